@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"xm-microservice/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configUsage = `usage: xm-microservice [flags] config print
+
+commands:
+  print       print the effective configuration as YAML with secrets redacted`
+
+// runConfig executes the config subcommand against the loaded configuration
+func runConfig(args []string, cfg *config.Config) error {
+	if len(args) != 1 || args[0] != "print" {
+		return errors.New(configUsage)
+	}
+
+	out, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
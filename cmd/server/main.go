@@ -1,36 +1,112 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"xm-microservice/internal/apikey"
+	"xm-microservice/internal/attributeschema"
 	"xm-microservice/internal/auth"
 	"xm-microservice/internal/company"
+	"xm-microservice/internal/companytype"
 	"xm-microservice/internal/config"
 	"xm-microservice/internal/database"
 	"xm-microservice/internal/event"
+	"xm-microservice/internal/feature"
 	"xm-microservice/internal/health"
+	"xm-microservice/internal/idempotency"
+	"xm-microservice/internal/mfa"
+	"xm-microservice/internal/ratelimit"
+	"xm-microservice/internal/tenant"
 	"xm-microservice/internal/user"
 	"xm-microservice/pkg/logger"
+	"xm-microservice/pkg/requestid"
+	"xm-microservice/pkg/utils"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
 func main() {
 	// Initialize the logger
 	appLogger := logger.NewLogger()
+
+	// Load application configuration from the config file, environment and flags
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		appLogger.Fatal(err)
+	}
+
+	// Print the effective configuration instead of starting the server when requested
+	if len(args) > 0 && args[0] == "config" {
+		if err := runConfig(args[1:], cfg); err != nil {
+			appLogger.Fatal(err)
+		}
+		return
+	}
+
+	pool := database.PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+	}
+
+	// Run the migrate subcommand instead of the server when requested
+	if len(args) > 0 && args[0] == "migrate" {
+		db, err := database.Connect(cfg.DatabaseURL, "", pool, appLogger)
+		if err != nil {
+			appLogger.Fatal(err)
+		}
+		defer db.Close()
+		if err := runMigrate(args[1:], db, appLogger); err != nil {
+			appLogger.Fatal(err)
+		}
+		return
+	}
+	if len(args) > 0 {
+		appLogger.Fatal(fmt.Errorf("unknown command %q\n%s", args[0], config.Usage()))
+	}
 	appLogger.Info("Starting the application...")
 
-	// Load application configuration
-	cfg := config.LoadConfig()
+	// Per-client rate limits for the login, registration and API route groups
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), appLogger)
+	// Validate has checked the trusted proxies
+	trustedProxies, _ := ratelimit.ParseTrustedProxies(cfg.TrustedProxies)
+	clients := ratelimit.NewClientKeys(trustedProxies)
+
+	// Features that operators can switch off without a restart
+	features := feature.NewToggles()
+
+	// Apply runtime settings now and again whenever the config file changes or on SIGHUP
+	reloader := config.NewReloader(cfg, os.Args[1:], appLogger)
+	reloader.OnReload(func(c *config.Config) {
+		level, _ := logger.ParseLevel(c.LogLevel)
+		appLogger.SetLevel(level)
+		limiter.SetPolicies(c.RateLimitPolicies())
+		features.Set(c.Features())
+	})
+	reloader.Watch(context.Background())
 
-	// Connect to the database
-	db, err := database.Connect(cfg.DatabaseURL, appLogger)
+	// Connect to the primary database and the optional read replica
+	db, err := database.Connect(cfg.DatabaseURL, cfg.DatabaseReplicaURL, pool, appLogger)
 	if err != nil {
 		appLogger.Fatal(err)
 	}
 	defer db.Close()
 	appLogger.Info("Connected to the database successfully")
 
+	// Apply migrations if enabled and refuse to serve a dirty or newer schema
+	if err := database.PrepareSchema(db, cfg.MigrateOnStartup, appLogger); err != nil {
+		appLogger.Fatal(err)
+	}
+
 	// Create Kafka topic and initialize producer
 	kafkaTopic := cfg.KafkaTopicCompany
 	if err := event.CreateTopic(cfg.KafkaBroker, kafkaTopic, cfg.KafkaPartitions, cfg.KafkaReplicationFactor, appLogger); err != nil {
@@ -38,48 +114,229 @@ func main() {
 	}
 	appLogger.Info("Kafka topic created successfully")
 
-	kafkaProducer := event.NewProducer(cfg.KafkaBroker, kafkaTopic, appLogger)
+	kafkaProducer := event.NewProducer(cfg.KafkaBroker, kafkaTopic, cfg.KafkaPublishTimeout, appLogger)
+
+	// Services share one transaction manager for operations that span several repository calls
+	txManager := database.NewTxManager(db, cfg.DBTxMaxRetries, appLogger)
+
+	// Initialize Company type service and handler; company validation reads the types through the shared cache
+	companyTypeRepo := companytype.NewRepository(db, cfg.DBQueryTimeout)
+	companyTypeCache := companytype.NewCache(companyTypeRepo, cfg.CompanyTypesCacheTTL)
+	companyTypeService := companytype.NewService(companyTypeRepo, companyTypeCache)
+	companyTypeHandler := companytype.NewHandler(companyTypeService, appLogger)
+
+	// Initialize the company attribute schema service and handler; company validation compiles the schema once per cache load
+	attributeSchemaRepo := attributeschema.NewRepository(db, cfg.DBQueryTimeout)
+	attributeSchemaCache := attributeschema.NewCache(attributeSchemaRepo, cfg.AttrSchemaCacheTTL)
+	attributeSchemaService := attributeschema.NewService(attributeSchemaRepo, attributeSchemaCache)
+	attributeSchemaHandler := attributeschema.NewHandler(attributeSchemaService, appLogger)
 
 	// Initialize Company service and handler with logger
-	companyRepo := company.NewRepository(db)
-	companyService := company.NewService(companyRepo)
-	companyHandler := company.NewHandler(companyService, kafkaProducer, appLogger)
+	companyRepo := company.NewRepository(db, cfg.DBQueryTimeout)
+	companyService := company.NewService(companyRepo, txManager, companyTypeCache, attributeSchemaCache, company.DuplicateCheck{
+		Threshold: cfg.DuplicateThreshold,
+		Action:    company.DuplicateAction(cfg.DuplicateAction),
+	})
+	companyHandler := company.NewHandler(companyService, kafkaProducer, company.ImportLimits{
+		MaxRows:  cfg.ImportMaxRows,
+		SyncRows: cfg.ImportSyncRows,
+	}, appLogger)
+
+	// Background imports left unfinished by a stopped instance would otherwise stay running forever
+	if failed, err := companyService.FailStaleImportJobs(context.Background()); err != nil {
+		appLogger.Error(err, "Failed to mark interrupted import jobs as failed")
+	} else if failed > 0 {
+		appLogger.Info("Marked %d interrupted import job(s) as failed", failed)
+	}
 
 	// Initialize User service and handler with logger
-	userRepo := user.NewRepository(db)
-	userService := user.NewService(userRepo)
+	userRepo := user.NewRepository(db, cfg.DBQueryTimeout)
+	userService := user.NewService(userRepo, txManager)
 	userHandler := user.NewHandler(userService, appLogger)
 
-	// Initialize Authentication middleware and handler with logger
-	authMiddleware := auth.NewMiddleware(cfg.JWTSecret)
-	authHandler := auth.NewAuthHandler(authMiddleware.GetJWTService(), userRepo, appLogger)
+	// Initialize Tenant service and handler with logger
+	tenantRepo := tenant.NewRepository(db, cfg.DBQueryTimeout)
+	tenantService := tenant.NewService(tenantRepo)
+	tenantHandler := tenant.NewHandler(tenantService, appLogger)
+
+	// Initialize MFA service and policy handler with logger
+	mfaRepo := mfa.NewRepository(db, cfg.DBQueryTimeout)
+	mfaService := mfa.NewService(mfaRepo, txManager, cfg.MFAIssuer)
+	mfaPolicyHandler := mfa.NewHandler(mfaService, appLogger)
+
+	// Initialize API key service and handler with logger
+	apiKeyRepo := apikey.NewRepository(db, cfg.DBQueryTimeout)
+	apiKeyService := apikey.NewService(apiKeyRepo)
+	apiKeyHandler := apikey.NewHandler(apiKeyService, appLogger)
+
+	// Initialize Authentication middleware and handlers with logger
+	authMiddleware := auth.NewMiddleware(cfg.JWTSecret, apiKeyService)
+	authHandler := auth.NewAuthHandler(authMiddleware.GetJWTService(), userRepo, mfaService, appLogger)
+	mfaHandler := auth.NewMFAHandler(authMiddleware.GetJWTService(), mfaService, userRepo, appLogger)
+
+	// Replay responses to retried POST requests that carry an Idempotency-Key
+	idempotencyRepo := idempotency.NewRepository(db, cfg.DBQueryTimeout)
+	idempotencyMiddleware := idempotency.NewMiddleware(idempotencyRepo, cfg.IdempotencyTTL, appLogger)
+	go idempotencyMiddleware.PurgeExpired(context.Background(), time.Hour)
 
-	// Set up the HTTP router
+	// Set up the HTTP router; every response, including unmatched routes, carries a request ID
 	router := mux.NewRouter()
+	router.Use(requestid.Middleware)
+	router.NotFoundHandler = requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.ErrorResponse(w, r, http.StatusNotFound, "No route matches the request")
+	}))
+	router.MethodNotAllowedHandler = requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.ErrorResponse(w, r, http.StatusMethodNotAllowed, "Method not allowed for this route")
+	}))
 
 	// Register health check endpoint
 	router.HandleFunc("/health", health.HealthHandler).Methods("GET")
 
-	// Authentication route
-	router.HandleFunc("/api/login", authHandler.Login).Methods("POST")
+	// Authentication routes, including the second factor step of the login flow, limited per IP
+	loginRoutes := router.PathPrefix("/api/login").Subrouter()
+	loginRoutes.Use(limiter.Middleware(ratelimit.GroupLogin, clients.ByIP))
+	loginRoutes.HandleFunc("", authHandler.Login).Methods("POST")
+	loginRoutes.HandleFunc("/mfa", mfaHandler.VerifyLogin).Methods("POST")
+	loginRoutes.HandleFunc("/mfa/enroll", mfaHandler.EnrollLogin).Methods("POST")
+	loginRoutes.HandleFunc("/mfa/confirm", mfaHandler.ConfirmLogin).Methods("POST")
+
+	// Optional login federation with an external OIDC identity provider
+	if cfg.OIDCEnabled() {
+		// Validate has checked that the tenant ID, when set, is a UUID
+		oidcTenantID, _ := uuid.Parse(cfg.OIDCTenantID)
+		oidcHandler, err := auth.NewOIDCHandler(context.Background(), auth.OIDCConfig{
+			IssuerURL:    cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+			GroupsClaim:  cfg.OIDCGroupsClaim,
+			RoleMapping:  cfg.OIDCRoleMapping,
+			DefaultRole:  cfg.OIDCDefaultRole,
+			TenantClaim:  cfg.OIDCTenantClaim,
+			TenantID:     oidcTenantID,
+		}, authMiddleware.GetJWTService(), userService, mfaService, appLogger)
+		if err != nil {
+			appLogger.Fatal(err)
+		}
+		loginRoutes.HandleFunc("/oidc", oidcHandler.Login).Methods("GET")
+		loginRoutes.HandleFunc("/oidc/callback", oidcHandler.Callback).Methods("GET")
+		appLogger.Info("OIDC login enabled for issuer %s", cfg.OIDCIssuerURL)
+	}
+
+	// Requests made with an API key are limited per key, other authenticated routes per user, anonymous ones per IP
+	apiLimit := limiter.Middleware(ratelimit.GroupAPI, clients.ByAPIKey)
+
+	// Protected routes for managing the caller's own second factor, which an API key cannot do
+	mfaRoutes := router.PathPrefix("/api/mfa").Subrouter()
+	mfaRoutes.Use(authMiddleware.ProtectMiddleware, apiLimit, authMiddleware.RequireToken)
+	mfaRoutes.HandleFunc("/enroll", mfaHandler.Enroll).Methods("POST")
+	mfaRoutes.HandleFunc("/confirm", mfaHandler.Confirm).Methods("POST")
+	mfaRoutes.HandleFunc("/recovery-codes", mfaHandler.RegenerateRecoveryCodes).Methods("POST")
+
+	// Protected routes for managing the caller's own API keys, which an API key cannot do either
+	apiKeyRoutes := router.PathPrefix("/api/api-keys").Subrouter()
+	apiKeyRoutes.Use(authMiddleware.ProtectMiddleware, apiLimit, authMiddleware.RequireToken)
+	apiKeyRoutes.HandleFunc("", apiKeyHandler.CreateKey).Methods("POST")
+	apiKeyRoutes.HandleFunc("", apiKeyHandler.ListKeys).Methods("GET")
+	apiKeyRoutes.HandleFunc("/{id}", apiKeyHandler.RevokeKey).Methods("DELETE")
 
-	// Public user registration route
+	// Platform admin routes for tenants, MFA policies per role and the reference data shared by all tenants,
+	// which apply to every tenant and are therefore out of reach of tenant admins
+	adminRoutes := router.PathPrefix("/api/admin").Subrouter()
+	adminRoutes.Use(authMiddleware.ProtectMiddleware, apiLimit, authMiddleware.RequireRole(string(user.RolePlatformAdmin)), idempotencyMiddleware.Handler)
+	adminRoutes.HandleFunc("/mfa/policies", mfaPolicyHandler.ListRolePolicies).Methods("GET")
+	adminRoutes.HandleFunc("/mfa/policies/{role}", mfaPolicyHandler.SetRolePolicy).Methods("PUT")
+	adminRoutes.HandleFunc("/tenants", tenantHandler.CreateTenant).Methods("POST")
+	adminRoutes.HandleFunc("/tenants", tenantHandler.ListTenants).Methods("GET")
+	adminRoutes.HandleFunc("/users/{id}/tenant", userHandler.AssignTenant).Methods("PUT")
+	adminRoutes.HandleFunc("/company-types", companyTypeHandler.CreateType).Methods("POST")
+	adminRoutes.HandleFunc("/company-types/{code}", companyTypeHandler.UpdateType).Methods("PATCH")
+	adminRoutes.HandleFunc("/company-types/{code}", companyTypeHandler.DeleteType).Methods("DELETE")
+	adminRoutes.HandleFunc("/company-attribute-schema", attributeSchemaHandler.SetSchema).Methods("PUT")
+	adminRoutes.HandleFunc("/tags/{name}", companyHandler.PutCuratedTag).Methods("PUT")
+	adminRoutes.HandleFunc("/tags/{name}", companyHandler.DeleteCuratedTag).Methods("DELETE")
+
+	// Public user registration route, limited per IP
 	userRoutes := router.PathPrefix("/api/users").Subrouter()
+	userRoutes.Use(limiter.Middleware(ratelimit.GroupSignup, clients.ByIP), features.Middleware(feature.Signup))
 	userRoutes.HandleFunc("", userHandler.CreateUser).Methods("POST")
 
-	// Public route for retrieving company details
-	router.HandleFunc("/api/companies/{id}", companyHandler.GetCompany).Methods("GET")
+	// Public route for listing company types, including deprecated ones
+	router.Handle("/api/company-types", apiLimit(http.HandlerFunc(companyTypeHandler.ListTypes))).Methods("GET")
+
+	// Public route for reading the schema that company attributes must satisfy
+	router.Handle("/api/company-attribute-schema", apiLimit(http.HandlerFunc(attributeSchemaHandler.GetSchema))).Methods("GET")
+
+	// Protected route for the tag catalogue of the caller's tenant, with curated tags and how many companies carry each tag
+	router.Handle("/api/tags", authMiddleware.ProtectMiddleware(apiLimit(http.HandlerFunc(companyHandler.ListTags)))).Methods("GET")
+
+	// Protected routes for listing, searching and retrieving the companies of the caller's tenant, their addresses,
+	// contacts and hierarchy. Contacts name people, and addresses belong to the tenant, so neither is public
+	router.Handle("/api/companies", authMiddleware.ProtectMiddleware(apiLimit(http.HandlerFunc(companyHandler.ListCompanies)))).Methods("GET")
+	router.Handle("/api/companies/search", authMiddleware.ProtectMiddleware(apiLimit(http.HandlerFunc(companyHandler.SearchCompanies)))).Methods("GET")
+	router.Handle("/api/companies/lookup", authMiddleware.ProtectMiddleware(apiLimit(http.HandlerFunc(companyHandler.LookupCompany)))).Methods("GET")
+	router.Handle("/api/companies/{id}", authMiddleware.ProtectMiddleware(apiLimit(http.HandlerFunc(companyHandler.GetCompany)))).Methods("GET")
+	router.Handle("/api/companies/{id}/addresses", authMiddleware.ProtectMiddleware(apiLimit(http.HandlerFunc(companyHandler.ListAddresses)))).Methods("GET")
+	router.Handle("/api/companies/{id}/contacts", authMiddleware.ProtectMiddleware(apiLimit(http.HandlerFunc(companyHandler.ListContacts)))).Methods("GET")
+	router.Handle("/api/companies/{id}/subsidiaries", authMiddleware.ProtectMiddleware(apiLimit(http.HandlerFunc(companyHandler.ListSubsidiaries)))).Methods("GET")
+	router.Handle("/api/companies/{id}/ancestors", authMiddleware.ProtectMiddleware(apiLimit(http.HandlerFunc(companyHandler.ListAncestors)))).Methods("GET")
+	router.Handle("/api/companies/{id}/group", authMiddleware.ProtectMiddleware(apiLimit(http.HandlerFunc(companyHandler.GetGroupSummary)))).Methods("GET")
+
+	// Imports upload whole files, so keyed import requests may be as large as an import file
+	router.Handle("/api/companies:import", authMiddleware.ProtectMiddleware(apiLimit(idempotencyMiddleware.HandlerWithLimit(company.MaxImportBytes)(features.Middleware(feature.Import)(http.HandlerFunc(companyHandler.ImportCompanies)))))).Methods("POST")
 
-	// Protected routes for creating, updating, and deleting companies
+	// Protected routes for creating, updating, deleting, merging, restructuring, importing and exporting companies
+	// and for managing their addresses and contacts
 	companyRoutes := router.PathPrefix("/api/companies").Subrouter()
-	companyRoutes.Use(authMiddleware.ProtectMiddleware)
+	companyRoutes.Use(authMiddleware.ProtectMiddleware, apiLimit, idempotencyMiddleware.Handler)
+	// Deleting and merging companies destroys data, so it is left to admins and platform admins who signed in with a second factor
+	destructive := func(handler http.HandlerFunc) http.Handler {
+		return authMiddleware.RequireRoleAtLeast(user.RoleAdmin)(authMiddleware.RequireMFA(handler))
+	}
 	companyRoutes.HandleFunc("", companyHandler.CreateCompany).Methods("POST")
+	companyRoutes.Handle(":export", features.Middleware(feature.Export)(http.HandlerFunc(companyHandler.ExportCompanies))).Methods("GET")
 	companyRoutes.HandleFunc("/{id}", companyHandler.UpdateCompany).Methods("PATCH")
-	companyRoutes.HandleFunc("/{id}", companyHandler.DeleteCompany).Methods("DELETE")
+	companyRoutes.Handle("/{id}", destructive(companyHandler.DeleteCompany)).Methods("DELETE")
+	companyRoutes.Handle("/{id}/merge", destructive(companyHandler.MergeCompanies)).Methods("POST")
+	companyRoutes.HandleFunc("/{id}/transitions/{transition}", companyHandler.TransitionCompany).Methods("POST")
+	companyRoutes.HandleFunc("/{id}/parent", companyHandler.SetParent).Methods("PUT")
+	companyRoutes.HandleFunc("/{id}/parent", companyHandler.RemoveParent).Methods("DELETE")
+	companyRoutes.HandleFunc("/{id}/addresses", companyHandler.CreateAddress).Methods("POST")
+	companyRoutes.HandleFunc("/{id}/addresses/{addressID}", companyHandler.UpdateAddress).Methods("PUT")
+	companyRoutes.HandleFunc("/{id}/addresses/{addressID}", companyHandler.DeleteAddress).Methods("DELETE")
+	companyRoutes.HandleFunc("/{id}/contacts", companyHandler.CreateContact).Methods("POST")
+	companyRoutes.HandleFunc("/{id}/contacts/{contactID}", companyHandler.UpdateContact).Methods("PUT")
+	companyRoutes.HandleFunc("/{id}/contacts/{contactID}", companyHandler.DeleteContact).Methods("DELETE")
+	companyRoutes.HandleFunc("/{id}/tags", companyHandler.AddTags).Methods("POST")
+	companyRoutes.HandleFunc("/{id}/tags", companyHandler.ReplaceTags).Methods("PUT")
+	companyRoutes.HandleFunc("/{id}/tags/{tag}", companyHandler.RemoveTag).Methods("DELETE")
 
-	// Start the HTTP server
-	appLogger.Info("Server is running on port %s", cfg.Port)
-	if err := http.ListenAndServe(":"+cfg.Port, router); err != nil {
-		appLogger.Fatal(err)
+	// Protected route for following background company imports
+	importJobRoutes := router.PathPrefix("/api/import-jobs").Subrouter()
+	importJobRoutes.Use(authMiddleware.ProtectMiddleware, apiLimit)
+	importJobRoutes.HandleFunc("/{id}", companyHandler.GetImportJob).Methods("GET")
+
+	// Start the HTTP server and serve until SIGINT or SIGTERM
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: router}
+	stop, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	go func() {
+		appLogger.Info("Server is running on port %s", cfg.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			appLogger.Fatal(err)
+		}
+	}()
+	<-stop.Done()
+
+	// Let in-flight requests and background imports finish before the database connections close
+	appLogger.Info("Shutting down, waiting up to %s for requests and imports to finish", cfg.ShutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		appLogger.Error(err, "Failed to finish in-flight requests")
+	}
+	if err := companyService.Shutdown(ctx); err != nil {
+		appLogger.Error(err, "Cancelled background imports that were still running")
 	}
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"xm-microservice/internal/database"
+	"xm-microservice/pkg/logger"
+)
+
+const migrateUsage = `usage: xm-microservice migrate <command>
+
+commands:
+  up          apply all pending migrations
+  down N      roll back the last N migrations
+  goto V      migrate up or down to version V
+  version     print the current schema version
+  force V     set the schema version to V without running migrations`
+
+// runMigrate executes the migrate subcommand against the configured database
+func runMigrate(args []string, db *database.DB, log *logger.Logger) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := database.NewMigrator(db, log)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "up":
+		return migrator.Up()
+	case "down":
+		steps, err := intArg(args)
+		if err != nil {
+			return err
+		}
+		return migrator.Down(steps)
+	case "goto":
+		version, err := intArg(args)
+		if err != nil {
+			return err
+		}
+		if version < 0 {
+			return fmt.Errorf("invalid version: %d", version)
+		}
+		return migrator.Goto(uint(version))
+	case "force":
+		version, err := intArg(args)
+		if err != nil {
+			return err
+		}
+		return migrator.Force(version)
+	case "version":
+		version, dirty, err := migrator.Version()
+		if err != nil {
+			return err
+		}
+		latest, err := database.LatestVersion()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version: %d\ndirty: %t\nlatest: %d\n", version, dirty, latest)
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+}
+
+// intArg parses the single integer argument of a migrate command
+func intArg(args []string) (int, error) {
+	if len(args) != 2 {
+		return 0, fmt.Errorf("%s expects exactly one numeric argument\n%s", args[0], migrateUsage)
+	}
+	value, err := strconv.Atoi(args[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid argument %q for %s: %w", args[1], args[0], err)
+	}
+	return value, nil
+}
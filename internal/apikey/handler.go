@@ -0,0 +1,89 @@
+package apikey
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"xm-microservice/internal/auth"
+	"xm-microservice/pkg/logger"
+	"xm-microservice/pkg/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Handler manages the API keys of the authenticated user
+type Handler struct {
+	service Service
+	logger  *logger.Logger
+}
+
+// NewHandler initializes a new API key handler
+func NewHandler(service Service, logger *logger.Logger) *Handler {
+	return &Handler{service: service, logger: logger}
+}
+
+// CreateKey creates an API key for the authenticated user and returns the key once
+func (h *Handler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("CreateKey handler invoked")
+
+	identity, _ := auth.IdentityFromContext(r.Context())
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error(err, "Invalid input while decoding API key data")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	key, err := h.service.CreateKey(r.Context(), identity.UserID, req.Name)
+	if err != nil {
+		h.logger.Error(err, "Failed to create API key")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.logger.Info("API key %s created for user: %s", key.ID, identity.Username)
+	utils.JSONResponse(w, http.StatusCreated, key)
+}
+
+// ListKeys returns the API keys of the authenticated user, without the keys themselves
+func (h *Handler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("ListKeys handler invoked")
+
+	identity, _ := auth.IdentityFromContext(r.Context())
+
+	keys, err := h.service.ListKeys(r.Context(), identity.UserID)
+	if err != nil {
+		h.logger.Error(err, "Failed to list API keys")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, keys)
+}
+
+// RevokeKey deletes an API key of the authenticated user
+func (h *Handler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("RevokeKey handler invoked")
+
+	identity, _ := auth.IdentityFromContext(r.Context())
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.logger.Error(err, "Invalid UUID")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid UUID")
+		return
+	}
+
+	if err := h.service.RevokeKey(r.Context(), identity.UserID, id); err != nil {
+		h.logger.Error(err, "Failed to revoke API key")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.logger.Info("API key %s revoked for user: %s", id, identity.Username)
+	w.WriteHeader(http.StatusNoContent)
+}
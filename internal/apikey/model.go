@@ -0,0 +1,32 @@
+package apikey
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey describes a key a user created to call the API without logging in. The key itself is only
+// shown once, when it is created; the prefix identifies it afterwards.
+type APIKey struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"-"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreatedKey is returned once when a key is created, together with the key itself
+type CreatedKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+// Owner is the user an API key acts as, with the role and tenant the user has now
+type Owner struct {
+	KeyID    uuid.UUID
+	UserID   uuid.UUID
+	Username string
+	Role     string
+	TenantID uuid.UUID
+}
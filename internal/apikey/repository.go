@@ -0,0 +1,86 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"xm-microservice/internal/database"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	Create(ctx context.Context, key *APIKey, keyHash string) error
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]APIKey, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+	FindOwner(ctx context.Context, keyHash string) (*Owner, error)
+}
+
+type repository struct {
+	db      *database.DB
+	timeout time.Duration
+}
+
+// NewRepository initializes a new API key repository with the database connection and query timeout
+func NewRepository(db *database.DB, timeout time.Duration) Repository {
+	return &repository{db: db, timeout: timeout}
+}
+
+// Create stores a new API key of a user with the hash of the key
+func (r *repository) Create(ctx context.Context, key *APIKey, keyHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `INSERT INTO api_keys (id, user_id, name, prefix, key_hash) VALUES ($1, $2, $3, $4, $5) RETURNING created_at`
+	return mapError(r.db.Writer(ctx).QueryRowContext(ctx, query, key.ID, key.UserID, key.Name, key.Prefix, keyHash).Scan(&key.CreatedAt))
+}
+
+// ListByUser retrieves the API keys of a user, newest first
+func (r *repository) ListByUser(ctx context.Context, userID uuid.UUID) ([]APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT id, user_id, name, prefix, created_at FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC, id`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var key APIKey
+		if err := rows.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.CreatedAt); err != nil {
+			return nil, mapError(err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, mapError(rows.Err())
+}
+
+// Delete revokes an API key of a user
+func (r *repository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.Writer(ctx).ExecContext(ctx, `DELETE FROM api_keys WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return mapError(err)
+	}
+	return mapError(database.ExpectRows(result))
+}
+
+// FindOwner retrieves the key with the given hash and the user it belongs to.
+// It reads from the primary so that a revoked key stops working at once.
+func (r *repository) FindOwner(ctx context.Context, keyHash string) (*Owner, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT k.id, u.id, u.username, u.role, u.tenant_id FROM api_keys k JOIN users u ON u.id = k.user_id WHERE k.key_hash = $1`
+	owner := &Owner{}
+	err := r.db.Primary().QueryRowContext(ctx, query, keyHash).Scan(&owner.KeyID, &owner.UserID, &owner.Username, &owner.Role, &owner.TenantID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return owner, nil
+}
@@ -0,0 +1,99 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"xm-microservice/internal/auth"
+	"xm-microservice/pkg/apperror"
+
+	"github.com/google/uuid"
+)
+
+// fakeRepository keeps the keys of a single user in memory, indexed by hash
+type fakeRepository struct {
+	Repository
+	owner  Owner
+	hashes map[string]uuid.UUID
+}
+
+func (r *fakeRepository) Create(_ context.Context, key *APIKey, keyHash string) error {
+	r.hashes[keyHash] = key.ID
+	return nil
+}
+
+func (r *fakeRepository) Delete(_ context.Context, userID, id uuid.UUID) error {
+	for hash, keyID := range r.hashes {
+		if keyID == id && userID == r.owner.UserID {
+			delete(r.hashes, hash)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *fakeRepository) FindOwner(_ context.Context, keyHash string) (*Owner, error) {
+	keyID, ok := r.hashes[keyHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	owner := r.owner
+	owner.KeyID = keyID
+	return &owner, nil
+}
+
+func TestCreateAndAuthenticate(t *testing.T) {
+	owner := Owner{UserID: uuid.New(), Username: "jane", Role: "admin", TenantID: uuid.New()}
+	repo := &fakeRepository{owner: owner, hashes: map[string]uuid.UUID{}}
+	s := NewService(repo)
+	ctx := context.Background()
+
+	created, err := s.CreateKey(ctx, owner.UserID, "  ci pipeline  ")
+	if err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+	if created.Name != "ci pipeline" || !strings.HasPrefix(created.Key, keyPrefix) || created.Prefix != created.Key[:shownPrefix] {
+		t.Errorf("CreateKey() = %+v with key %q", created.APIKey, created.Key)
+	}
+	if _, stored := repo.hashes[created.Key]; stored {
+		t.Error("CreateKey() stored the key in the clear")
+	}
+
+	identity, err := s.AuthenticateAPIKey(ctx, created.Key)
+	if err != nil {
+		t.Fatalf("AuthenticateAPIKey() error = %v", err)
+	}
+	want := auth.Identity{UserID: owner.UserID, Username: "jane", Role: "admin", TenantID: owner.TenantID, APIKeyID: created.ID}
+	if identity != want {
+		t.Errorf("AuthenticateAPIKey() = %+v, want %+v", identity, want)
+	}
+
+	if err := s.RevokeKey(ctx, owner.UserID, created.ID); err != nil {
+		t.Fatalf("RevokeKey() error = %v", err)
+	}
+	if _, err := s.AuthenticateAPIKey(ctx, created.Key); !errors.Is(err, auth.ErrInvalidAPIKey) {
+		t.Errorf("AuthenticateAPIKey() after revoking = %v, want %v", err, auth.ErrInvalidAPIKey)
+	}
+}
+
+func TestAuthenticateRejectsUnknownKeys(t *testing.T) {
+	s := NewService(&fakeRepository{hashes: map[string]uuid.UUID{}})
+	for _, key := range []string{"", "xmk_unknown", "not-a-key"} {
+		if _, err := s.AuthenticateAPIKey(context.Background(), key); !errors.Is(err, auth.ErrInvalidAPIKey) {
+			t.Errorf("AuthenticateAPIKey(%q) = %v, want %v", key, err, auth.ErrInvalidAPIKey)
+		}
+	}
+}
+
+func TestCreateKeyValidatesName(t *testing.T) {
+	s := NewService(&fakeRepository{hashes: map[string]uuid.UUID{}})
+	for _, name := range []string{"", "   ", strings.Repeat("n", 101)} {
+		_, err := s.CreateKey(context.Background(), uuid.New(), name)
+		var violations *apperror.ValidationError
+		if !errors.As(err, &violations) {
+			t.Errorf("CreateKey(%q) = %v, want a validation error", name, err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package attributeschema
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache keeps the compiled schema and recompiles it once it is older than its TTL.
+// Changes made through this instance invalidate it at once; other instances see them within the TTL.
+type Cache struct {
+	repo      Repository
+	ttl       time.Duration
+	mu        sync.Mutex
+	validator *Validator
+	loadedAt  time.Time
+}
+
+// NewCache initializes an empty attribute schema cache that loads from the repository on first use
+func NewCache(repo Repository, ttl time.Duration) *Cache {
+	return &Cache{repo: repo, ttl: ttl}
+}
+
+// Validator returns the compiled schema, reloading it when it is missing or expired
+func (c *Cache) Validator(ctx context.Context) (*Validator, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.validator != nil && time.Since(c.loadedAt) < c.ttl {
+		return c.validator, nil
+	}
+
+	schema, err := c.repo.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	validator, err := Compile(schema.Document)
+	if err != nil {
+		return nil, err
+	}
+	c.validator, c.loadedAt = validator, time.Now()
+	return validator, nil
+}
+
+// Invalidate drops the compiled schema so that the next lookup reloads it
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validator = nil
+}
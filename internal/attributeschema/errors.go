@@ -0,0 +1,28 @@
+package attributeschema
+
+import (
+	"database/sql"
+	"errors"
+
+	"xm-microservice/internal/database"
+	"xm-microservice/pkg/apperror"
+)
+
+var (
+	ErrNotFound    = apperror.New(apperror.ErrNotFound, "company attribute schema not found")
+	ErrUnavailable = apperror.New(apperror.ErrUnavailable, "company attribute schema storage is unavailable")
+)
+
+// mapError translates database errors into attribute schema domain errors
+func mapError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case database.IsUnavailable(err):
+		return ErrUnavailable.WithCause(err)
+	default:
+		return err
+	}
+}
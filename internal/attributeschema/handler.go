@@ -0,0 +1,56 @@
+package attributeschema
+
+import (
+	"io"
+	"net/http"
+
+	"xm-microservice/pkg/logger"
+	"xm-microservice/pkg/utils"
+)
+
+// Handler manages the endpoints for the company attribute schema
+type Handler struct {
+	service Service
+	logger  *logger.Logger
+}
+
+// NewHandler initializes a new attribute schema handler
+func NewHandler(service Service, logger *logger.Logger) *Handler {
+	return &Handler{service: service, logger: logger}
+}
+
+// GetSchema returns the schema that company attributes are validated against
+func (h *Handler) GetSchema(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("GetSchema handler invoked")
+
+	schema, err := h.service.GetSchema(r.Context())
+	if err != nil {
+		h.logger.Error(err, "Failed to get company attribute schema")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, schema)
+}
+
+// SetSchema handles replacing the schema with the JSON Schema document in the request body
+func (h *Handler) SetSchema(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("SetSchema handler invoked")
+
+	document, err := io.ReadAll(io.LimitReader(r.Body, maxDocumentSize+1))
+	if err != nil {
+		h.logger.Error(err, "Failed to read company attribute schema")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	schema, err := h.service.SetSchema(r.Context(), document)
+	if err != nil {
+		h.logger.Error(err, "Failed to set company attribute schema")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.logger.Info("Company attribute schema updated successfully")
+	utils.JSONResponse(w, http.StatusOK, schema)
+}
@@ -0,0 +1,12 @@
+package attributeschema
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Schema is the JSON Schema that the custom attributes of every company must satisfy
+type Schema struct {
+	Document  json.RawMessage `json:"schema"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
@@ -0,0 +1,52 @@
+package attributeschema
+
+import (
+	"context"
+	"time"
+
+	"xm-microservice/internal/database"
+)
+
+type Repository interface {
+	Get(ctx context.Context) (*Schema, error)
+	Put(ctx context.Context, document []byte) (*Schema, error)
+}
+
+type repository struct {
+	db      *database.DB
+	timeout time.Duration
+}
+
+// NewRepository initializes a new attribute schema repository with the database connection and query timeout
+func NewRepository(db *database.DB, timeout time.Duration) Repository {
+	return &repository{db: db, timeout: timeout}
+}
+
+// Get retrieves the schema, always from the primary since it fills the validation cache
+func (r *repository) Get(ctx context.Context) (*Schema, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	schema := &Schema{}
+	err := r.db.Primary().QueryRowContext(ctx, `SELECT document, updated_at FROM company_attribute_schema`).Scan(&schema.Document, &schema.UpdatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return schema, nil
+}
+
+// Put replaces the schema document and returns it as stored
+func (r *repository) Put(ctx context.Context, document []byte) (*Schema, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `INSERT INTO company_attribute_schema (id, document) VALUES (TRUE, $1)
+		ON CONFLICT (id) DO UPDATE SET document=EXCLUDED.document, updated_at=NOW()
+		RETURNING document, updated_at`
+	schema := &Schema{}
+	err := r.db.Writer(ctx).QueryRowContext(ctx, query, string(document)).Scan(&schema.Document, &schema.UpdatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return schema, nil
+}
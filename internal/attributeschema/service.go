@@ -0,0 +1,52 @@
+package attributeschema
+
+import (
+	"context"
+
+	"xm-microservice/pkg/apperror"
+)
+
+// maxDocumentSize caps the size of a schema document in bytes
+const maxDocumentSize = 64 * 1024
+
+// Service defines the business logic interface for the company attribute schema
+type Service interface {
+	GetSchema(ctx context.Context) (*Schema, error)
+	SetSchema(ctx context.Context, document []byte) (*Schema, error)
+}
+
+type service struct {
+	repo  Repository
+	cache *Cache
+}
+
+// NewService initializes a new attribute schema service with the repository and the cache it keeps current
+func NewService(repo Repository, cache *Cache) Service {
+	return &service{repo: repo, cache: cache}
+}
+
+// GetSchema retrieves the schema that company attributes are validated against
+func (s *service) GetSchema(ctx context.Context) (*Schema, error) {
+	return s.repo.Get(ctx)
+}
+
+// SetSchema replaces the schema after checking that it compiles.
+// Companies are only checked against it when they are next created or updated.
+func (s *service) SetSchema(ctx context.Context, document []byte) (*Schema, error) {
+	violations := &apperror.ValidationError{}
+	if len(document) > maxDocumentSize {
+		violations.Add("schema", apperror.CodeTooLong, "the schema must be up to 64 KiB")
+	} else if _, err := Compile(document); err != nil {
+		violations.Add("schema", apperror.CodeInvalidValue, err.Error())
+	}
+	if err := violations.Err(); err != nil {
+		return nil, err
+	}
+
+	schema, err := s.repo.Put(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Invalidate()
+	return schema, nil
+}
@@ -0,0 +1,80 @@
+package attributeschema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"xm-microservice/pkg/apperror"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// schemaURL is the location the schema document is registered under while compiling
+const schemaURL = "urn:xm-microservice:company-attributes"
+
+// printer renders validation messages
+var printer = message.NewPrinter(language.English)
+
+// Validator checks company attributes against a compiled schema
+type Validator struct {
+	schema *jsonschema.Schema
+}
+
+// Compile parses a schema document and compiles it with format assertions enabled.
+// References to other documents are refused so that a schema cannot make the service fetch files or URLs.
+func Compile(document []byte) (*Validator, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(document))
+	if err != nil {
+		return nil, fmt.Errorf("the schema is not valid JSON: %w", err)
+	}
+	if _, ok := doc.(map[string]interface{}); !ok {
+		return nil, errors.New("the schema must be a JSON object")
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	compiler.AssertFormat()
+	compiler.UseLoader(noLoader{})
+	if err := compiler.AddResource(schemaURL, doc); err != nil {
+		return nil, err
+	}
+	schema, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{schema: schema}, nil
+}
+
+// Validate adds a violation for every way the attributes fail the schema, with fields named attributes.<path>
+func (v *Validator) Validate(attributes map[string]interface{}, violations *apperror.ValidationError) {
+	err := v.schema.Validate(attributes)
+	var invalid *jsonschema.ValidationError
+	if errors.As(err, &invalid) {
+		addViolations(invalid, violations)
+	} else if err != nil {
+		violations.Add("attributes", apperror.CodeInvalidValue, err.Error())
+	}
+}
+
+// addViolations reports the causes at the leaves of a validation error, which name the failed keywords
+func addViolations(err *jsonschema.ValidationError, violations *apperror.ValidationError) {
+	if len(err.Causes) == 0 {
+		field := strings.Join(append([]string{"attributes"}, err.InstanceLocation...), ".")
+		violations.Add(field, apperror.CodeInvalidValue, err.ErrorKind.LocalizedString(printer))
+		return
+	}
+	for _, cause := range err.Causes {
+		addViolations(cause, violations)
+	}
+}
+
+// noLoader refuses to load any document referenced by the schema
+type noLoader struct{}
+
+func (noLoader) Load(url string) (any, error) {
+	return nil, fmt.Errorf("schema references to %s are not allowed", url)
+}
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type contextKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the authenticated identity
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// IdentityFromContext returns the authenticated identity stored in ctx, if any
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(Identity)
+	return identity, ok
+}
+
+// TenantFromContext returns the tenant of the authenticated identity, or uuid.Nil, which matches no tenant, for anonymous requests
+func TenantFromContext(ctx context.Context) uuid.UUID {
+	if identity, ok := IdentityFromContext(ctx); ok {
+		return identity.TenantID
+	}
+	return uuid.Nil
+}
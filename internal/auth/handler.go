@@ -2,13 +2,16 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
+	"xm-microservice/internal/mfa"
 	"xm-microservice/internal/user"
 	"xm-microservice/pkg/logger"
 	"xm-microservice/pkg/utils"
 
+	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -17,17 +20,22 @@ type Credentials struct {
 	Password string `json:"password"`
 }
 
+// mfaChallengeTTL is how long the password step of a login stays valid while awaiting the second factor
+const mfaChallengeTTL = 5 * time.Minute
+
 type AuthHandler struct {
 	jwtService JWTService
 	userRepo   *user.Repository
+	mfaService mfa.Service
 	logger     *logger.Logger
 }
 
 // NewAuthHandler initializes a new AuthHandler
-func NewAuthHandler(jwtService JWTService, userRepo *user.Repository, logger *logger.Logger) *AuthHandler {
+func NewAuthHandler(jwtService JWTService, userRepo *user.Repository, mfaService mfa.Service, logger *logger.Logger) *AuthHandler {
 	return &AuthHandler{
 		jwtService: jwtService,
 		userRepo:   userRepo,
+		mfaService: mfaService,
 		logger:     logger,
 	}
 }
@@ -39,38 +47,91 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var creds Credentials
 	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
 		h.logger.Error(err, "Invalid input while decoding credentials")
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid input")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
 		return
 	}
 
 	// Fetch user from the database
-	userData, err := h.userRepo.GetUserByUsername(creds.Username)
-	if err != nil {
+	userData, err := h.userRepo.GetUserByUsername(r.Context(), creds.Username)
+	if errors.Is(err, user.ErrNotFound) {
 		h.logger.Error(err, "Unauthorized - user not found")
-		utils.ErrorResponse(w, http.StatusUnauthorized, "Unauthorized - user not found")
+		utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - user not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error(err, "Failed to fetch user")
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	// Compare hashed password with provided password
 	if err := bcrypt.CompareHashAndPassword([]byte(userData.PasswordHash), []byte(creds.Password)); err != nil {
 		h.logger.Error(err, "Unauthorized - invalid password")
-		utils.ErrorResponse(w, http.StatusUnauthorized, "Unauthorized - invalid password")
+		utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - invalid password")
+		return
+	}
+
+	completeLogin(w, r, h.jwtService, h.mfaService, userData, h.logger)
+}
+
+// completeLogin issues a JWT token, or an MFA challenge token when the user must present a second factor
+func completeLogin(w http.ResponseWriter, r *http.Request, jwtService JWTService, mfaService mfa.Service, userData *user.User, log *logger.Logger) {
+	enabled, required, err := mfaService.Status(r.Context(), userData.ID, string(userData.Role))
+	if err != nil {
+		log.Error(err, "Failed to check MFA status")
+		utils.ErrorResponse(w, r, http.StatusInternalServerError, "Failed to check MFA status")
+		return
+	}
+
+	if !enabled && !required {
+		issueToken(w, r, jwtService, userData, false, log)
 		return
 	}
 
-	// Generate JWT token
-	token, err := h.jwtService.GenerateToken(userData.Username)
+	challenge, err := jwtService.GenerateScopedToken(TokenTypeMFA, jwt.MapClaims{
+		"sub": userData.ID.String(),
+	}, mfaChallengeTTL)
+	if err != nil {
+		log.Error(err, "Failed to generate MFA challenge token")
+		utils.ErrorResponse(w, r, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	log.Info("MFA challenge issued for user: %s", userData.Username)
+
+	// Return the challenge; the client exchanges it together with a code for a JWT token
+	response := map[string]interface{}{
+		"mfa_required":        true,
+		"enrollment_required": !enabled,
+		"mfa_token":           challenge,
+		"expires_at":          time.Now().Add(mfaChallengeTTL).Unix(),
+	}
+
+	utils.JSONResponse(w, http.StatusOK, response)
+}
+
+// issueToken generates a JWT access token for the user and writes it with its metadata.
+// mfaVerified records in the token whether the user presented a second factor.
+func issueToken(w http.ResponseWriter, r *http.Request, jwtService JWTService, userData *user.User, mfaVerified bool, log *logger.Logger) {
+	identity := Identity{
+		UserID:   userData.ID,
+		Username: userData.Username,
+		Role:     string(userData.Role),
+		TenantID: userData.TenantID,
+		MFA:      mfaVerified,
+	}
+	token, err := jwtService.GenerateToken(identity)
 	if err != nil {
-		h.logger.Error(err, "Failed to generate token")
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to generate token")
+		log.Error(err, "Failed to generate token")
+		utils.ErrorResponse(w, r, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
-	h.logger.Info("JWT token generated successfully for user: %s", userData.Username)
+	log.Info("JWT token generated successfully for user: %s", userData.Username)
 
 	// Extract token claims to include created_at and expires_at
 	createdAt := time.Now().Unix()
-	expiresAt := createdAt + (2 * 3600) // 2 hours from now
+	expiresAt := createdAt + int64(accessTokenTTL.Seconds())
 
 	// Return the JWT token with metadata
 	response := map[string]interface{}{
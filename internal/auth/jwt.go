@@ -1,14 +1,40 @@
 package auth
 
 import (
+	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
+// Token types distinguish access tokens from short-lived tokens used inside auth flows
+const (
+	TokenTypeAccess    = "access"
+	TokenTypeOIDCState = "oidc_state"
+	TokenTypeMFA       = "mfa_challenge"
+)
+
+// accessTokenTTL is how long an access token stays valid
+const accessTokenTTL = 2 * time.Hour
+
+// Identity describes the authenticated user carried in an access token
+type Identity struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	TenantID uuid.UUID `json:"tenant_id"`
+	// MFA tells whether the user presented a second factor when the token was issued
+	MFA bool `json:"mfa"`
+	// APIKeyID is the key the request authenticated with, or uuid.Nil for a token
+	APIKeyID uuid.UUID `json:"-"`
+}
+
 type JWTService interface {
-	GenerateToken(userID string) (string, error)
+	GenerateToken(identity Identity) (string, error)
 	ValidateToken(tokenString string) (*jwt.Token, error)
+	GenerateScopedToken(tokenType string, claims jwt.MapClaims, ttl time.Duration) (string, error)
+	ValidateScopedToken(tokenString, tokenType string) (jwt.MapClaims, error)
 }
 
 type jwtService struct {
@@ -19,20 +45,16 @@ func NewJWTService(secretKey string) JWTService {
 	return &jwtService{secretKey: secretKey}
 }
 
-// GenerateToken generates a JWT token for a given user ID with additional claims
-func (j *jwtService) GenerateToken(userID string) (string, error) {
-	createdAt := time.Now().Unix()
-	expiresAt := time.Now().Add(2 * time.Hour).Unix() // Token expires in 2 hours
-
+// GenerateToken generates a JWT access token for a given identity with additional claims
+func (j *jwtService) GenerateToken(identity Identity) (string, error) {
 	claims := jwt.MapClaims{
-		"user_id":    userID,
-		"created_at": createdAt,
-		"expires_at": expiresAt,
-		"exp":        expiresAt, // Standard expiration claim
+		"sub":       identity.UserID.String(),
+		"user_id":   identity.Username,
+		"role":      identity.Role,
+		"tenant_id": identity.TenantID.String(),
+		"mfa":       identity.MFA,
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secretKey))
+	return j.GenerateScopedToken(TokenTypeAccess, claims, accessTokenTTL)
 }
 
 // ValidateToken validates the provided JWT token and returns the parsed token
@@ -45,3 +67,39 @@ func (j *jwtService) ValidateToken(tokenString string) (*jwt.Token, error) {
 		return []byte(j.secretKey), nil
 	})
 }
+
+// GenerateScopedToken signs the given claims as a token of the given type that expires after ttl
+func (j *jwtService) GenerateScopedToken(tokenType string, claims jwt.MapClaims, ttl time.Duration) (string, error) {
+	createdAt := time.Now().Unix()
+	expiresAt := time.Now().Add(ttl).Unix()
+
+	signed := jwt.MapClaims{}
+	for key, value := range claims {
+		signed[key] = value
+	}
+	signed["token_type"] = tokenType
+	signed["created_at"] = createdAt
+	signed["expires_at"] = expiresAt
+	signed["exp"] = expiresAt // Standard expiration claim
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, signed)
+	return token.SignedString([]byte(j.secretKey))
+}
+
+// ValidateScopedToken validates a token and ensures it was issued for the given type
+func (j *jwtService) ValidateScopedToken(tokenString, tokenType string) (jwt.MapClaims, error) {
+	token, err := j.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if claims["token_type"] != tokenType {
+		return nil, errors.New("unexpected token type")
+	}
+	return claims, nil
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"xm-microservice/internal/database"
+	"xm-microservice/internal/mfa"
+	"xm-microservice/internal/user"
+	"xm-microservice/pkg/logger"
+	"xm-microservice/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+// MFAHandler manages TOTP enrollment and the second step of the login flow
+type MFAHandler struct {
+	jwtService JWTService
+	mfaService mfa.Service
+	userRepo   *user.Repository
+	logger     *logger.Logger
+}
+
+// NewMFAHandler initializes a new MFAHandler
+func NewMFAHandler(jwtService JWTService, mfaService mfa.Service, userRepo *user.Repository, logger *logger.Logger) *MFAHandler {
+	return &MFAHandler{
+		jwtService: jwtService,
+		mfaService: mfaService,
+		userRepo:   userRepo,
+		logger:     logger,
+	}
+}
+
+type mfaRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+// VerifyLogin exchanges an MFA challenge token and a TOTP or recovery code for a JWT token
+func (h *MFAHandler) VerifyLogin(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("VerifyLogin handler invoked")
+
+	req, userData, ok := h.resolveChallenge(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.mfaService.Verify(r.Context(), userData.ID, req.Code); err != nil {
+		h.writeError(w, r, err, "Failed to verify MFA code")
+		return
+	}
+
+	issueToken(w, r, h.jwtService, userData, true, h.logger)
+}
+
+// EnrollLogin starts TOTP enrollment for a user whose role requires MFA but who has not enrolled yet
+func (h *MFAHandler) EnrollLogin(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("EnrollLogin handler invoked")
+
+	_, userData, ok := h.resolveChallenge(w, r)
+	if !ok {
+		return
+	}
+
+	h.enroll(w, r, userData.ID, userData.Username)
+}
+
+// ConfirmLogin enables MFA during login and returns recovery codes together with a JWT token
+func (h *MFAHandler) ConfirmLogin(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("ConfirmLogin handler invoked")
+
+	req, userData, ok := h.resolveChallenge(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.mfaService.Confirm(r.Context(), userData.ID, req.Code)
+	if err != nil {
+		h.writeError(w, r, err, "Failed to confirm MFA enrollment")
+		return
+	}
+
+	token, err := h.jwtService.GenerateToken(Identity{
+		UserID:   userData.ID,
+		Username: userData.Username,
+		Role:     string(userData.Role),
+		TenantID: userData.TenantID,
+		MFA:      true,
+	})
+	if err != nil {
+		h.logger.Error(err, "Failed to generate token")
+		utils.ErrorResponse(w, r, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	h.logger.Info("MFA enabled for user: %s", userData.Username)
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"token":          token,
+		"recovery_codes": codes,
+	})
+}
+
+// Enroll starts TOTP enrollment for the authenticated user
+func (h *MFAHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("Enroll handler invoked")
+
+	identity, _ := IdentityFromContext(r.Context())
+	h.enroll(w, r, identity.UserID, identity.Username)
+}
+
+// Confirm enables MFA for the authenticated user and returns their recovery codes
+func (h *MFAHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("Confirm handler invoked")
+
+	var req mfaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error(err, "Invalid input while decoding MFA code")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	identity, _ := IdentityFromContext(r.Context())
+	codes, err := h.mfaService.Confirm(r.Context(), identity.UserID, req.Code)
+	if err != nil {
+		h.writeError(w, r, err, "Failed to confirm MFA enrollment")
+		return
+	}
+
+	h.logger.Info("MFA enabled for user: %s", identity.Username)
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the authenticated user
+func (h *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("RegenerateRecoveryCodes handler invoked")
+
+	var req mfaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error(err, "Invalid input while decoding MFA code")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	identity, _ := IdentityFromContext(r.Context())
+	codes, err := h.mfaService.RegenerateRecoveryCodes(r.Context(), identity.UserID, req.Code)
+	if err != nil {
+		h.writeError(w, r, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	h.logger.Info("Recovery codes regenerated for user: %s", identity.Username)
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+}
+
+// enroll generates a TOTP secret for the user and writes it with its provisioning URI
+func (h *MFAHandler) enroll(w http.ResponseWriter, r *http.Request, userID uuid.UUID, account string) {
+	enrollment, err := h.mfaService.Enroll(r.Context(), userID, account)
+	if err != nil {
+		h.writeError(w, r, err, "Failed to start MFA enrollment")
+		return
+	}
+
+	h.logger.Info("MFA enrollment started for user: %s", account)
+	utils.JSONResponse(w, http.StatusOK, enrollment)
+}
+
+// resolveChallenge decodes the request and loads the user an MFA challenge token was issued for
+func (h *MFAHandler) resolveChallenge(w http.ResponseWriter, r *http.Request) (*mfaRequest, *user.User, bool) {
+	var req mfaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error(err, "Invalid input while decoding MFA request")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return nil, nil, false
+	}
+
+	claims, err := h.jwtService.ValidateScopedToken(req.MFAToken, TokenTypeMFA)
+	if err != nil {
+		h.logger.Error(err, "Invalid MFA challenge token")
+		utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - invalid MFA token")
+		return nil, nil, false
+	}
+
+	subject, _ := claims["sub"].(string)
+	userID, err := uuid.Parse(subject)
+	if err != nil {
+		h.logger.Error(err, "Invalid subject in MFA challenge token")
+		utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - invalid MFA token")
+		return nil, nil, false
+	}
+
+	// The challenge may be redeemed right after the user was provisioned, so read from the primary
+	userData, err := h.userRepo.GetUserByID(database.WithPrimary(r.Context()), userID)
+	if errors.Is(err, user.ErrNotFound) {
+		h.logger.Error(err, "Unauthorized - user not found")
+		utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - user not found")
+		return nil, nil, false
+	}
+	if err != nil {
+		h.logger.Error(err, "Failed to fetch user")
+		utils.WriteError(w, r, err)
+		return nil, nil, false
+	}
+
+	return &req, userData, true
+}
+
+// writeError maps MFA service errors to HTTP responses
+func (h *MFAHandler) writeError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	h.logger.Error(err, message)
+
+	switch {
+	case errors.Is(err, mfa.ErrInvalidCode):
+		utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - invalid verification code")
+	case errors.Is(err, mfa.ErrNotEnrolled), errors.Is(err, mfa.ErrAlreadyEnabled):
+		utils.ErrorResponse(w, r, http.StatusConflict, err.Error())
+	default:
+		utils.ErrorResponse(w, r, http.StatusInternalServerError, message)
+	}
+}
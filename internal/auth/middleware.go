@@ -1,18 +1,38 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"slices"
 	"strings"
+
+	"xm-microservice/internal/user"
+	"xm-microservice/pkg/utils"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
+// ErrInvalidAPIKey is returned by an APIKeyAuthenticator for a key that does not exist or was revoked
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// APIKeyAuthenticator resolves an API key to the identity it acts as
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (Identity, error)
+}
+
 type Middleware struct {
 	jwtService JWTService
+	apiKeys    APIKeyAuthenticator
 }
 
-// NewMiddleware initializes the JWT middleware with a secret key
-func NewMiddleware(secretKey string) *Middleware {
+// NewMiddleware initializes the JWT middleware with a secret key. Requests without a token may
+// authenticate with an X-API-Key header when apiKeys is not nil.
+func NewMiddleware(secretKey string, apiKeys APIKeyAuthenticator) *Middleware {
 	return &Middleware{
 		jwtService: NewJWTService(secretKey),
+		apiKeys:    apiKeys,
 	}
 }
 
@@ -26,23 +46,120 @@ func (m *Middleware) ProtectMiddleware(next http.Handler) http.Handler {
 	return m.Protect(next.ServeHTTP)
 }
 
-// Protect validates the JWT token from the Authorization header
+// Protect validates the JWT token from the Authorization header, or the API key from the X-API-Key header
 func (m *Middleware) Protect(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tokenString := extractToken(r)
+		if key := r.Header.Get("X-API-Key"); tokenString == "" && key != "" && m.apiKeys != nil {
+			identity, err := m.apiKeys.AuthenticateAPIKey(r.Context(), key)
+			if errors.Is(err, ErrInvalidAPIKey) {
+				utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - invalid API key")
+				return
+			}
+			if err != nil {
+				utils.WriteError(w, r, err)
+				return
+			}
+			next(w, r.WithContext(WithIdentity(r.Context(), identity)))
+			return
+		}
 		if tokenString == "" {
-			http.Error(w, "Unauthorized - no token provided", http.StatusUnauthorized)
+			utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - no token provided")
+			return
+		}
+
+		claims, err := m.jwtService.ValidateScopedToken(tokenString, TokenTypeAccess)
+		if err != nil {
+			utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - invalid token")
+			return
+		}
+
+		identity, err := identityFromClaims(claims)
+		if err != nil {
+			utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - invalid token")
+			return
+		}
+
+		next(w, r.WithContext(WithIdentity(r.Context(), identity)))
+	}
+}
+
+// RequireRole returns a middleware that only lets through identities with one of the given roles.
+// It must run after ProtectMiddleware.
+func (m *Middleware) RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, ok := IdentityFromContext(r.Context())
+			if !ok || !slices.Contains(roles, identity.Role) {
+				utils.ErrorResponse(w, r, http.StatusForbidden, "Forbidden - insufficient role")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRoleAtLeast returns a middleware that only lets through identities whose role grants at least
+// the privileges of min. It must run after ProtectMiddleware.
+func (m *Middleware) RequireRoleAtLeast(min user.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, ok := IdentityFromContext(r.Context())
+			if !ok || !user.Role(identity.Role).AtLeast(min) {
+				utils.ErrorResponse(w, r, http.StatusForbidden, "Forbidden - insufficient role")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireMFA only lets through identities whose token was issued after a second factor was verified.
+// It must run after ProtectMiddleware.
+func (m *Middleware) RequireMFA(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := IdentityFromContext(r.Context())
+		if !ok || !identity.MFA {
+			utils.ErrorResponse(w, r, http.StatusForbidden, "Forbidden - sign in with a second factor to perform this action")
 			return
 		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-		token, err := m.jwtService.ValidateToken(tokenString)
-		if err != nil || !token.Valid {
-			http.Error(w, "Unauthorized - invalid token", http.StatusUnauthorized)
+// RequireToken only lets through identities that authenticated with a token rather than an API key,
+// so that a leaked key cannot manage keys or second factors. It must run after ProtectMiddleware.
+func (m *Middleware) RequireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := IdentityFromContext(r.Context())
+		if !ok || identity.APIKeyID != uuid.Nil {
+			utils.ErrorResponse(w, r, http.StatusForbidden, "Forbidden - sign in to perform this action")
 			return
 		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// identityFromClaims builds the identity carried by validated access token claims
+func identityFromClaims(claims jwt.MapClaims) (Identity, error) {
+	subject, _ := claims["sub"].(string)
+	userID, err := uuid.Parse(subject)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	username, _ := claims["user_id"].(string)
+	role, _ := claims["role"].(string)
 
-		next(w, r)
+	tenantClaim, _ := claims["tenant_id"].(string)
+	tenantID, err := uuid.Parse(tenantClaim)
+	if err != nil {
+		return Identity{}, err
 	}
+
+	mfaVerified, _ := claims["mfa"].(bool)
+
+	return Identity{UserID: userID, Username: username, Role: role, TenantID: tenantID, MFA: mfaVerified}, nil
 }
 
 // extractToken extracts the JWT token from the Authorization header
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"xm-microservice/internal/user"
+
+	"github.com/google/uuid"
+)
+
+func TestRequireMFA(t *testing.T) {
+	m := NewMiddleware(testJWTSecret, nil)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	protected := m.ProtectMiddleware(m.RequireRole("admin")(m.RequireMFA(ok)))
+
+	tests := []struct {
+		name       string
+		identity   *Identity
+		wantStatus int
+	}{
+		{name: "admin with a second factor", identity: &Identity{Role: "admin", MFA: true}, wantStatus: http.StatusNoContent},
+		{name: "admin with a password only", identity: &Identity{Role: "admin"}, wantStatus: http.StatusForbidden},
+		{name: "user with a second factor", identity: &Identity{Role: "user", MFA: true}, wantStatus: http.StatusForbidden},
+		{name: "anonymous", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/api/companies/1", nil)
+			if tt.identity != nil {
+				tt.identity.UserID, tt.identity.Username, tt.identity.TenantID = uuid.New(), "jane", uuid.New()
+				token, err := m.GetJWTService().GenerateToken(*tt.identity)
+				if err != nil {
+					t.Fatalf("GenerateToken() error = %v", err)
+				}
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			rec := httptest.NewRecorder()
+			protected.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	m := NewMiddleware(testJWTSecret, nil)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	tests := []struct {
+		name       string
+		roles      []string
+		role       string
+		wantStatus int
+	}{
+		{name: "only allowed role", roles: []string{"platform_admin"}, role: "platform_admin", wantStatus: http.StatusNoContent},
+		{name: "tenant admin on a platform route", roles: []string{"platform_admin"}, role: "admin", wantStatus: http.StatusForbidden},
+		{name: "one of several roles", roles: []string{"admin", "platform_admin"}, role: "admin", wantStatus: http.StatusNoContent},
+		{name: "none of several roles", roles: []string{"admin", "platform_admin"}, role: "user", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/tenants", nil)
+			req = req.WithContext(WithIdentity(req.Context(), Identity{UserID: uuid.New(), Role: tt.role}))
+
+			rec := httptest.NewRecorder()
+			m.RequireRole(tt.roles...)(ok).ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequireRoleAtLeast(t *testing.T) {
+	m := NewMiddleware(testJWTSecret, nil)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	tests := []struct {
+		role       string
+		wantStatus int
+	}{
+		{role: "user", wantStatus: http.StatusForbidden},
+		{role: "admin", wantStatus: http.StatusNoContent},
+		{role: "platform_admin", wantStatus: http.StatusNoContent},
+		{role: "", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/api/companies/1", nil)
+			req = req.WithContext(WithIdentity(req.Context(), Identity{UserID: uuid.New(), Role: tt.role}))
+
+			rec := httptest.NewRecorder()
+			m.RequireRoleAtLeast(user.RoleAdmin)(ok).ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestTenantFromContextAnonymous(t *testing.T) {
+	if got := TenantFromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context()); got != uuid.Nil {
+		t.Errorf("TenantFromContext() = %s for an anonymous request, want no tenant", got)
+	}
+}
+
+// fakeAPIKeys accepts a single key
+type fakeAPIKeys struct {
+	key      string
+	identity Identity
+}
+
+func (f fakeAPIKeys) AuthenticateAPIKey(_ context.Context, key string) (Identity, error) {
+	if key != f.key {
+		return Identity{}, ErrInvalidAPIKey
+	}
+	return f.identity, nil
+}
+
+func TestProtectWithAPIKey(t *testing.T) {
+	keyIdentity := Identity{UserID: uuid.New(), Role: "user", TenantID: uuid.New(), APIKeyID: uuid.New()}
+	m := NewMiddleware(testJWTSecret, fakeAPIKeys{key: "xmk_valid", identity: keyIdentity})
+
+	var got Identity
+	protected := m.Protect(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = IdentityFromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tokenIdentity := Identity{UserID: uuid.New(), Username: "jane", Role: "admin", TenantID: uuid.New()}
+	token, err := m.GetJWTService().GenerateToken(tokenIdentity)
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		key        string
+		wantStatus int
+		wantUser   uuid.UUID
+	}{
+		{name: "valid key", key: "xmk_valid", wantStatus: http.StatusNoContent, wantUser: keyIdentity.UserID},
+		{name: "unknown key", key: "xmk_revoked", wantStatus: http.StatusUnauthorized},
+		{name: "token wins over key", token: token, key: "xmk_valid", wantStatus: http.StatusNoContent, wantUser: tokenIdentity.UserID},
+		{name: "neither", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = Identity{}
+			req := httptest.NewRequest(http.MethodGet, "/api/companies", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+
+			rec := httptest.NewRecorder()
+			protected.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got.UserID != tt.wantUser {
+				t.Errorf("identity user = %s, want %s", got.UserID, tt.wantUser)
+			}
+		})
+	}
+
+	t.Run("keys disabled", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/companies", nil)
+		req.Header.Set("X-API-Key", "xmk_valid")
+		rec := httptest.NewRecorder()
+		NewMiddleware(testJWTSecret, nil).Protect(protected).ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestRequireToken(t *testing.T) {
+	m := NewMiddleware(testJWTSecret, nil)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	tests := []struct {
+		name       string
+		identity   *Identity
+		wantStatus int
+	}{
+		{name: "token", identity: &Identity{UserID: uuid.New()}, wantStatus: http.StatusNoContent},
+		{name: "API key", identity: &Identity{UserID: uuid.New(), APIKeyID: uuid.New()}, wantStatus: http.StatusForbidden},
+		{name: "anonymous", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/api-keys", nil)
+			if tt.identity != nil {
+				req = req.WithContext(WithIdentity(req.Context(), *tt.identity))
+			}
+
+			rec := httptest.NewRecorder()
+			m.RequireToken(ok).ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"xm-microservice/internal/mfa"
+	"xm-microservice/internal/user"
+	"xm-microservice/pkg/logger"
+	"xm-microservice/pkg/utils"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+// errNoTenant reports an ID token that names no tenant when no tenant is configured for the issuer
+var errNoTenant = errors.New("ID token names no tenant and the issuer has no tenant configured")
+
+// FederatedProvisioner resolves the local user for an identity asserted by an identity provider
+type FederatedProvisioner interface {
+	ProvisionFederatedUser(ctx context.Context, profile user.FederatedProfile) (*user.User, error)
+}
+
+// OIDCConfig holds the settings needed to federate login with an OIDC identity provider
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	GroupsClaim  string
+	RoleMapping  map[string]string
+	DefaultRole  string
+	// TenantClaim names the ID token claim holding the user's tenant ID; it takes precedence over TenantID
+	TenantClaim string
+	// TenantID is the tenant of the users of the issuer whose ID token names no tenant
+	TenantID uuid.UUID
+}
+
+// OIDCHandler implements the authorization code flow with PKCE against an OIDC issuer
+type OIDCHandler struct {
+	oauth2Config oauth2.Config
+	verifier     *oidc.IDTokenVerifier
+	issuer       string
+	groupsClaim  string
+	roleMapping  map[string]user.Role
+	defaultRole  user.Role
+	tenantClaim  string
+	tenantID     uuid.UUID
+	secureCookie bool
+	jwtService   JWTService
+	userService  FederatedProvisioner
+	mfaService   mfa.Service
+	logger       *logger.Logger
+}
+
+// NewOIDCHandler discovers the issuer configuration and initializes the OIDC login handler
+func NewOIDCHandler(ctx context.Context, cfg OIDCConfig, jwtService JWTService, userService FederatedProvisioner, mfaService mfa.Service, logger *logger.Logger) (*OIDCHandler, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC issuer: %w", err)
+	}
+
+	roleMapping := make(map[string]user.Role, len(cfg.RoleMapping))
+	for group, role := range cfg.RoleMapping {
+		if !user.Role(role).Valid() {
+			return nil, fmt.Errorf("invalid role %q mapped to group %q", role, group)
+		}
+		roleMapping[group] = user.Role(role)
+	}
+
+	defaultRole := user.Role(cfg.DefaultRole)
+	if !defaultRole.Valid() {
+		return nil, fmt.Errorf("invalid default OIDC role %q", cfg.DefaultRole)
+	}
+
+	scopes := append([]string{oidc.ScopeOpenID}, cfg.Scopes...)
+
+	return &OIDCHandler{
+		oauth2Config: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier:     provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		issuer:       cfg.IssuerURL,
+		groupsClaim:  cfg.GroupsClaim,
+		roleMapping:  roleMapping,
+		defaultRole:  defaultRole,
+		tenantClaim:  cfg.TenantClaim,
+		tenantID:     cfg.TenantID,
+		secureCookie: strings.HasPrefix(cfg.RedirectURL, "https://"),
+		jwtService:   jwtService,
+		userService:  userService,
+		mfaService:   mfaService,
+		logger:       logger,
+	}, nil
+}
+
+// Login starts the authorization code flow by redirecting the browser to the identity provider
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("OIDC Login handler invoked")
+
+	state, err := randomString()
+	if err != nil {
+		h.logger.Error(err, "Failed to generate OIDC state")
+		utils.ErrorResponse(w, r, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		h.logger.Error(err, "Failed to generate OIDC nonce")
+		utils.ErrorResponse(w, r, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+
+	verifier := oauth2.GenerateVerifier()
+
+	// The flow state travels in a signed cookie so that any instance can complete the callback
+	stateToken, err := h.jwtService.GenerateScopedToken(TokenTypeOIDCState, jwt.MapClaims{
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+	}, oidcStateTTL)
+	if err != nil {
+		h.logger.Error(err, "Failed to sign OIDC state")
+		utils.ErrorResponse(w, r, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    stateToken,
+		Path:     "/",
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   h.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	authURL := h.oauth2Config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback completes the flow, provisions or links the local user and issues a JWT token
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("OIDC Callback handler invoked")
+
+	if idpErr := r.URL.Query().Get("error"); idpErr != "" {
+		h.logger.Info("Identity provider returned error: %s", idpErr)
+		utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - login rejected by identity provider")
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		h.logger.Error(err, "Missing OIDC state cookie")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Login session expired or missing")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Value: "", Path: "/", MaxAge: -1})
+
+	flow, err := h.jwtService.ValidateScopedToken(cookie.Value, TokenTypeOIDCState)
+	if err != nil {
+		h.logger.Error(err, "Invalid OIDC state cookie")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Login session expired or missing")
+		return
+	}
+
+	state, _ := flow["state"].(string)
+	nonce, _ := flow["nonce"].(string)
+	verifier, _ := flow["verifier"].(string)
+	if state == "" || r.URL.Query().Get("state") != state {
+		h.logger.Info("OIDC state mismatch")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid login state")
+		return
+	}
+
+	token, err := h.oauth2Config.Exchange(r.Context(), r.URL.Query().Get("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		h.logger.Error(err, "Failed to exchange authorization code")
+		utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - code exchange failed")
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		h.logger.Info("Token response did not contain an ID token")
+		utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - missing ID token")
+		return
+	}
+
+	idToken, err := h.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		h.logger.Error(err, "Failed to verify ID token")
+		utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - invalid ID token")
+		return
+	}
+
+	if idToken.Nonce != nonce {
+		h.logger.Info("OIDC nonce mismatch")
+		utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - invalid ID token")
+		return
+	}
+
+	profile, err := h.profileFromToken(idToken)
+	if errors.Is(err, errNoTenant) {
+		h.logger.Info("OIDC login without a tenant for subject %s", idToken.Subject)
+		utils.ErrorResponse(w, r, http.StatusForbidden, "Forbidden - no tenant for this account")
+		return
+	}
+	if err != nil {
+		h.logger.Error(err, "Failed to read ID token claims")
+		utils.ErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized - invalid ID token")
+		return
+	}
+
+	userData, err := h.userService.ProvisionFederatedUser(r.Context(), profile)
+	if err != nil {
+		h.logger.Error(err, "Failed to provision federated user")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.logger.Info("Federated user %s signed in via %s", userData.Username, h.issuer)
+	completeLogin(w, r, h.jwtService, h.mfaService, userData, h.logger)
+}
+
+// profileFromToken extracts the user profile and mapped role from a verified ID token
+func (h *OIDCHandler) profileFromToken(idToken *oidc.IDToken) (user.FederatedProfile, error) {
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return user.FederatedProfile{}, err
+	}
+
+	email, _ := claims["email"].(string)
+	emailVerified, _ := claims["email_verified"].(bool)
+	username, _ := claims["preferred_username"].(string)
+	if username == "" {
+		username = email
+	}
+	if username == "" {
+		username = idToken.Subject
+	}
+	if username == "" {
+		return user.FederatedProfile{}, errors.New("ID token has no subject")
+	}
+
+	tenantID, err := h.tenantFromClaims(claims)
+	if err != nil {
+		return user.FederatedProfile{}, err
+	}
+
+	return user.FederatedProfile{
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Username:      username,
+		Email:         email,
+		EmailVerified: emailVerified,
+		Role:          h.mapRole(claims[h.groupsClaim]),
+		TenantID:      tenantID,
+	}, nil
+}
+
+// tenantFromClaims resolves the tenant of a user from the tenant claim, or else from the tenant configured for the issuer
+func (h *OIDCHandler) tenantFromClaims(claims map[string]interface{}) (uuid.UUID, error) {
+	if h.tenantClaim != "" {
+		if value, ok := claims[h.tenantClaim].(string); ok && value != "" {
+			tenantID, err := uuid.Parse(value)
+			if err != nil {
+				return uuid.Nil, fmt.Errorf("invalid tenant claim %q: %w", h.tenantClaim, err)
+			}
+			return tenantID, nil
+		}
+	}
+	if h.tenantID == uuid.Nil {
+		return uuid.Nil, errNoTenant
+	}
+	return h.tenantID, nil
+}
+
+// mapRole resolves the most privileged local role for the groups claim of an ID token
+func (h *OIDCHandler) mapRole(groupsClaim interface{}) user.Role {
+	var groups []string
+	switch value := groupsClaim.(type) {
+	case []interface{}:
+		for _, group := range value {
+			if name, ok := group.(string); ok {
+				groups = append(groups, name)
+			}
+		}
+	case string:
+		groups = append(groups, value)
+	}
+
+	role := h.defaultRole
+	// The most privileged mapped role wins
+	for _, group := range groups {
+		if mapped, ok := h.roleMapping[group]; ok && !role.AtLeast(mapped) {
+			role = mapped
+		}
+	}
+	return role
+}
+
+// randomString returns a URL-safe random string suitable for state and nonce values
+func randomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
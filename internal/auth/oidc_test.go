@@ -0,0 +1,382 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"xm-microservice/internal/mfa"
+	"xm-microservice/internal/user"
+	"xm-microservice/pkg/logger"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+const (
+	testClientID  = "xm-microservice"
+	testKeyID     = "test-key"
+	testJWTSecret = "0123456789abcdef0123456789abcdef"
+)
+
+// mockProvider is a local OIDC identity provider serving discovery, JWKS and a token endpoint
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+// authorization is what the provider remembers about a code between the authorize and token steps
+type authorization struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	p := &mockProvider{key: key, codes: map[string]authorization{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": testKeyID,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// authorize plays the user signing in at the provider: it issues a code for the PKCE challenge that
+// will be redeemed for an ID token with the given claims
+func (p *mockProvider) authorize(challenge string, claims jwt.MapClaims) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	code := uuid.NewString()
+	p.codes[code] = authorization{challenge: challenge, claims: claims}
+	return code
+}
+
+// token redeems a code once, checking the PKCE verifier against the challenge of the authorize step
+func (p *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	auth, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, auth.claims)
+	token.Header["kid"] = testKeyID
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "provider-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// idClaims returns the claims of a valid ID token for the subject and nonce
+func (p *mockProvider) idClaims(subject, nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":                p.server.URL,
+		"aud":                testClientID,
+		"sub":                subject,
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Hour).Unix(),
+		"nonce":              nonce,
+		"email":              subject + "@example.com",
+		"email_verified":     true,
+		"preferred_username": subject,
+	}
+}
+
+// recordingProvisioner returns a user for every profile and remembers the last profile
+type recordingProvisioner struct {
+	profile *user.FederatedProfile
+}
+
+func (p *recordingProvisioner) ProvisionFederatedUser(_ context.Context, profile user.FederatedProfile) (*user.User, error) {
+	p.profile = &profile
+	return &user.User{ID: uuid.New(), Username: profile.Username, Role: profile.Role, TenantID: profile.TenantID}, nil
+}
+
+// noMFA reports every user as not enrolled and not required to use MFA
+type noMFA struct {
+	mfa.Service
+}
+
+func (noMFA) Status(context.Context, uuid.UUID, string) (bool, bool, error) {
+	return false, false, nil
+}
+
+var testTenant = uuid.MustParse("00000000-0000-0000-0000-0000000000aa")
+
+func newTestOIDCHandler(t *testing.T, p *mockProvider, provisioner FederatedProvisioner) *OIDCHandler {
+	t.Helper()
+	h, err := NewOIDCHandler(context.Background(), OIDCConfig{
+		IssuerURL:    p.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8080/api/login/oidc/callback",
+		GroupsClaim:  "groups",
+		RoleMapping:  map[string]string{"xm-admins": "admin", "xm-staff": "user"},
+		DefaultRole:  "user",
+		TenantClaim:  "tenant_id",
+		TenantID:     testTenant,
+	}, NewJWTService(testJWTSecret), provisioner, noMFA{}, logger.NewLogger())
+	if err != nil {
+		t.Fatalf("NewOIDCHandler() error = %v", err)
+	}
+	return h
+}
+
+// startLogin runs the Login step and returns the authorize request parameters and the state cookie
+func startLogin(t *testing.T, h *OIDCHandler) (url.Values, *http.Cookie) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.Login(rec, httptest.NewRequest(http.MethodGet, "/api/login/oidc", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Login status = %d, want %d", rec.Code, http.StatusFound)
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect: %v", err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oidcStateCookie || !cookies[0].HttpOnly {
+		t.Fatalf("Login cookies = %v, want one HttpOnly %s cookie", cookies, oidcStateCookie)
+	}
+	return location.Query(), cookies[0]
+}
+
+// callback runs the Callback step for a code and state with the state cookie
+func callback(h *OIDCHandler, code, state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	query := url.Values{"code": {code}, "state": {state}}
+	r := httptest.NewRequest(http.MethodGet, "/api/login/oidc/callback?"+query.Encode(), nil)
+	r.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	h.Callback(rec, r)
+	return rec
+}
+
+func TestOIDCLoginRoundTrip(t *testing.T) {
+	p := newMockProvider(t)
+	provisioner := &recordingProvisioner{}
+	h := newTestOIDCHandler(t, p, provisioner)
+
+	params, cookie := startLogin(t, h)
+	if params.Get("code_challenge_method") != "S256" || params.Get("code_challenge") == "" {
+		t.Fatalf("authorize request %v has no S256 PKCE challenge", params)
+	}
+	if params.Get("state") == "" || params.Get("nonce") == "" || params.Get("client_id") != testClientID {
+		t.Fatalf("authorize request %v lacks state, nonce or client ID", params)
+	}
+
+	code := p.authorize(params.Get("code_challenge"), p.idClaims("jane", params.Get("nonce")))
+	rec := callback(h, code, params.Get("state"), cookie)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Callback status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	var response struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil || response.Token == "" {
+		t.Fatalf("Callback response has no token: %v", err)
+	}
+	claims, err := NewJWTService(testJWTSecret).ValidateScopedToken(response.Token, TokenTypeAccess)
+	if err != nil {
+		t.Fatalf("issued token is invalid: %v", err)
+	}
+	if claims["tenant_id"] != testTenant.String() {
+		t.Errorf("token tenant = %v, want %s", claims["tenant_id"], testTenant)
+	}
+
+	got := provisioner.profile
+	if got.Issuer != p.server.URL || got.Subject != "jane" || got.Email != "jane@example.com" || !got.EmailVerified {
+		t.Errorf("provisioned profile = %+v", got)
+	}
+}
+
+func TestOIDCCallbackRejectsTamperedFlows(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(p *mockProvider, params url.Values) (code, state string)
+		want   int
+	}{
+		{
+			name: "nonce mismatch",
+			tamper: func(p *mockProvider, params url.Values) (string, string) {
+				return p.authorize(params.Get("code_challenge"), p.idClaims("jane", "another-nonce")), params.Get("state")
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "expired ID token",
+			tamper: func(p *mockProvider, params url.Values) (string, string) {
+				claims := p.idClaims("jane", params.Get("nonce"))
+				claims["iat"] = time.Now().Add(-2 * time.Hour).Unix()
+				claims["exp"] = time.Now().Add(-time.Hour).Unix()
+				return p.authorize(params.Get("code_challenge"), claims), params.Get("state")
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "ID token for another client",
+			tamper: func(p *mockProvider, params url.Values) (string, string) {
+				claims := p.idClaims("jane", params.Get("nonce"))
+				claims["aud"] = "another-client"
+				return p.authorize(params.Get("code_challenge"), claims), params.Get("state")
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "state mismatch",
+			tamper: func(p *mockProvider, params url.Values) (string, string) {
+				return p.authorize(params.Get("code_challenge"), p.idClaims("jane", params.Get("nonce"))), "forged-state"
+			},
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "PKCE challenge of another login",
+			tamper: func(p *mockProvider, params url.Values) (string, string) {
+				sum := sha256.Sum256([]byte("attacker-verifier"))
+				challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+				return p.authorize(challenge, p.idClaims("jane", params.Get("nonce"))), params.Get("state")
+			},
+			want: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newMockProvider(t)
+			provisioner := &recordingProvisioner{}
+			h := newTestOIDCHandler(t, p, provisioner)
+
+			params, cookie := startLogin(t, h)
+			code, state := tt.tamper(p, params)
+			rec := callback(h, code, state, cookie)
+			if rec.Code != tt.want {
+				t.Errorf("Callback status = %d, want %d, body %s", rec.Code, tt.want, rec.Body)
+			}
+			if provisioner.profile != nil {
+				t.Errorf("user was provisioned for a rejected login: %+v", provisioner.profile)
+			}
+		})
+	}
+}
+
+func TestOIDCRoleAndTenantMapping(t *testing.T) {
+	claimTenant := uuid.MustParse("00000000-0000-0000-0000-0000000000bb")
+	tests := []struct {
+		name       string
+		groups     interface{}
+		tenant     interface{}
+		wantRole   user.Role
+		wantTenant uuid.UUID
+		wantStatus int
+	}{
+		{name: "no groups get the default role", wantRole: user.RoleUser, wantTenant: testTenant, wantStatus: http.StatusOK},
+		{name: "mapped group", groups: []string{"xm-admins"}, wantRole: user.RoleAdmin, wantTenant: testTenant, wantStatus: http.StatusOK},
+		{name: "most privileged group wins", groups: []string{"xm-staff", "xm-admins", "other"}, wantRole: user.RoleAdmin, wantTenant: testTenant, wantStatus: http.StatusOK},
+		{name: "unmapped groups", groups: []string{"other"}, wantRole: user.RoleUser, wantTenant: testTenant, wantStatus: http.StatusOK},
+		{name: "single group as a string", groups: "xm-admins", wantRole: user.RoleAdmin, wantTenant: testTenant, wantStatus: http.StatusOK},
+		{name: "tenant claim wins over the issuer tenant", tenant: claimTenant.String(), wantRole: user.RoleUser, wantTenant: claimTenant, wantStatus: http.StatusOK},
+		{name: "invalid tenant claim", tenant: "not-a-uuid", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newMockProvider(t)
+			provisioner := &recordingProvisioner{}
+			h := newTestOIDCHandler(t, p, provisioner)
+
+			params, cookie := startLogin(t, h)
+			claims := p.idClaims("jane", params.Get("nonce"))
+			if tt.groups != nil {
+				claims["groups"] = tt.groups
+			}
+			if tt.tenant != nil {
+				claims["tenant_id"] = tt.tenant
+			}
+			rec := callback(h, p.authorize(params.Get("code_challenge"), claims), params.Get("state"), cookie)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Callback status = %d, want %d, body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if provisioner.profile.Role != tt.wantRole || provisioner.profile.TenantID != tt.wantTenant {
+				t.Errorf("profile role %s tenant %s, want %s and %s", provisioner.profile.Role, provisioner.profile.TenantID, tt.wantRole, tt.wantTenant)
+			}
+		})
+	}
+}
+
+func TestOIDCLoginWithoutTenant(t *testing.T) {
+	p := newMockProvider(t)
+	provisioner := &recordingProvisioner{}
+	h := newTestOIDCHandler(t, p, provisioner)
+	h.tenantID = uuid.Nil
+
+	params, cookie := startLogin(t, h)
+	rec := callback(h, p.authorize(params.Get("code_challenge"), p.idClaims("jane", params.Get("nonce"))), params.Get("state"), cookie)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Callback status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if provisioner.profile != nil {
+		t.Errorf("user was provisioned without a tenant: %+v", provisioner.profile)
+	}
+}
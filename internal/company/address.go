@@ -0,0 +1,82 @@
+package company
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"xm-microservice/internal/jurisdiction"
+	"xm-microservice/pkg/apperror"
+
+	"github.com/google/uuid"
+)
+
+// AddressKind tells what a company uses an address for
+type AddressKind string
+
+const (
+	// AddressRegistered is the official address of a company; a company has at most one
+	AddressRegistered AddressKind = "registered"
+	// AddressTrading is a place a company does business from
+	AddressTrading AddressKind = "trading"
+)
+
+// Address is a postal address of a company
+type Address struct {
+	ID         uuid.UUID   `json:"id"`
+	CompanyID  uuid.UUID   `json:"company_id"`
+	Kind       AddressKind `json:"kind"`
+	Line1      string      `json:"line1"`
+	Line2      string      `json:"line2,omitempty"`
+	City       string      `json:"city"`
+	Region     string      `json:"region,omitempty"`
+	PostalCode string      `json:"postal_code,omitempty"`
+	Country    string      `json:"country"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+var (
+	ErrAddressNotFound         = apperror.New(apperror.ErrNotFound, "address not found")
+	ErrRegisteredAddressExists = apperror.New(apperror.ErrConflict, "the company already has a registered address")
+)
+
+// validate trims the fields of an address, upper-cases its country and reports every violation
+func (a *Address) validate() error {
+	violations := &apperror.ValidationError{}
+
+	if a.Kind != AddressRegistered && a.Kind != AddressTrading {
+		violations.Add("kind", apperror.CodeInvalidValue, "kind must be registered or trading")
+	}
+
+	fields := []struct {
+		name     string
+		value    *string
+		max      int
+		required bool
+	}{
+		{"line1", &a.Line1, 200, true},
+		{"line2", &a.Line2, 200, false},
+		{"city", &a.City, 100, true},
+		{"region", &a.Region, 100, false},
+		{"postal_code", &a.PostalCode, 20, false},
+	}
+	for _, field := range fields {
+		*field.value = strings.TrimSpace(*field.value)
+		if *field.value == "" && field.required {
+			violations.Add(field.name, apperror.CodeRequired, field.name+" is required")
+		} else if utf8.RuneCountInString(*field.value) > field.max {
+			violations.Add(field.name, apperror.CodeTooLong, fmt.Sprintf("%s must be up to %d characters", field.name, field.max))
+		}
+	}
+
+	a.Country = strings.ToUpper(strings.TrimSpace(a.Country))
+	if a.Country == "" {
+		violations.Add("country", apperror.CodeRequired, "country is required")
+	} else if !jurisdiction.IsCountry(a.Country) {
+		violations.Add("country", apperror.CodeInvalidValue, "country must be an ISO 3166-1 alpha-2 country code")
+	}
+
+	return violations.Err()
+}
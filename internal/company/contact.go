@@ -0,0 +1,92 @@
+package company
+
+import (
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"xm-microservice/pkg/apperror"
+
+	"github.com/google/uuid"
+)
+
+// Contact is a named person to reach at a company
+type Contact struct {
+	ID        uuid.UUID `json:"id"`
+	CompanyID uuid.UUID `json:"company_id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	Phone     string    `json:"phone,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+var (
+	ErrContactNotFound    = apperror.New(apperror.ErrNotFound, "contact not found")
+	ErrContactEmailExists = apperror.New(apperror.ErrConflict, "the company already has a contact with this email")
+)
+
+// validate normalizes the email and phone number of a contact and reports every violation.
+// A contact needs at least one of them.
+func (c *Contact) validate() error {
+	violations := &apperror.ValidationError{}
+
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		violations.Add("name", apperror.CodeRequired, "name is required")
+	} else if utf8.RuneCountInString(c.Name) > 100 {
+		violations.Add("name", apperror.CodeTooLong, "name must be up to 100 characters")
+	}
+	c.Role = strings.TrimSpace(c.Role)
+	if utf8.RuneCountInString(c.Role) > 100 {
+		violations.Add("role", apperror.CodeTooLong, "role must be up to 100 characters")
+	}
+
+	if email, ok := normalizeEmail(c.Email); ok {
+		c.Email = email
+	} else {
+		violations.Add("email", apperror.CodeInvalidValue, "email must be a plain email address such as jane@example.com")
+	}
+	if phone, ok := normalizePhone(c.Phone); ok {
+		c.Phone = phone
+	} else {
+		violations.Add("phone", apperror.CodeInvalidValue, "phone must be an international number such as +44 20 7946 0000")
+	}
+	if c.Email == "" && c.Phone == "" {
+		violations.Add("email", apperror.CodeRequired, "an email or phone number is required")
+	}
+
+	return violations.Err()
+}
+
+// normalizeEmail lower-cases a bare email address, reporting false for anything else such as a display name
+func normalizeEmail(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", true
+	}
+	address, err := mail.ParseAddress(value)
+	if err != nil || address.Address != value || address.Name != "" || len(value) > 254 {
+		return "", false
+	}
+	return strings.ToLower(value), true
+}
+
+// normalizePhone converts an international phone number to E.164, dropping the spaces, dots, hyphens and
+// parentheses people write in it and accepting 00 for the leading +
+func normalizePhone(value string) (string, bool) {
+	value = strings.NewReplacer(" ", "", ".", "", "-", "", "(", "", ")", "").Replace(strings.TrimSpace(value))
+	if value == "" {
+		return "", true
+	}
+	if strings.HasPrefix(value, "00") {
+		value = "+" + value[2:]
+	}
+	digits, ok := strings.CutPrefix(value, "+")
+	if !ok || len(digits) < 8 || len(digits) > 15 || digits[0] == '0' || strings.Trim(digits, "0123456789") != "" {
+		return "", false
+	}
+	return value, true
+}
@@ -0,0 +1,79 @@
+package company
+
+import (
+	"strings"
+	"unicode"
+
+	"xm-microservice/pkg/apperror"
+
+	"github.com/google/uuid"
+)
+
+// DuplicateAction decides what happens when a new company resembles existing ones
+type DuplicateAction string
+
+const (
+	DuplicateWarn   DuplicateAction = "warn"
+	DuplicateReject DuplicateAction = "reject"
+)
+
+// maxDuplicateCandidates caps how many similar companies are reported
+const maxDuplicateCandidates = 5
+
+// DuplicateCheck configures the near-duplicate detection on company creation
+type DuplicateCheck struct {
+	Threshold float64
+	Action    DuplicateAction
+}
+
+// DuplicateCandidate is an existing company whose name resembles the name of a new one
+type DuplicateCandidate struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	Similarity float64   `json:"similarity"`
+}
+
+// ErrPossibleDuplicate rejects a company whose name resembles existing companies
+var ErrPossibleDuplicate = apperror.New(apperror.ErrConflict, "company name resembles existing companies; retry with force=true to create it anyway")
+
+// DuplicateError carries the existing companies that a rejected company resembles
+type DuplicateError struct {
+	Candidates []DuplicateCandidate
+}
+
+// Error returns the message of ErrPossibleDuplicate
+func (e *DuplicateError) Error() string {
+	return ErrPossibleDuplicate.Error()
+}
+
+// Unwrap exposes ErrPossibleDuplicate to errors.Is and errors.As
+func (e *DuplicateError) Unwrap() error {
+	return ErrPossibleDuplicate
+}
+
+// ProblemExtensions lists the candidates in the problem details sent to the client
+func (e *DuplicateError) ProblemExtensions() map[string]interface{} {
+	return map[string]interface{}{"candidates": e.Candidates}
+}
+
+// legalSuffixes are trailing words of company names that do not tell companies apart.
+// Migration 009 repeats this list to backfill existing companies.
+var legalSuffixes = map[string]bool{
+	"ltd": true, "limited": true, "inc": true, "incorporated": true, "llc": true, "llp": true, "lp": true,
+	"corp": true, "corporation": true, "co": true, "company": true, "plc": true, "gmbh": true, "ag": true,
+	"sa": true, "sas": true, "sarl": true, "srl": true, "spa": true, "bv": true, "nv": true, "ab": true,
+	"oy": true, "pty": true, "pte": true,
+}
+
+// normalizeName reduces a company name to the form compared for near-duplicates: lower case,
+// apostrophes dropped, other punctuation collapsed to single spaces and trailing legal suffixes removed
+func normalizeName(name string) string {
+	name = strings.NewReplacer("'", "", "’", "").Replace(strings.ToLower(name))
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for len(words) > 1 && legalSuffixes[words[len(words)-1]] {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
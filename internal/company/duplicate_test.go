@@ -0,0 +1,108 @@
+package company
+
+import (
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// migration009 backfills normalized_name with the SQL that normalizeName must agree with
+const migration009 = "../database/migrations/009_add_company_normalized_name.up.sql"
+
+// sqlNormalizer applies the regular expressions of migration 009 the way PostgreSQL does,
+// with the POSIX class [:alnum:] spelled as the Unicode classes it covers in a UTF-8 database
+type sqlNormalizer struct {
+	apostrophes *regexp.Regexp
+	separators  *regexp.Regexp
+	suffixes    *regexp.Regexp
+}
+
+func loadSQLNormalizer(t *testing.T) (*sqlNormalizer, []string) {
+	t.Helper()
+	data, err := os.ReadFile(migration009)
+	if err != nil {
+		t.Fatalf("cannot read migration 009: %v", err)
+	}
+	sql := string(data)
+	for _, pattern := range []string{`'[''’]'`, `'[^[:alnum:]]+'`} {
+		if !strings.Contains(sql, pattern) {
+			t.Fatalf("migration 009 no longer uses the pattern %s, update this test", pattern)
+		}
+	}
+	match := regexp.MustCompile(`'(\( \(((?:[a-z]+\|)*[a-z]+)\)\)\+\$)'`).FindStringSubmatch(sql)
+	if match == nil {
+		t.Fatal("migration 009 has no legal suffix pattern")
+	}
+
+	return &sqlNormalizer{
+		apostrophes: regexp.MustCompile(`['’]`),
+		separators:  regexp.MustCompile(`[^\p{L}\p{Nd}]+`),
+		suffixes:    regexp.MustCompile(match[1]),
+	}, strings.Split(match[2], "|")
+}
+
+// normalize mirrors regexp_replace(btrim(regexp_replace(regexp_replace(lower(name), ...))), ...)
+func (n *sqlNormalizer) normalize(name string) string {
+	name = n.apostrophes.ReplaceAllString(strings.ToLower(name), "")
+	name = strings.Trim(n.separators.ReplaceAllString(name, " "), " ")
+	return n.suffixes.ReplaceAllString(name, "")
+}
+
+func TestLegalSuffixesMatchMigration(t *testing.T) {
+	_, suffixes := loadSQLNormalizer(t)
+	var listed []string
+	for suffix := range legalSuffixes {
+		listed = append(listed, suffix)
+	}
+	slices.Sort(listed)
+	slices.Sort(suffixes)
+	if !slices.Equal(listed, suffixes) {
+		t.Errorf("legalSuffixes = %v, migration 009 strips %v", listed, suffixes)
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	sql, _ := loadSQLNormalizer(t)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Acme Ltd", want: "acme"},
+		{name: "ACME Ltd.", want: "acme"},
+		{name: "Acme, Inc.", want: "acme"},
+		{name: "  Acme   Holdings  ", want: "acme holdings"},
+		{name: "Acme Co. Ltd", want: "acme"},
+		{name: "Acme Pty Ltd", want: "acme"},
+		{name: "McDonald's Corporation", want: "mcdonalds"},
+		{name: "O’Reilly Media, Inc.", want: "oreilly media"},
+		{name: "AT&T Inc", want: "at t"},
+		{name: "3M Company", want: "3m"},
+		{name: "Société Générale S.A.", want: "société générale s a"},
+		{name: "Siemens AG", want: "siemens"},
+		{name: "Müller GmbH & Co. KG", want: "müller gmbh co kg"},
+		{name: "Ltd Holdings", want: "ltd holdings"},
+		{name: "Company Inc", want: "company"},
+		{name: "Ltd", want: "ltd"},
+		{name: "Co Ltd", want: "co"},
+		{name: "The Limited", want: "the"},
+		{name: "Incorporated Ltd Inc", want: "incorporated"},
+		{name: "Acmeltd", want: "acmeltd"},
+		{name: "株式会社 トヨタ", want: "株式会社 トヨタ"},
+		{name: "!!!", want: ""},
+		{name: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeName(tt.name)
+			if got != tt.want {
+				t.Errorf("normalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+			if backfilled := sql.normalize(tt.name); backfilled != got {
+				t.Errorf("migration 009 normalises %q to %q, normalizeName to %q", tt.name, backfilled, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package company
+
+import (
+	"database/sql"
+	"errors"
+
+	"xm-microservice/internal/database"
+	"xm-microservice/pkg/apperror"
+)
+
+var (
+	ErrNotFound                   = apperror.New(apperror.ErrNotFound, "company not found")
+	ErrNameConflict               = apperror.New(apperror.ErrConflict, "company name already exists")
+	ErrRegistrationNumberConflict = apperror.New(apperror.ErrConflict, "a company with this registration number already exists in the jurisdiction")
+	ErrTaxIDConflict              = apperror.New(apperror.ErrConflict, "a company with this tax identifier already exists in the jurisdiction")
+	ErrLEIConflict                = apperror.New(apperror.ErrConflict, "a company with this LEI already exists")
+	ErrUnavailable                = apperror.New(apperror.ErrUnavailable, "company storage is unavailable")
+	ErrInvalidRef                 = apperror.New(apperror.ErrValidation, "company type or parent company no longer exists")
+
+	ErrImportJobNotFound = apperror.New(apperror.ErrNotFound, "import job not found")
+)
+
+// mapError translates database errors into company domain errors
+func mapError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case database.IsUniqueViolation(err):
+		return uniqueError(err)
+	case database.IsForeignKeyViolation(err):
+		return ErrInvalidRef.WithCause(err)
+	case database.IsUnavailable(err):
+		return ErrUnavailable.WithCause(err)
+	default:
+		return err
+	}
+}
+
+// uniqueError tells which unique company field err conflicts on
+func uniqueError(err error) error {
+	switch database.Constraint(err) {
+	case "companies_registration_number_key":
+		return ErrRegistrationNumberConflict.WithCause(err)
+	case "companies_tax_id_key":
+		return ErrTaxIDConflict.WithCause(err)
+	case "companies_lei_key":
+		return ErrLEIConflict.WithCause(err)
+	case "company_addresses_registered_key":
+		return ErrRegisteredAddressExists.WithCause(err)
+	case "company_contacts_email_key":
+		return ErrContactEmailExists.WithCause(err)
+	default:
+		return ErrNameConflict.WithCause(err)
+	}
+}
+
+// mapAddressError translates database errors for addresses into company domain errors
+func mapAddressError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAddressNotFound
+	}
+	return mapError(err)
+}
+
+// mapContactError translates database errors for contacts into company domain errors
+func mapContactError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrContactNotFound
+	}
+	return mapError(err)
+}
+
+// mapCuratedTagError translates database errors for curated tags into company domain errors
+func mapCuratedTagError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCuratedTagNotFound
+	}
+	return mapError(err)
+}
+
+// mapJobError translates database errors for import jobs into company domain errors
+func mapJobError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrImportJobNotFound
+	}
+	return mapError(err)
+}
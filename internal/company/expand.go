@@ -0,0 +1,38 @@
+package company
+
+import (
+	"strings"
+
+	"xm-microservice/pkg/apperror"
+)
+
+// Expand names the sub-resources to include with a company
+type Expand struct {
+	Addresses bool
+	Contacts  bool
+}
+
+// ParseExpand reads a comma-separated list of sub-resources such as addresses,contacts
+func ParseExpand(value string) (Expand, error) {
+	var expand Expand
+	violations := &apperror.ValidationError{}
+	for _, name := range strings.Split(value, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "addresses":
+			expand.Addresses = true
+		case "contacts":
+			expand.Contacts = true
+		default:
+			violations.Add("expand", apperror.CodeInvalidValue, "expand may only list addresses and contacts")
+		}
+	}
+	return expand, violations.Err()
+}
+
+// CompanyDetails is a company with the sub-resources that were expanded; the others are left out
+type CompanyDetails struct {
+	*Company
+	Addresses *[]Address `json:"addresses,omitempty"`
+	Contacts  *[]Contact `json:"contacts,omitempty"`
+}
@@ -0,0 +1,268 @@
+package company
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"xm-microservice/pkg/apperror"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Export formats
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// parquetRowGroupSize bounds how many rows the Parquet writer buffers before flushing a row group
+const parquetRowGroupSize = 10000
+
+// exportFormats maps each export format to its content type
+var exportFormats = map[string]string{
+	FormatCSV:     "text/csv",
+	FormatNDJSON:  "application/x-ndjson",
+	FormatParquet: "application/vnd.apache.parquet",
+}
+
+// exportColumn describes one column of an export and how to read it from a company
+type exportColumn struct {
+	name  string
+	node  parquet.Node
+	value func(c *Company) interface{}
+}
+
+// exportColumns lists the columns an export may contain, in their default order
+var exportColumns = []exportColumn{
+	{"id", parquet.String(), func(c *Company) interface{} { return c.ID.String() }},
+	{"name", parquet.String(), func(c *Company) interface{} { return c.Name }},
+	{"description", parquet.String(), func(c *Company) interface{} { return c.Description }},
+	{"amount_of_employees", parquet.Int(64), func(c *Company) interface{} {
+		if c.AmountOfEmployees == nil {
+			return nil
+		}
+		return int64(*c.AmountOfEmployees)
+	}},
+	{"registered", parquet.Leaf(parquet.BooleanType), func(c *Company) interface{} {
+		if c.Registered == nil {
+			return nil
+		}
+		return *c.Registered
+	}},
+	{"status", parquet.String(), func(c *Company) interface{} { return string(c.Status) }},
+	{"type", parquet.String(), func(c *Company) interface{} { return string(c.Type) }},
+	{"parent_id", parquet.String(), func(c *Company) interface{} {
+		if c.ParentID == nil {
+			return nil
+		}
+		return c.ParentID.String()
+	}},
+	{"ownership_percentage", parquet.Leaf(parquet.DoubleType), func(c *Company) interface{} {
+		if c.OwnershipPercentage == nil {
+			return nil
+		}
+		return *c.OwnershipPercentage
+	}},
+	{"jurisdiction", parquet.String(), func(c *Company) interface{} { return c.Jurisdiction }},
+	{"registration_number", parquet.String(), func(c *Company) interface{} { return c.RegistrationNumber }},
+	{"tax_id", parquet.String(), func(c *Company) interface{} { return c.TaxID }},
+	{"lei", parquet.String(), func(c *Company) interface{} { return c.LEI }},
+	{"attributes", parquet.JSON(), func(c *Company) interface{} {
+		data, err := json.Marshal(c.Attributes)
+		if err != nil {
+			return nil
+		}
+		return json.RawMessage(data)
+	}},
+	{"tags", parquet.String(), func(c *Company) interface{} { return strings.Join(c.Tags, ",") }},
+}
+
+// parseExportColumns selects export columns from a comma-separated list, or all columns when empty
+func parseExportColumns(value string) ([]exportColumn, error) {
+	if strings.TrimSpace(value) == "" {
+		return exportColumns, nil
+	}
+
+	var columns []exportColumn
+	seen := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if seen[name] {
+			continue
+		}
+		column, ok := findExportColumn(name)
+		if !ok {
+			violations := &apperror.ValidationError{}
+			violations.Add("columns", apperror.CodeInvalidValue, fmt.Sprintf("unknown column %q", name))
+			return nil, violations.Err()
+		}
+		seen[name] = true
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// findExportColumn looks up an export column by name
+func findExportColumn(name string) (exportColumn, bool) {
+	for _, column := range exportColumns {
+		if column.name == name {
+			return column, true
+		}
+	}
+	return exportColumn{}, false
+}
+
+// exportWriter encodes companies one at a time in an export format
+type exportWriter interface {
+	Write(c *Company) error
+	Flush() error
+	Close() error
+}
+
+// newExportWriter creates the writer for a format, which must be one of exportFormats
+func newExportWriter(format string, w io.Writer, columns []exportColumn) (exportWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVExporter(w, columns)
+	case FormatNDJSON:
+		return &ndjsonExporter{encoder: json.NewEncoder(w), columns: columns}, nil
+	case FormatParquet:
+		return newParquetExporter(w, columns), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+type csvExporter struct {
+	writer  *csv.Writer
+	columns []exportColumn
+	record  []string
+}
+
+// newCSVExporter writes the header row and returns a CSV export writer
+func newCSVExporter(w io.Writer, columns []exportColumn) (*csvExporter, error) {
+	e := &csvExporter{writer: csv.NewWriter(w), columns: columns, record: make([]string, len(columns))}
+	for i, column := range columns {
+		e.record[i] = column.name
+	}
+	return e, e.writer.Write(e.record)
+}
+
+func (e *csvExporter) Write(c *Company) error {
+	for i, column := range e.columns {
+		switch value := column.value(c).(type) {
+		case nil:
+			e.record[i] = ""
+		case string:
+			e.record[i] = value
+		case int64:
+			e.record[i] = strconv.FormatInt(value, 10)
+		case float64:
+			e.record[i] = strconv.FormatFloat(value, 'f', -1, 64)
+		case bool:
+			e.record[i] = strconv.FormatBool(value)
+		case json.RawMessage:
+			e.record[i] = string(value)
+		}
+	}
+	return e.writer.Write(e.record)
+}
+
+func (e *csvExporter) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvExporter) Close() error {
+	return e.Flush()
+}
+
+type ndjsonExporter struct {
+	encoder *json.Encoder
+	columns []exportColumn
+}
+
+func (e *ndjsonExporter) Write(c *Company) error {
+	object := make(map[string]interface{}, len(e.columns))
+	for _, column := range e.columns {
+		object[column.name] = column.value(c)
+	}
+	return e.encoder.Encode(object)
+}
+
+func (e *ndjsonExporter) Flush() error {
+	return nil
+}
+
+func (e *ndjsonExporter) Close() error {
+	return nil
+}
+
+type parquetExporter struct {
+	writer  *parquet.Writer
+	columns []exportColumn
+	// order maps each leaf column of the schema, which sorts its fields by name, to the selected column
+	order []int
+	row   parquet.Row
+	rows  int
+}
+
+// newParquetExporter builds a schema of optional columns for the selected columns
+func newParquetExporter(w io.Writer, columns []exportColumn) *parquetExporter {
+	group := parquet.Group{}
+	for _, column := range columns {
+		group[column.name] = parquet.Optional(column.node)
+	}
+	schema := parquet.NewSchema("company", group)
+
+	e := &parquetExporter{
+		writer:  parquet.NewWriter(w, schema, parquet.MaxRowsPerRowGroup(parquetRowGroupSize)),
+		columns: columns,
+	}
+	for _, field := range schema.Fields() {
+		for i, column := range columns {
+			if column.name == field.Name() {
+				e.order = append(e.order, i)
+			}
+		}
+	}
+	return e
+}
+
+func (e *parquetExporter) Write(c *Company) error {
+	e.row = e.row[:0]
+	for leaf, i := range e.order {
+		value := e.columns[i].value(c)
+		if value == nil {
+			e.row = append(e.row, parquet.NullValue().Level(0, 0, leaf))
+			continue
+		}
+		if raw, ok := value.(json.RawMessage); ok {
+			value = []byte(raw)
+		}
+		e.row = append(e.row, parquet.ValueOf(value).Level(0, 1, leaf))
+	}
+	if _, err := e.writer.WriteRows([]parquet.Row{e.row}); err != nil {
+		return err
+	}
+
+	// Flushing full row groups keeps memory constant however many rows are exported
+	e.rows++
+	if e.rows%parquetRowGroupSize == 0 {
+		return e.writer.Flush()
+	}
+	return nil
+}
+
+func (e *parquetExporter) Flush() error {
+	return nil
+}
+
+func (e *parquetExporter) Close() error {
+	return e.writer.Close()
+}
@@ -0,0 +1,272 @@
+package company
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"xm-microservice/pkg/apperror"
+
+	"github.com/google/uuid"
+	"github.com/parquet-go/parquet-go"
+)
+
+// exportFixtures returns a company with every field set and one with only the required ones
+func exportFixtures() []Company {
+	employees, registered, ownership := 150, true, 51.5
+	parentID := uuid.MustParse("7b2f7b0e-1f5a-4f0b-9a59-6f0d5d1f2c3a")
+	return []Company{
+		{
+			ID:                  uuid.MustParse("e3f1a8b2-9d14-4c2b-8c3f-1a2f3d4e5678"),
+			Name:                "Acme, Inc.",
+			Description:         "Anvils \"and\" rockets",
+			AmountOfEmployees:   &employees,
+			Registered:          &registered,
+			Status:              StatusRegistered,
+			Type:                "Corporation",
+			ParentID:            &parentID,
+			OwnershipPercentage: &ownership,
+			Jurisdiction:        "GB",
+			RegistrationNumber:  "01234567",
+			LEI:                 "5493001KJTIIGC8Y1R12",
+			Attributes:          Attributes{"segment": "retail"},
+			Tags:                Tags{"kyc-pending", "vip"},
+		},
+		{
+			ID:         uuid.MustParse("0c6f1b7e-2a3d-4e5f-8a9b-0c1d2e3f4a5b"),
+			Name:       "Bare",
+			Status:     StatusDraft,
+			Type:       "NonProfit",
+			Attributes: Attributes{},
+			Tags:       Tags{},
+		},
+	}
+}
+
+// export writes the companies with the named format and columns
+func export(t *testing.T, format, columnList string, companies []Company) []byte {
+	t.Helper()
+	columns, err := parseExportColumns(columnList)
+	if err != nil {
+		t.Fatalf("parseExportColumns(%q) error = %v", columnList, err)
+	}
+	var buf bytes.Buffer
+	writer, err := newExportWriter(format, &buf, columns)
+	if err != nil {
+		t.Fatalf("newExportWriter(%q) error = %v", format, err)
+	}
+	for i := range companies {
+		if err := writer.Write(&companies[i]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseExportColumns(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "", want: []string{"id", "name", "description", "amount_of_employees", "registered", "status", "type", "parent_id",
+			"ownership_percentage", "jurisdiction", "registration_number", "tax_id", "lei", "attributes", "tags"}},
+		{value: "name,id", want: []string{"name", "id"}},
+		{value: " tags , name ,tags", want: []string{"tags", "name"}},
+		{value: "name,password_hash", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			columns, err := parseExportColumns(tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, apperror.ErrValidation) {
+					t.Errorf("parseExportColumns(%q) error = %v, want a validation error", tt.value, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseExportColumns(%q) error = %v", tt.value, err)
+			}
+			var names []string
+			for _, column := range columns {
+				names = append(names, column.name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("parseExportColumns(%q) = %v, want %v", tt.value, names, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	tests := []struct {
+		columns string
+		want    [][]string
+	}{
+		{
+			columns: "name,amount_of_employees,registered,ownership_percentage,attributes,tags",
+			want: [][]string{
+				{"name", "amount_of_employees", "registered", "ownership_percentage", "attributes", "tags"},
+				{"Acme, Inc.", "150", "true", "51.5", `{"segment":"retail"}`, "kyc-pending,vip"},
+				{"Bare", "", "", "", "{}", ""},
+			},
+		},
+		{
+			columns: "lei,id",
+			want: [][]string{
+				{"lei", "id"},
+				{"5493001KJTIIGC8Y1R12", "e3f1a8b2-9d14-4c2b-8c3f-1a2f3d4e5678"},
+				{"", "0c6f1b7e-2a3d-4e5f-8a9b-0c1d2e3f4a5b"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.columns, func(t *testing.T) {
+			records, err := csv.NewReader(bytes.NewReader(export(t, FormatCSV, tt.columns, exportFixtures()))).ReadAll()
+			if err != nil {
+				t.Fatalf("exported CSV does not parse: %v", err)
+			}
+			if !reflect.DeepEqual(records, tt.want) {
+				t.Errorf("exported CSV = %q, want %q", records, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	data := export(t, FormatNDJSON, "id,description,amount_of_employees,parent_id,attributes", exportFixtures())
+
+	want := []map[string]interface{}{
+		{
+			"id":                  "e3f1a8b2-9d14-4c2b-8c3f-1a2f3d4e5678",
+			"description":         "Anvils \"and\" rockets",
+			"amount_of_employees": float64(150),
+			"parent_id":           "7b2f7b0e-1f5a-4f0b-9a59-6f0d5d1f2c3a",
+			"attributes":          map[string]interface{}{"segment": "retail"},
+		},
+		{
+			"id":                  "0c6f1b7e-2a3d-4e5f-8a9b-0c1d2e3f4a5b",
+			"description":         "",
+			"amount_of_employees": nil,
+			"parent_id":           nil,
+			"attributes":          map[string]interface{}{},
+		},
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var got []map[string]interface{}
+	for scanner.Scan() {
+		var object map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &object); err != nil {
+			t.Fatalf("line %d is not a JSON object: %v", len(got)+1, err)
+		}
+		got = append(got, object)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exported NDJSON = %v, want %v", got, want)
+	}
+}
+
+// parquetCompany is the layout of a Parquet export with the columns used in TestExportParquet
+type parquetCompany struct {
+	ID                  *string  `parquet:"id,optional"`
+	Name                *string  `parquet:"name,optional"`
+	AmountOfEmployees   *int64   `parquet:"amount_of_employees,optional"`
+	Registered          *bool    `parquet:"registered,optional"`
+	OwnershipPercentage *float64 `parquet:"ownership_percentage,optional"`
+	Tags                *string  `parquet:"tags,optional"`
+}
+
+func TestExportParquet(t *testing.T) {
+	data := export(t, FormatParquet, "tags,name,id,ownership_percentage,registered,amount_of_employees", exportFixtures())
+
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("exported Parquet does not open: %v", err)
+	}
+	var columns []string
+	for _, field := range file.Schema().Fields() {
+		columns = append(columns, field.Name())
+	}
+	if want := []string{"amount_of_employees", "id", "name", "ownership_percentage", "registered", "tags"}; !reflect.DeepEqual(columns, want) {
+		t.Errorf("Parquet columns = %v, want %v", columns, want)
+	}
+
+	rows, err := parquet.Read[parquetCompany](bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("cannot read the exported rows: %v", err)
+	}
+	str := func(s string) *string { return &s }
+	employees, registered, ownership := int64(150), true, 51.5
+	want := []parquetCompany{
+		{ID: str("e3f1a8b2-9d14-4c2b-8c3f-1a2f3d4e5678"), Name: str("Acme, Inc."), AmountOfEmployees: &employees,
+			Registered: &registered, OwnershipPercentage: &ownership, Tags: str("kyc-pending,vip")},
+		{ID: str("0c6f1b7e-2a3d-4e5f-8a9b-0c1d2e3f4a5b"), Name: str("Bare"), Tags: str("")},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("exported Parquet rows = %+v, want %+v", rows, want)
+	}
+}
+
+// countingWriter records how many bytes have been written through it
+type countingWriter struct {
+	w       io.Writer
+	written int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.written += len(p)
+	return c.w.Write(p)
+}
+
+func TestExportParquetFlushesFullRowGroups(t *testing.T) {
+	columns, _ := parseExportColumns("id,name")
+	var buf bytes.Buffer
+	out := &countingWriter{w: &buf}
+	writer := newParquetExporter(out, columns)
+
+	company := exportFixtures()[1]
+	total := 2*parquetRowGroupSize + 5
+	var beforeFirstGroup, afterFirstGroup int
+	for i := 0; i < total; i++ {
+		if err := writer.Write(&company); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		switch i {
+		case parquetRowGroupSize - 2:
+			beforeFirstGroup = out.written
+		case parquetRowGroupSize - 1:
+			afterFirstGroup = out.written
+		}
+	}
+	// Rows stay buffered only until their row group is full, so the output grows while exporting
+	if afterFirstGroup <= beforeFirstGroup {
+		t.Fatalf("output was %d bytes before and %d bytes after the first row group filled up, want it flushed", beforeFirstGroup, afterFirstGroup)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("exported Parquet does not open: %v", err)
+	}
+	if file.NumRows() != int64(total) {
+		t.Errorf("exported %d rows, want %d", file.NumRows(), total)
+	}
+	var sizes []int64
+	for _, group := range file.RowGroups() {
+		sizes = append(sizes, group.NumRows())
+	}
+	if want := []int64{parquetRowGroupSize, parquetRowGroupSize, 5}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("row group sizes = %v, want %v", sizes, want)
+	}
+}
@@ -0,0 +1,219 @@
+package company
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"xm-microservice/pkg/apperror"
+
+	"github.com/google/uuid"
+)
+
+// Filter narrows the companies returned by the listing and export endpoints
+type Filter struct {
+	Name         string
+	Type         CompanyType
+	Registered   *bool
+	Status       CompanyStatus
+	MinEmployees *int
+	MaxEmployees *int
+	// Attributes maps top-level attribute names to the value they must have
+	Attributes map[string]string
+	// Tags selects the companies with any or, following TagMatch, all of the tags
+	Tags     Tags
+	TagMatch TagMatch
+}
+
+// attributeFilterPrefix starts the query parameters that filter on an attribute, as in attributes.sector=K64
+const attributeFilterPrefix = "attributes."
+
+// maxAttributeFilters caps how many attributes one request can filter on
+const maxAttributeFilters = 10
+
+// ParseFilter reads a Filter from query parameters and reports every invalid one
+func ParseFilter(query url.Values) (Filter, error) {
+	violations := &apperror.ValidationError{}
+	filter := Filter{
+		Name:   strings.TrimSpace(query.Get("name")),
+		Type:   CompanyType(query.Get("type")),
+		Status: CompanyStatus(query.Get("status")),
+	}
+
+	if filter.Status != "" && !filter.Status.valid() {
+		violations.Add("status", apperror.CodeInvalidValue, "invalid company status")
+	}
+
+	if value := query.Get("registered"); value != "" {
+		registered, err := strconv.ParseBool(value)
+		if err != nil {
+			violations.Add("registered", apperror.CodeInvalidValue, "registered must be true or false")
+		} else {
+			filter.Registered = &registered
+		}
+	}
+
+	filter.MinEmployees = parseCount(query, "min_employees", violations)
+	filter.MaxEmployees = parseCount(query, "max_employees", violations)
+	if filter.MinEmployees != nil && filter.MaxEmployees != nil && *filter.MinEmployees > *filter.MaxEmployees {
+		violations.Add("max_employees", apperror.CodeOutOfRange, "max_employees must not be less than min_employees")
+	}
+
+	filter.Attributes = parseAttributeFilters(query, violations)
+	filter.Tags, filter.TagMatch = parseTagFilter(query, violations)
+
+	return filter, violations.Err()
+}
+
+// parseAttributeFilters reads the attributes.<name> query parameters
+func parseAttributeFilters(query url.Values, violations *apperror.ValidationError) map[string]string {
+	var attributes map[string]string
+	for param, values := range query {
+		name, ok := strings.CutPrefix(param, attributeFilterPrefix)
+		if !ok {
+			continue
+		}
+		if name == "" || len(name) > 100 {
+			violations.Add(param, apperror.CodeInvalidValue, "attribute filters must name an attribute of up to 100 characters")
+			continue
+		}
+		if attributes == nil {
+			attributes = map[string]string{}
+		}
+		attributes[name] = values[0]
+	}
+	if len(attributes) > maxAttributeFilters {
+		violations.Add("attributes", apperror.CodeOutOfRange, fmt.Sprintf("at most %d attributes can be filtered on", maxAttributeFilters))
+	}
+	return attributes
+}
+
+// parseTagFilter reads the tags to filter on from the tag query parameters, each of which may list
+// several comma-separated tags, and whether tag_match selects companies with any or all of them
+func parseTagFilter(query url.Values, violations *apperror.ValidationError) (Tags, TagMatch) {
+	var tags Tags
+	for _, value := range query["tag"] {
+		for _, name := range strings.Split(value, ",") {
+			tag, ok := normalizeTag(name)
+			if !ok {
+				violations.Add("tag", apperror.CodeInvalidValue, fmt.Sprintf("invalid tag %q", name))
+				continue
+			}
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	tags = slices.Compact(tags)
+	if len(tags) > maxTags {
+		violations.Add("tag", apperror.CodeOutOfRange, fmt.Sprintf("at most %d tags can be filtered on", maxTags))
+	}
+
+	match := TagMatch(query.Get("tag_match"))
+	switch match {
+	case "":
+		match = TagMatchAll
+	case TagMatchAny, TagMatchAll:
+	default:
+		violations.Add("tag_match", apperror.CodeInvalidValue, "tag_match must be any or all")
+	}
+	return tags, match
+}
+
+// parseCount reads an optional non-negative integer query parameter
+func parseCount(query url.Values, name string, violations *apperror.ValidationError) *int {
+	value := query.Get(name)
+	if value == "" {
+		return nil
+	}
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		violations.Add(name, apperror.CodeInvalidValue, name+" must be a non-negative whole number")
+		return nil
+	}
+	return &count
+}
+
+// where builds the SQL condition and arguments selecting the live companies of a tenant that match the filter
+func (f Filter) where(tenantID uuid.UUID) (string, []interface{}) {
+	conditions := []string{"tenant_id = $1", "merged_into IS NULL"}
+	args := []interface{}{tenantID}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.Name != "" {
+		add("name ILIKE '%%' || $%d || '%%'", escapeLike(f.Name))
+	}
+	if f.Type != "" {
+		add("type = $%d", f.Type)
+	}
+	if f.Registered != nil {
+		add("registered = $%d", *f.Registered)
+	}
+	if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if f.MinEmployees != nil {
+		add("amount_of_employees >= $%d", *f.MinEmployees)
+	}
+	if f.MaxEmployees != nil {
+		add("amount_of_employees <= $%d", *f.MaxEmployees)
+	}
+	for _, name := range sortedKeys(f.Attributes) {
+		matches := attributeMatches(name, f.Attributes[name])
+		alternatives := make([]string, len(matches))
+		for i, match := range matches {
+			args = append(args, match)
+			alternatives[i] = fmt.Sprintf("attributes @> $%d", len(args))
+		}
+		conditions = append(conditions, "("+strings.Join(alternatives, " OR ")+")")
+	}
+	if len(f.Tags) > 0 {
+		if f.TagMatch == TagMatchAny {
+			add("tags && $%d", f.Tags)
+		} else {
+			add("tags @> $%d", f.Tags)
+		}
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// attributeMatches returns the JSON objects that an attribute filter accepts as contained in the attributes.
+// The value always matches as a string and, when it is a JSON number or boolean, also as that value.
+func attributeMatches(name, value string) []string {
+	values := []interface{}{value}
+	var typed interface{}
+	if err := json.Unmarshal([]byte(value), &typed); err == nil {
+		switch typed.(type) {
+		case float64, bool:
+			values = append(values, json.RawMessage(value))
+		}
+	}
+
+	matches := make([]string, 0, len(values))
+	for _, v := range values {
+		data, _ := json.Marshal(map[string]interface{}{name: v})
+		matches = append(matches, string(data))
+	}
+	return matches
+}
+
+// sortedKeys returns the keys of m in order, so that the same filter always builds the same query
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// escapeLike escapes the wildcard characters of a LIKE pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
@@ -1,11 +1,22 @@
 package company
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"xm-microservice/internal/auth"
 	"xm-microservice/internal/event"
+	"xm-microservice/internal/user"
+	"xm-microservice/pkg/apperror"
 	"xm-microservice/pkg/logger"
 	"xm-microservice/pkg/utils"
 
@@ -13,17 +24,40 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// Page sizes of the company listing
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// exportFlushRows is how many exported rows are buffered before they are flushed to the client
+const exportFlushRows = 1000
+
+// SnapshotHeader carries the time of the database snapshot an export was read from
+const SnapshotHeader = "X-Snapshot-Timestamp"
+
+// MaxImportBytes caps the size of an uploaded import file
+const MaxImportBytes = 32 << 20
+
+// ImportLimits bounds the size of imports and decides which ones run in the background
+type ImportLimits struct {
+	MaxRows  int
+	SyncRows int
+}
+
 type Handler struct {
 	service  Service
 	producer *event.Producer
+	limits   ImportLimits
 	logger   *logger.Logger
 }
 
-// NewHandler initializes the company handler with the service, Kafka producer, and logger
-func NewHandler(service Service, producer *event.Producer, logger *logger.Logger) *Handler {
+// NewHandler initializes the company handler with the service, Kafka producer, import limits, and logger
+func NewHandler(service Service, producer *event.Producer, limits ImportLimits, logger *logger.Logger) *Handler {
 	return &Handler{
 		service:  service,
 		producer: producer,
+		limits:   limits,
 		logger:   logger,
 	}
 }
@@ -34,32 +68,60 @@ type Event struct {
 	Company interface{} `json:"company"`
 }
 
-// CreateCompany handles the creation of a new company
+// createdCompany is the response to a company creation, listing similar companies when duplicates only warn
+type createdCompany struct {
+	*Company
+	PossibleDuplicates []DuplicateCandidate `json:"possible_duplicates,omitempty"`
+}
+
+// CreateCompany handles the creation of a new company, checking for near-duplicates unless force=true is passed
 func (h *Handler) CreateCompany(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("CreateCompany handler invoked")
 
+	force := false
+	if value := r.URL.Query().Get("force"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			violations := &apperror.ValidationError{}
+			violations.Add("force", apperror.CodeInvalidValue, "force must be true or false")
+			utils.WriteError(w, r, violations)
+			return
+		}
+		force = parsed
+	}
+
 	var company Company
 	if err := json.NewDecoder(r.Body).Decode(&company); err != nil {
 		h.logger.Error(err, "Invalid input while decoding company data")
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid input")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
 		return
 	}
 
-	if err := h.service.CreateCompany(&company); err != nil {
+	tenantID := auth.TenantFromContext(r.Context())
+	candidates, err := h.service.CreateCompany(r.Context(), tenantID, &company, force)
+	if err != nil {
 		h.logger.Error(err, "Failed to create company")
-
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			utils.ErrorResponse(w, http.StatusBadRequest, "Company name already exists")
-			return
-		}
-
-		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	h.produceEvent("create", company)
+	h.produceEvent(r.Context(), tenantID, "create", company)
 	h.logger.Info("Company created successfully with ID: %s", company.ID)
-	utils.JSONResponse(w, http.StatusCreated, company)
+	utils.JSONResponse(w, http.StatusCreated, createdCompany{Company: &company, PossibleDuplicates: candidates})
+}
+
+// updatedCompany is the event of a company update, listing the tags it added and removed, if any
+type updatedCompany struct {
+	*Company
+	TagChanges *TagChange `json:"tag_changes,omitempty"`
+}
+
+// tagChanges returns the change to report in an update event, or nil when the tags stayed the same
+func tagChanges(change *TagChange) *TagChange {
+	if change.empty() {
+		return nil
+	}
+	return change
 }
 
 // UpdateCompany handles updating an existing company
@@ -69,27 +131,28 @@ func (h *Handler) UpdateCompany(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
 		h.logger.Error(err, "Invalid UUID")
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid UUID")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid UUID")
 		return
 	}
 
 	var company Company
 	if err := json.NewDecoder(r.Body).Decode(&company); err != nil {
 		h.logger.Error(err, "Invalid input while decoding company data")
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid input")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
 		return
 	}
 
-	company.ID = id
-	if err := h.service.UpdateCompany(id, &company); err != nil {
+	tenantID := auth.TenantFromContext(r.Context())
+	updated, change, err := h.service.UpdateCompany(r.Context(), tenantID, id, &company)
+	if err != nil {
 		h.logger.Error(err, "Failed to update company")
-		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	h.produceEvent("update", company)
+	h.produceEvent(r.Context(), tenantID, "update", updatedCompany{Company: updated, TagChanges: tagChanges(change)})
 	h.logger.Info("Company updated successfully with ID: %s", id)
-	utils.JSONResponse(w, http.StatusOK, company)
+	utils.JSONResponse(w, http.StatusOK, updated)
 }
 
 // DeleteCompany handles deleting an existing company
@@ -99,21 +162,224 @@ func (h *Handler) DeleteCompany(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
 		h.logger.Error(err, "Invalid UUID")
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid UUID")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid UUID")
 		return
 	}
 
-	if err := h.service.DeleteCompany(id); err != nil {
+	tenantID := auth.TenantFromContext(r.Context())
+	if err := h.service.DeleteCompany(r.Context(), tenantID, id); err != nil {
 		h.logger.Error(err, "Failed to delete company")
-		utils.ErrorResponse(w, http.StatusInternalServerError, err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	h.produceEvent("delete", map[string]string{"id": id.String()})
+	h.produceEvent(r.Context(), tenantID, "delete", map[string]string{"id": id.String(), "tenant_id": tenantID.String()})
 	h.logger.Info("Company deleted successfully with ID: %s", id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// mergedCompany is the payload of a merged event: the surviving company and the company merged into it
+type mergedCompany struct {
+	*Company
+	MergedFrom uuid.UUID `json:"merged_from"`
+}
+
+// MergeCompanies folds the company named by source_id into the company of the path
+func (h *Handler) MergeCompanies(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("MergeCompanies handler invoked")
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.logger.Error(err, "Invalid UUID")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid UUID")
+		return
+	}
+
+	var request MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.logger.Error(err, "Invalid input while decoding merge request")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	tenantID := auth.TenantFromContext(r.Context())
+	company, changes, err := h.service.MergeCompanies(r.Context(), tenantID, id, request)
+	if err != nil {
+		h.logger.Error(err, "Failed to merge companies")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.produceEvent(r.Context(), tenantID, "merged", mergedCompany{Company: company, MergedFrom: request.SourceID})
+	for _, change := range changes {
+		h.produceEvent(r.Context(), tenantID, "hierarchy_changed", change)
+	}
+	h.logger.Info("Company %s merged into %s", request.SourceID, id)
+	utils.JSONResponse(w, http.StatusOK, company)
+}
+
+// statusChanged is the payload of a status_changed event: the company after the transition and the change itself
+type statusChanged struct {
+	*Company
+	Transition *StatusChange `json:"transition"`
+}
+
+// transitionRequest is the optional body of a status transition
+type transitionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// TransitionCompany moves a company to another status through the transition named in the path
+func (h *Handler) TransitionCompany(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("TransitionCompany handler invoked")
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		h.logger.Error(err, "Invalid UUID")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid UUID")
+		return
+	}
+
+	var request transitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		h.logger.Error(err, "Invalid input while decoding transition request")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	identity, _ := auth.IdentityFromContext(r.Context())
+	actor := Actor{ID: identity.UserID, Admin: user.Role(identity.Role).AtLeast(user.RoleAdmin)}
+	company, change, err := h.service.TransitionCompany(r.Context(), identity.TenantID, id, vars["transition"], strings.TrimSpace(request.Reason), actor)
+	if err != nil {
+		h.logger.Error(err, "Failed to transition company")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.produceEvent(r.Context(), identity.TenantID, "status_changed", statusChanged{Company: company, Transition: change})
+	h.logger.Info("Company %s moved from %s to %s", id, change.From, change.To)
+	utils.JSONResponse(w, http.StatusOK, company)
+}
+
+// SetParent makes the company named by parent_id the parent of the company of the path
+func (h *Handler) SetParent(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("SetParent handler invoked")
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.logger.Error(err, "Invalid UUID")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid UUID")
+		return
+	}
+
+	var request ParentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.logger.Error(err, "Invalid input while decoding parent request")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	tenantID := auth.TenantFromContext(r.Context())
+	company, change, err := h.service.SetParent(r.Context(), tenantID, id, request)
+	if err != nil {
+		h.logger.Error(err, "Failed to set company parent")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.produceEvent(r.Context(), tenantID, "hierarchy_changed", change)
+	h.logger.Info("Company %s now has parent %s", id, *request.ParentID)
+	utils.JSONResponse(w, http.StatusOK, company)
+}
+
+// RemoveParent detaches the company of the path from its parent
+func (h *Handler) RemoveParent(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("RemoveParent handler invoked")
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.logger.Error(err, "Invalid UUID")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid UUID")
+		return
+	}
+
+	tenantID := auth.TenantFromContext(r.Context())
+	company, change, err := h.service.RemoveParent(r.Context(), tenantID, id)
+	if err != nil {
+		h.logger.Error(err, "Failed to remove company parent")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	if change.PreviousParentID != nil {
+		h.produceEvent(r.Context(), tenantID, "hierarchy_changed", change)
+	}
+	h.logger.Info("Company %s detached from its parent", id)
+	utils.JSONResponse(w, http.StatusOK, company)
+}
+
+// ListSubsidiaries returns the direct subsidiaries of a company, or all of them with transitive=true
+func (h *Handler) ListSubsidiaries(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("ListSubsidiaries handler invoked")
+	h.listRelatives(w, r, h.service.ListSubsidiaries)
+}
+
+// ListAncestors returns the parent of a company, or its whole chain of owners with transitive=true
+func (h *Handler) ListAncestors(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("ListAncestors handler invoked")
+	h.listRelatives(w, r, h.service.ListAncestors)
+}
+
+// listRelatives parses the company ID and transitive parameter of a hierarchy listing and writes its result
+func (h *Handler) listRelatives(w http.ResponseWriter, r *http.Request, list func(ctx context.Context, tenantID, id uuid.UUID, transitive bool) ([]Relative, error)) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.logger.Error(err, "Invalid UUID")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid UUID")
+		return
+	}
+
+	transitive := false
+	if value := r.URL.Query().Get("transitive"); value != "" {
+		transitive, err = strconv.ParseBool(value)
+		if err != nil {
+			violations := &apperror.ValidationError{}
+			violations.Add("transitive", apperror.CodeInvalidValue, "transitive must be true or false")
+			utils.WriteError(w, r, violations)
+			return
+		}
+	}
+
+	relatives, err := list(r.Context(), auth.TenantFromContext(r.Context()), id, transitive)
+	if err != nil {
+		h.logger.Error(err, "Failed to list related companies")
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{"items": relatives})
+}
+
+// GetGroupSummary returns the company and employee counts of a company and all of its subsidiaries
+func (h *Handler) GetGroupSummary(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("GetGroupSummary handler invoked")
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.logger.Error(err, "Invalid UUID")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid UUID")
+		return
+	}
+
+	summary, err := h.service.GetGroupSummary(r.Context(), auth.TenantFromContext(r.Context()), id)
+	if err != nil {
+		h.logger.Error(err, "Failed to summarize company group")
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, summary)
+}
+
 // GetCompany retrieves a company by its ID
 func (h *Handler) GetCompany(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("GetCompany handler invoked")
@@ -121,14 +387,26 @@ func (h *Handler) GetCompany(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
 		h.logger.Error(err, "Invalid UUID")
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid UUID")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid UUID")
 		return
 	}
 
-	company, err := h.service.GetCompanyByID(id)
+	expand, err := ParseExpand(r.URL.Query().Get("expand"))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	var company interface{}
+	tenantID := auth.TenantFromContext(r.Context())
+	if expand == (Expand{}) {
+		company, err = h.service.GetCompanyByID(r.Context(), tenantID, id)
+	} else {
+		company, err = h.service.GetCompanyDetails(r.Context(), tenantID, id, expand)
+	}
 	if err != nil {
-		h.logger.Error(err, "Company not found")
-		utils.ErrorResponse(w, http.StatusNotFound, "Company not found")
+		h.logger.Error(err, "Failed to retrieve company")
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -136,20 +414,694 @@ func (h *Handler) GetCompany(w http.ResponseWriter, r *http.Request) {
 	utils.JSONResponse(w, http.StatusOK, company)
 }
 
-// produceEvent sends events to Kafka based on the action performed
-func (h *Handler) produceEvent(action string, company interface{}) {
-	event := Event{
+// addressChanged is the payload of an address_changed event; deletions only carry the address ID
+type addressChanged struct {
+	CompanyID uuid.UUID `json:"company_id"`
+	Change    string    `json:"change"`
+	AddressID uuid.UUID `json:"address_id"`
+	Address   *Address  `json:"address,omitempty"`
+}
+
+// ListAddresses returns the addresses of a company, the registered address first
+func (h *Handler) ListAddresses(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("ListAddresses handler invoked")
+
+	ids, ok := h.pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	addresses, err := h.service.ListAddresses(r.Context(), auth.TenantFromContext(r.Context()), ids[0])
+	if err != nil {
+		h.logger.Error(err, "Failed to list company addresses")
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{"items": addresses})
+}
+
+// CreateAddress handles adding an address to a company
+func (h *Handler) CreateAddress(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("CreateAddress handler invoked")
+
+	ids, ok := h.pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var address Address
+	if err := json.NewDecoder(r.Body).Decode(&address); err != nil {
+		h.logger.Error(err, "Invalid input while decoding address data")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	tenantID := auth.TenantFromContext(r.Context())
+	if err := h.service.CreateAddress(r.Context(), tenantID, ids[0], &address); err != nil {
+		h.logger.Error(err, "Failed to create company address")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.produceEvent(r.Context(), tenantID, "address_changed", addressChanged{CompanyID: ids[0], Change: "created", AddressID: address.ID, Address: &address})
+	h.logger.Info("Address %s added to company %s", address.ID, ids[0])
+	utils.JSONResponse(w, http.StatusCreated, address)
+}
+
+// UpdateAddress handles replacing an address of a company
+func (h *Handler) UpdateAddress(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("UpdateAddress handler invoked")
+
+	ids, ok := h.pathIDs(w, r, "id", "addressID")
+	if !ok {
+		return
+	}
+
+	var address Address
+	if err := json.NewDecoder(r.Body).Decode(&address); err != nil {
+		h.logger.Error(err, "Invalid input while decoding address data")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	tenantID := auth.TenantFromContext(r.Context())
+	if err := h.service.UpdateAddress(r.Context(), tenantID, ids[0], ids[1], &address); err != nil {
+		h.logger.Error(err, "Failed to update company address")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.produceEvent(r.Context(), tenantID, "address_changed", addressChanged{CompanyID: ids[0], Change: "updated", AddressID: address.ID, Address: &address})
+	h.logger.Info("Address %s of company %s updated", address.ID, ids[0])
+	utils.JSONResponse(w, http.StatusOK, address)
+}
+
+// DeleteAddress handles removing an address of a company
+func (h *Handler) DeleteAddress(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("DeleteAddress handler invoked")
+
+	ids, ok := h.pathIDs(w, r, "id", "addressID")
+	if !ok {
+		return
+	}
+
+	tenantID := auth.TenantFromContext(r.Context())
+	if err := h.service.DeleteAddress(r.Context(), tenantID, ids[0], ids[1]); err != nil {
+		h.logger.Error(err, "Failed to delete company address")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.produceEvent(r.Context(), tenantID, "address_changed", addressChanged{CompanyID: ids[0], Change: "deleted", AddressID: ids[1]})
+	h.logger.Info("Address %s of company %s deleted", ids[1], ids[0])
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// contactChanged is the payload of a contact_changed event; deletions only carry the contact ID
+type contactChanged struct {
+	CompanyID uuid.UUID `json:"company_id"`
+	Change    string    `json:"change"`
+	ContactID uuid.UUID `json:"contact_id"`
+	Contact   *Contact  `json:"contact,omitempty"`
+}
+
+// ListContacts returns the contacts of a company ordered by name
+func (h *Handler) ListContacts(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("ListContacts handler invoked")
+
+	ids, ok := h.pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	contacts, err := h.service.ListContacts(r.Context(), auth.TenantFromContext(r.Context()), ids[0])
+	if err != nil {
+		h.logger.Error(err, "Failed to list company contacts")
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{"items": contacts})
+}
+
+// CreateContact handles adding a contact to a company
+func (h *Handler) CreateContact(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("CreateContact handler invoked")
+
+	ids, ok := h.pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var contact Contact
+	if err := json.NewDecoder(r.Body).Decode(&contact); err != nil {
+		h.logger.Error(err, "Invalid input while decoding contact data")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	tenantID := auth.TenantFromContext(r.Context())
+	if err := h.service.CreateContact(r.Context(), tenantID, ids[0], &contact); err != nil {
+		h.logger.Error(err, "Failed to create company contact")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.produceEvent(r.Context(), tenantID, "contact_changed", contactChanged{CompanyID: ids[0], Change: "created", ContactID: contact.ID, Contact: &contact})
+	h.logger.Info("Contact %s added to company %s", contact.ID, ids[0])
+	utils.JSONResponse(w, http.StatusCreated, contact)
+}
+
+// UpdateContact handles replacing a contact of a company
+func (h *Handler) UpdateContact(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("UpdateContact handler invoked")
+
+	ids, ok := h.pathIDs(w, r, "id", "contactID")
+	if !ok {
+		return
+	}
+
+	var contact Contact
+	if err := json.NewDecoder(r.Body).Decode(&contact); err != nil {
+		h.logger.Error(err, "Invalid input while decoding contact data")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	tenantID := auth.TenantFromContext(r.Context())
+	if err := h.service.UpdateContact(r.Context(), tenantID, ids[0], ids[1], &contact); err != nil {
+		h.logger.Error(err, "Failed to update company contact")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.produceEvent(r.Context(), tenantID, "contact_changed", contactChanged{CompanyID: ids[0], Change: "updated", ContactID: contact.ID, Contact: &contact})
+	h.logger.Info("Contact %s of company %s updated", contact.ID, ids[0])
+	utils.JSONResponse(w, http.StatusOK, contact)
+}
+
+// DeleteContact handles removing a contact of a company
+func (h *Handler) DeleteContact(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("DeleteContact handler invoked")
+
+	ids, ok := h.pathIDs(w, r, "id", "contactID")
+	if !ok {
+		return
+	}
+
+	tenantID := auth.TenantFromContext(r.Context())
+	if err := h.service.DeleteContact(r.Context(), tenantID, ids[0], ids[1]); err != nil {
+		h.logger.Error(err, "Failed to delete company contact")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.produceEvent(r.Context(), tenantID, "contact_changed", contactChanged{CompanyID: ids[0], Change: "deleted", ContactID: ids[1]})
+	h.logger.Info("Contact %s of company %s deleted", ids[1], ids[0])
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddTags handles adding tags to a company
+func (h *Handler) AddTags(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("AddTags handler invoked")
+	h.changeTags(w, r, h.service.AddTags)
+}
+
+// ReplaceTags handles replacing all the tags of a company
+func (h *Handler) ReplaceTags(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("ReplaceTags handler invoked")
+	h.changeTags(w, r, h.service.ReplaceTags)
+}
+
+// changeTags decodes the tags of a request and applies them to the company with change, publishing an update
+// event when the tags changed
+func (h *Handler) changeTags(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, tenantID, id uuid.UUID, tags Tags) (*Company, *TagChange, error)) {
+	ids, ok := h.pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var request TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.logger.Error(err, "Invalid input while decoding tags")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	tenantID := auth.TenantFromContext(r.Context())
+	company, tagChange, err := change(r.Context(), tenantID, ids[0], request.Tags)
+	if err != nil {
+		h.logger.Error(err, "Failed to change company tags")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.tagsChanged(r.Context(), tenantID, company, tagChange)
+	utils.JSONResponse(w, http.StatusOK, company)
+}
+
+// RemoveTag handles removing a tag from a company
+func (h *Handler) RemoveTag(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("RemoveTag handler invoked")
+
+	ids, ok := h.pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	tenantID := auth.TenantFromContext(r.Context())
+	company, change, err := h.service.RemoveTag(r.Context(), tenantID, ids[0], mux.Vars(r)["tag"])
+	if err != nil {
+		h.logger.Error(err, "Failed to remove company tag")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.tagsChanged(r.Context(), tenantID, company, change)
+	utils.JSONResponse(w, http.StatusOK, company)
+}
+
+// tagsChanged publishes an update event for a change of the tags of a company, unless nothing changed
+func (h *Handler) tagsChanged(ctx context.Context, tenantID uuid.UUID, company *Company, change *TagChange) {
+	if change.empty() {
+		h.logger.Info("Tags of company %s unchanged", company.ID)
+		return
+	}
+	h.produceEvent(ctx, tenantID, "update", updatedCompany{Company: company, TagChanges: change})
+	h.logger.Info("Tags of company %s changed: %d added, %d removed", company.ID, len(change.Added), len(change.Removed))
+}
+
+// ListTags handles the tag catalogue of a tenant: the curated tags and the tags in use, with how many companies carry each
+func (h *Handler) ListTags(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("ListTags handler invoked")
+
+	query := r.URL.Query()
+	violations := &apperror.ValidationError{}
+	limit := parsePageParam(query.Get("limit"), defaultPageSize, 1, maxPageSize, "limit", violations)
+	offset := parsePageParam(query.Get("offset"), 0, 0, math.MaxInt32, "offset", violations)
+	if err := violations.Err(); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// One extra row tells whether another page follows
+	tags, err := h.service.ListTags(r.Context(), auth.TenantFromContext(r.Context()), query.Get("prefix"), limit+1, offset)
+	if err != nil {
+		h.logger.Error(err, "Failed to list tags")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	hasMore := len(tags) > limit
+	if hasMore {
+		tags = tags[:limit]
+	}
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"items":    tags,
+		"limit":    limit,
+		"offset":   offset,
+		"has_more": hasMore,
+	})
+}
+
+// PutCuratedTag handles creating a curated tag or updating its description
+func (h *Handler) PutCuratedTag(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("PutCuratedTag handler invoked")
+
+	var tag CuratedTag
+	if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+		h.logger.Error(err, "Invalid input while decoding curated tag")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	tag.Name = mux.Vars(r)["name"]
+	if err := h.service.PutCuratedTag(r.Context(), &tag); err != nil {
+		h.logger.Error(err, "Failed to save curated tag")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.logger.Info("Curated tag %s saved", tag.Name)
+	utils.JSONResponse(w, http.StatusOK, tag)
+}
+
+// DeleteCuratedTag handles removing a curated tag, which companies keep as a free-form tag
+func (h *Handler) DeleteCuratedTag(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("DeleteCuratedTag handler invoked")
+
+	name := mux.Vars(r)["name"]
+	if err := h.service.DeleteCuratedTag(r.Context(), name); err != nil {
+		h.logger.Error(err, "Failed to delete curated tag")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.logger.Info("Curated tag %s deleted", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pathIDs parses the named UUID path variables, writing a 400 response when one is invalid
+func (h *Handler) pathIDs(w http.ResponseWriter, r *http.Request, names ...string) ([]uuid.UUID, bool) {
+	vars := mux.Vars(r)
+	ids := make([]uuid.UUID, len(names))
+	for i, name := range names {
+		id, err := uuid.Parse(vars[name])
+		if err != nil {
+			h.logger.Error(err, "Invalid UUID")
+			utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid UUID")
+			return nil, false
+		}
+		ids[i] = id
+	}
+	return ids, true
+}
+
+// LookupCompany returns the company of the caller's tenant with the official identifier in the query
+func (h *Handler) LookupCompany(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("LookupCompany handler invoked")
+
+	lookup, err := ParseLookup(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	company, err := h.service.LookupCompany(r.Context(), auth.TenantFromContext(r.Context()), lookup)
+	if err != nil {
+		h.logger.Error(err, "Failed to look up company")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.logger.Info("Company looked up successfully by %s: %s", lookup.Kind, company.ID)
+	utils.JSONResponse(w, http.StatusOK, company)
+}
+
+// ListCompanies returns a page of the companies of the caller's tenant that match the query filters
+func (h *Handler) ListCompanies(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("ListCompanies handler invoked")
+
+	query := r.URL.Query()
+	filter, err := ParseFilter(query)
+	violations := &apperror.ValidationError{}
+	errors.As(err, &violations)
+	limit := parsePageParam(query.Get("limit"), defaultPageSize, 1, maxPageSize, "limit", violations)
+	offset := parsePageParam(query.Get("offset"), 0, 0, math.MaxInt32, "offset", violations)
+	if err := violations.Err(); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// One extra row tells whether another page follows
+	companies, err := h.service.ListCompanies(r.Context(), auth.TenantFromContext(r.Context()), filter, limit+1, offset)
+	if err != nil {
+		h.logger.Error(err, "Failed to list companies")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	hasMore := len(companies) > limit
+	if hasMore {
+		companies = companies[:limit]
+	}
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"items":    companies,
+		"limit":    limit,
+		"offset":   offset,
+		"has_more": hasMore,
+	})
+}
+
+// SearchCompanies returns a ranked page of the companies of the caller's tenant that match the q parameter and the query filters
+func (h *Handler) SearchCompanies(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("SearchCompanies handler invoked")
+
+	query := r.URL.Query()
+	search, err := ParseSearch(query)
+	violations := &apperror.ValidationError{}
+	errors.As(err, &violations)
+	limit := parsePageParam(query.Get("limit"), defaultPageSize, 1, maxPageSize, "limit", violations)
+	offset := parsePageParam(query.Get("offset"), 0, 0, math.MaxInt32, "offset", violations)
+	if err := violations.Err(); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// One extra row tells whether another page follows
+	results, err := h.service.SearchCompanies(r.Context(), auth.TenantFromContext(r.Context()), search, limit+1, offset)
+	if err != nil {
+		h.logger.Error(err, "Failed to search companies")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	hasMore := len(results) > limit
+	if hasMore {
+		results = results[:limit]
+	}
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"items":    results,
+		"limit":    limit,
+		"offset":   offset,
+		"has_more": hasMore,
+	})
+}
+
+// ExportCompanies streams every company of the caller's tenant that matches the query filters as CSV, NDJSON or Parquet
+func (h *Handler) ExportCompanies(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("ExportCompanies handler invoked")
+
+	query := r.URL.Query()
+	filter, err := ParseFilter(query)
+	violations := &apperror.ValidationError{}
+	errors.As(err, &violations)
+	format := query.Get("format")
+	if format == "" {
+		format = FormatCSV
+	}
+	contentType, ok := exportFormats[format]
+	if !ok {
+		violations.Add("format", apperror.CodeInvalidValue, "format must be csv, ndjson or parquet")
+	}
+	if err := violations.Err(); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	columns, err := parseExportColumns(query.Get("columns"))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	tenantID := auth.TenantFromContext(r.Context())
+	flusher, _ := w.(http.Flusher)
+	var writer exportWriter
+	rows := 0
+
+	// The headers are set once the snapshot is taken and sent with the first exported bytes
+	begin := func(snapshot time.Time) error {
+		stamp := snapshot.UTC().Format(time.RFC3339Nano)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="companies-%s.%s"`, snapshot.UTC().Format("20060102T150405Z"), format))
+		w.Header().Set(SnapshotHeader, stamp)
+
+		var err error
+		writer, err = newExportWriter(format, w, columns)
+		return err
+	}
+	write := func(company *Company) error {
+		if err := writer.Write(company); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushRows == 0 {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	}
+
+	err = h.service.ExportCompanies(r.Context(), tenantID, filter, begin, write)
+	if writer == nil {
+		// Nothing was sent yet, so the failure can still be reported as a problem
+		h.logger.Error(err, "Failed to start company export")
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		// The status line is already sent; the client sees a truncated file and a broken connection
+		h.logger.Error(err, "Company export aborted after %d rows", rows)
+		panic(http.ErrAbortHandler)
+	}
+
+	h.logger.Info("Exported %d companies as %s", rows, format)
+}
+
+// parsePageParam reads an optional integer page parameter within bounds
+func parsePageParam(value string, fallback, min, max int, name string, violations *apperror.ValidationError) int {
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < min || parsed > max {
+		violations.Add(name, apperror.CodeOutOfRange, fmt.Sprintf("%s must be a whole number between %d and %d", name, min, max))
+		return fallback
+	}
+	return parsed
+}
+
+// ImportCompanies creates companies from a CSV or NDJSON file, in the background when the file is large
+func (h *Handler) ImportCompanies(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("ImportCompanies handler invoked")
+
+	query := r.URL.Query()
+	violations := &apperror.ValidationError{}
+	mode := ImportMode(query.Get("mode"))
+	if mode == "" {
+		mode = ImportAtomic
+	} else if !mode.Valid() {
+		violations.Add("mode", apperror.CodeInvalidValue, "mode must be atomic or best_effort")
+	}
+	async := false
+	if value := query.Get("async"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			violations.Add("async", apperror.CodeInvalidValue, "async must be true or false")
+		}
+		async = parsed
+	}
+	if err := violations.Err(); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	rows, err := h.parseImport(w, r)
+	if err != nil {
+		h.logger.Error(err, "Failed to parse import file")
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.ErrorResponse(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Import files are limited to %d bytes", tooLarge.Limit))
+			return
+		}
+		if errors.Is(err, errUnsupportedImportType) {
+			utils.ErrorResponse(w, r, http.StatusUnsupportedMediaType, err.Error())
+			return
+		}
+		utils.ErrorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tenantID := auth.TenantFromContext(r.Context())
+	if async || len(rows) > h.limits.SyncRows {
+		identity, _ := auth.IdentityFromContext(r.Context())
+		job, err := h.service.StartImportJob(r.Context(), tenantID, identity.UserID, mode, rows, h.importJobDone)
+		if err != nil {
+			h.logger.Error(err, "Failed to start import job")
+			utils.WriteError(w, r, err)
+			return
+		}
+
+		h.logger.Info("Import job %s started with %d rows", job.ID, len(rows))
+		w.Header().Set("Location", "/api/import-jobs/"+job.ID.String())
+		utils.JSONResponse(w, http.StatusAccepted, job)
+		return
+	}
+
+	report, err := h.service.ImportCompanies(r.Context(), tenantID, rows, mode)
+	h.publishImported(r.Context(), tenantID, report)
+	if err != nil {
+		h.logger.Error(err, "Failed to import companies")
+		utils.WriteError(w, r, err)
+		return
+	}
+	if mode == ImportAtomic && report.Failed > 0 {
+		h.logger.Info("Import rejected, %d of %d rows failed", report.Failed, report.Total)
+		utils.WriteError(w, r, report.Err())
+		return
+	}
+
+	h.logger.Info("Import finished, %d created and %d failed", report.Created, report.Failed)
+	utils.JSONResponse(w, http.StatusOK, report)
+}
+
+// GetImportJob returns the status and, once finished, the report of a background import
+func (h *Handler) GetImportJob(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("GetImportJob handler invoked")
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.logger.Error(err, "Invalid UUID")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid UUID")
+		return
+	}
+
+	job, err := h.service.GetImportJob(r.Context(), auth.TenantFromContext(r.Context()), id)
+	if err != nil {
+		h.logger.Error(err, "Failed to retrieve import job")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, job)
+}
+
+// parseImport reads the rows of an import file in the format given by its content type
+func (h *Handler) parseImport(w http.ResponseWriter, r *http.Request) ([]ImportRow, error) {
+	body := http.MaxBytesReader(w, r.Body, MaxImportBytes)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return ParseCSV(body, h.limits.MaxRows)
+	case "application/x-ndjson", "application/ndjson":
+		return ParseNDJSON(body, h.limits.MaxRows)
+	default:
+		return nil, errUnsupportedImportType
+	}
+}
+
+// importJobDone publishes the companies created by a background import and logs its outcome
+func (h *Handler) importJobDone(ctx context.Context, job *ImportJob, report *ImportReport, err error) {
+	h.publishImported(ctx, job.TenantID, report)
+	if err != nil {
+		h.logger.Error(err, "Import job %s failed", job.ID)
+		return
+	}
+	h.logger.Info("Import job %s finished with status %s", job.ID, job.Status)
+}
+
+// publishImported emits a create event for every company an import created
+func (h *Handler) publishImported(ctx context.Context, tenantID uuid.UUID, report *ImportReport) {
+	if report == nil {
+		return
+	}
+	for _, company := range report.Companies() {
+		h.produceEvent(ctx, tenantID, "create", company)
+	}
+}
+
+// produceEvent sends events to Kafka based on the action performed, tagged with the tenant they belong to.
+// The change is already stored, so the publish outlives a disconnecting client and is only bounded by its timeout.
+func (h *Handler) produceEvent(ctx context.Context, tenantID uuid.UUID, action string, company interface{}) {
+	message := Event{
 		Action:  action,
 		Company: company,
 	}
 
-	eventData, err := json.Marshal(event)
+	eventData, err := json.Marshal(message)
 	if err != nil {
 		h.logger.Error(err, "Failed to marshal event data")
 		return
 	}
 
-	if err := h.producer.PublishMessage(action, string(eventData)); err != nil {
+	headers := map[string]string{event.TenantHeader: tenantID.String()}
+	if err := h.producer.PublishMessage(context.WithoutCancel(ctx), action, string(eventData), headers); err != nil {
 		h.logger.Error(err, "Failed to publish Kafka message")
 	} else {
 		h.logger.Info("Kafka message published successfully for action: %s", action)
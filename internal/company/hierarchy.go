@@ -0,0 +1,83 @@
+package company
+
+import (
+	"xm-microservice/pkg/apperror"
+
+	"github.com/google/uuid"
+)
+
+// maxHierarchyDepth bounds how far hierarchy queries follow parent links. Changes that would make a chain
+// deeper are refused, so that the queries always reach the top and the cycle checks see every ancestor.
+const maxHierarchyDepth = 100
+
+// ParentRequest names the parent of a company and the share of it that the parent owns
+type ParentRequest struct {
+	ParentID            *uuid.UUID `json:"parent_id"`
+	OwnershipPercentage *float64   `json:"ownership_percentage"`
+}
+
+// validate reports a self-referencing parent and an ownership outside (0, 100] or without a parent
+func (p ParentRequest) validate(id uuid.UUID) error {
+	violations := &apperror.ValidationError{}
+	if p.ParentID == nil {
+		violations.Add("parent_id", apperror.CodeRequired, "parent_id is required")
+	} else if *p.ParentID == id {
+		violations.Add("parent_id", apperror.CodeInvalidValue, "a company cannot be its own parent")
+	}
+	if p.OwnershipPercentage != nil && (*p.OwnershipPercentage <= 0 || *p.OwnershipPercentage > 100) {
+		violations.Add("ownership_percentage", apperror.CodeOutOfRange, "ownership_percentage must be greater than 0 and at most 100")
+	}
+	return violations.Err()
+}
+
+// Relative is a subsidiary or ancestor of a company, with its distance from that company
+type Relative struct {
+	Company
+	Depth int `json:"depth"`
+}
+
+// GroupSummary aggregates a company and all of its transitive subsidiaries
+type GroupSummary struct {
+	CompanyID      uuid.UUID `json:"company_id"`
+	Companies      int       `json:"companies"`
+	Subsidiaries   int       `json:"subsidiaries"`
+	Depth          int       `json:"depth"`
+	TotalEmployees int       `json:"total_employees"`
+}
+
+// HierarchyChange describes a company getting a new parent or losing its parent
+type HierarchyChange struct {
+	CompanyID           uuid.UUID  `json:"company_id"`
+	ParentID            *uuid.UUID `json:"parent_id"`
+	PreviousParentID    *uuid.UUID `json:"previous_parent_id"`
+	OwnershipPercentage *float64   `json:"ownership_percentage"`
+}
+
+// ErrHierarchyCycle rejects a parent that is the company itself or one of its subsidiaries
+var ErrHierarchyCycle = apperror.New(apperror.ErrConflict, "the parent is a subsidiary of the company, which would create a cycle")
+
+// ErrHierarchyTooDeep rejects a change that would put a company more than maxHierarchyDepth levels below its top-level company
+var ErrHierarchyTooDeep = apperror.New(apperror.ErrConflict, "the change would make the company hierarchy deeper than 100 levels")
+
+// checkDepth rejects placing a company at depth levels below its top-level company when that company's
+// subsidiaries would end up deeper than maxHierarchyDepth
+func checkDepth(depth int, subsidiaries []Relative) error {
+	deepest := depth
+	for _, subsidiary := range subsidiaries {
+		deepest = max(deepest, depth+subsidiary.Depth)
+	}
+	if deepest > maxHierarchyDepth {
+		return ErrHierarchyTooDeep
+	}
+	return nil
+}
+
+// containsCompany reports whether id is one of the relatives
+func containsCompany(relatives []Relative, id uuid.UUID) bool {
+	for _, relative := range relatives {
+		if relative.ID == id {
+			return true
+		}
+	}
+	return false
+}
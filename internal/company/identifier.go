@@ -0,0 +1,119 @@
+package company
+
+import (
+	"net/url"
+	"strings"
+
+	"xm-microservice/internal/jurisdiction"
+	"xm-microservice/pkg/apperror"
+)
+
+// IdentifierKind names an official identifier that a company can be looked up by
+type IdentifierKind string
+
+const (
+	IdentifierRegistrationNumber IdentifierKind = "registration_number"
+	IdentifierTaxID              IdentifierKind = "tax_id"
+	IdentifierLEI                IdentifierKind = "lei"
+)
+
+// identifierKinds lists the identifiers in the order lookups look for them
+var identifierKinds = []IdentifierKind{IdentifierRegistrationNumber, IdentifierTaxID, IdentifierLEI}
+
+// scoped reports whether the identifier is only unique within a jurisdiction
+func (k IdentifierKind) scoped() bool {
+	return k != IdentifierLEI
+}
+
+// validate checks an identifier of the kind in a jurisdiction and returns it in canonical form
+func (k IdentifierKind) validate(country, value string) (string, error) {
+	switch k {
+	case IdentifierRegistrationNumber:
+		return jurisdiction.ValidateRegistrationNumber(country, value)
+	case IdentifierTaxID:
+		return jurisdiction.ValidateTaxID(country, value)
+	default:
+		return jurisdiction.ValidateLEI(value)
+	}
+}
+
+// Lookup finds a company by one of its official identifiers
+type Lookup struct {
+	Jurisdiction string
+	Kind         IdentifierKind
+	Value        string
+}
+
+// ParseLookup reads a Lookup from query parameters: exactly one of registration_number, tax_id and lei,
+// with a jurisdiction for the first two. The identifier is brought into the canonical form it is stored in.
+func ParseLookup(query url.Values) (Lookup, error) {
+	violations := &apperror.ValidationError{}
+	lookup := Lookup{Jurisdiction: strings.ToUpper(strings.TrimSpace(query.Get("jurisdiction")))}
+
+	for _, kind := range identifierKinds {
+		if value := strings.TrimSpace(query.Get(string(kind))); value != "" {
+			if lookup.Kind != "" {
+				violations.Add(string(kind), apperror.CodeInvalidValue, "only one identifier can be looked up at a time")
+				continue
+			}
+			lookup.Kind, lookup.Value = kind, value
+		}
+	}
+	if lookup.Kind == "" {
+		violations.Add("registration_number", apperror.CodeRequired, "one of registration_number, tax_id or lei is required")
+		return lookup, violations.Err()
+	}
+
+	if lookup.Kind.scoped() {
+		validateJurisdiction(lookup.Jurisdiction, violations)
+	}
+	if err := violations.Err(); err != nil {
+		return lookup, err
+	}
+
+	value, err := lookup.Kind.validate(lookup.Jurisdiction, lookup.Value)
+	if err != nil {
+		violations.Add(string(lookup.Kind), apperror.CodeInvalidValue, string(lookup.Kind)+" "+err.Error())
+		return lookup, violations.Err()
+	}
+	lookup.Value = value
+	return lookup, nil
+}
+
+// validateIdentifiers checks the jurisdiction and official identifiers of a company and stores them in canonical form.
+// Registration numbers and tax identifiers need a jurisdiction, since their format depends on it.
+func validateIdentifiers(company *Company, violations *apperror.ValidationError) {
+	company.Jurisdiction = strings.ToUpper(strings.TrimSpace(company.Jurisdiction))
+	if company.Jurisdiction != "" {
+		validateJurisdiction(company.Jurisdiction, violations)
+	} else if company.RegistrationNumber != "" || company.TaxID != "" {
+		violations.Add("jurisdiction", apperror.CodeRequired, "a jurisdiction is required with a registration number or tax identifier")
+	}
+
+	fields := map[IdentifierKind]*string{
+		IdentifierRegistrationNumber: &company.RegistrationNumber,
+		IdentifierTaxID:              &company.TaxID,
+		IdentifierLEI:                &company.LEI,
+	}
+	for _, kind := range identifierKinds {
+		field := fields[kind]
+		if *field = strings.TrimSpace(*field); *field == "" {
+			continue
+		}
+		value, err := kind.validate(company.Jurisdiction, *field)
+		if err != nil {
+			violations.Add(string(kind), apperror.CodeInvalidValue, string(kind)+" "+err.Error())
+			continue
+		}
+		*field = value
+	}
+}
+
+// validateJurisdiction reports a jurisdiction that is not an ISO 3166-1 alpha-2 country code
+func validateJurisdiction(country string, violations *apperror.ValidationError) {
+	if country == "" {
+		violations.Add("jurisdiction", apperror.CodeRequired, "jurisdiction is required")
+	} else if !jurisdiction.IsCountry(country) {
+		violations.Add("jurisdiction", apperror.CodeInvalidValue, "jurisdiction must be an ISO 3166-1 alpha-2 country code")
+	}
+}
@@ -0,0 +1,324 @@
+package company
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"xm-microservice/pkg/apperror"
+
+	"github.com/google/uuid"
+)
+
+// ImportMode decides what happens to valid rows when other rows of an import fail
+type ImportMode string
+
+const (
+	// ImportAtomic creates every row or, if any row fails, none of them
+	ImportAtomic ImportMode = "atomic"
+	// ImportBestEffort creates every valid row and reports the failed ones
+	ImportBestEffort ImportMode = "best_effort"
+)
+
+// Valid reports whether the mode is known
+func (m ImportMode) Valid() bool {
+	return m == ImportAtomic || m == ImportBestEffort
+}
+
+// Row statuses in an import report
+const (
+	RowCreated = "created"
+	RowFailed  = "failed"
+	RowSkipped = "skipped"
+)
+
+// ImportJobStatus is the lifecycle state of a background import
+type ImportJobStatus string
+
+const (
+	JobPending   ImportJobStatus = "pending"
+	JobRunning   ImportJobStatus = "running"
+	JobSucceeded ImportJobStatus = "succeeded"
+	JobFailed    ImportJobStatus = "failed"
+)
+
+const (
+	// importHeartbeat is how often a running import job records that its worker is still alive
+	importHeartbeat = 30 * time.Second
+	// importStaleAfter is how long an unfinished job can go without a heartbeat before it counts as abandoned
+	importStaleAfter = 4 * importHeartbeat
+)
+
+// ImportJobDone is called when a background import finishes, with the stored job and the outcome of the import
+type ImportJobDone func(ctx context.Context, job *ImportJob, report *ImportReport, err error)
+
+// ImportRow is one parsed record of an import file, with the errors found while parsing it
+type ImportRow struct {
+	Line    int
+	Company Company
+	Err     error
+}
+
+// RowResult is the outcome of importing one row
+type RowResult struct {
+	Line      int                   `json:"line"`
+	Status    string                `json:"status"`
+	CompanyID *uuid.UUID            `json:"company_id,omitempty"`
+	Detail    string                `json:"detail,omitempty"`
+	Errors    []apperror.FieldError `json:"errors,omitempty"`
+}
+
+// ImportReport summarizes an import row by row
+type ImportReport struct {
+	Mode    ImportMode  `json:"mode"`
+	Total   int         `json:"total"`
+	Created int         `json:"created"`
+	Failed  int         `json:"failed"`
+	Rows    []RowResult `json:"rows"`
+
+	// companies holds the created companies so that events can be published for them
+	companies []Company
+}
+
+// Companies returns the companies created by the import
+func (r *ImportReport) Companies() []Company {
+	return r.companies
+}
+
+// ImportJob tracks an import that runs in the background
+type ImportJob struct {
+	ID         uuid.UUID       `json:"id"`
+	TenantID   uuid.UUID       `json:"tenant_id"`
+	CreatedBy  uuid.UUID       `json:"created_by"`
+	Status     ImportJobStatus `json:"status"`
+	Mode       ImportMode      `json:"mode"`
+	TotalRows  int             `json:"total_rows"`
+	Report     *ImportReport   `json:"report,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// errUnsupportedImportType is returned for uploads that are neither CSV nor NDJSON
+var errUnsupportedImportType = errors.New("import files must be sent as text/csv or application/x-ndjson")
+
+// importColumns are the CSV columns an import file may contain
+var importColumns = map[string]bool{
+	"name":                true,
+	"description":         true,
+	"amount_of_employees": true,
+	"registered":          true,
+	"status":              true,
+	"type":                true,
+	"jurisdiction":        true,
+	"registration_number": true,
+	"tax_id":              true,
+	"lei":                 true,
+	"attributes":          true,
+	"tags":                true,
+}
+
+// ParseCSV reads companies from CSV with a header row naming the columns
+func ParseCSV(r io.Reader, maxRows int) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !importColumns[name] {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		columns[name] = i
+	}
+	// Without this check, a file missing the name column would only be reported row by row
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("the CSV header must contain a name column")
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			if len(rows) == 0 {
+				return nil, errors.New("the file contains no rows")
+			}
+			return rows, nil
+		}
+		if len(rows) >= maxRows {
+			return nil, fmt.Errorf("the file contains more than %d rows", maxRows)
+		}
+
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			rows = append(rows, ImportRow{Line: parseErr.StartLine, Err: rowError("", "malformed CSV record")})
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, csvRow(line, record, columns))
+	}
+}
+
+// csvRow converts a CSV record into a company, collecting cells that cannot be parsed
+func csvRow(line int, record []string, columns map[string]int) ImportRow {
+	cell := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	row := ImportRow{Line: line}
+	row.Company.Name = cell("name")
+	row.Company.Description = cell("description")
+	row.Company.Type = CompanyType(cell("type"))
+	row.Company.Status = CompanyStatus(cell("status"))
+	row.Company.Jurisdiction = cell("jurisdiction")
+	row.Company.RegistrationNumber = cell("registration_number")
+	row.Company.TaxID = cell("tax_id")
+	row.Company.LEI = cell("lei")
+
+	violations := &apperror.ValidationError{}
+	if value := cell("amount_of_employees"); value != "" {
+		amount, err := strconv.Atoi(value)
+		if err != nil {
+			violations.Add("amount_of_employees", apperror.CodeInvalidValue, "amount of employees must be a whole number")
+		} else {
+			row.Company.AmountOfEmployees = &amount
+		}
+	}
+	if value := cell("registered"); value != "" {
+		registered, err := strconv.ParseBool(value)
+		if err != nil {
+			violations.Add("registered", apperror.CodeInvalidValue, "registered must be true or false")
+		} else {
+			row.Company.Registered = &registered
+		}
+	}
+	if value := cell("attributes"); value != "" {
+		if err := json.Unmarshal([]byte(value), &row.Company.Attributes); err != nil {
+			violations.Add("attributes", apperror.CodeInvalidValue, "attributes must be a JSON object")
+		}
+	}
+	if value := cell("tags"); value != "" {
+		row.Company.Tags = strings.Split(value, ",")
+	}
+	row.Err = violations.Err()
+	return row
+}
+
+// ParseNDJSON reads companies from newline-delimited JSON, one object per line
+func ParseNDJSON(r io.Reader, maxRows int) ([]ImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var rows []ImportRow
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if len(rows) >= maxRows {
+			return nil, fmt.Errorf("the file contains more than %d rows", maxRows)
+		}
+
+		row := ImportRow{Line: line}
+		if err := json.Unmarshal(data, &row.Company); err != nil {
+			row.Err = rowError("", "malformed JSON object")
+		}
+		// Identifiers are always assigned by the service
+		row.Company.ID = uuid.Nil
+		row.Company.TenantID = uuid.Nil
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read NDJSON: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("the file contains no rows")
+	}
+	return rows, nil
+}
+
+// rowError builds a validation error for a row that could not be parsed
+func rowError(field, message string) error {
+	violations := &apperror.ValidationError{}
+	violations.Add(field, apperror.CodeInvalidValue, message)
+	return violations.Err()
+}
+
+// Err returns a validation error listing the failed rows, with fields named rows[<line>].<field>
+func (r *ImportReport) Err() error {
+	violations := &apperror.ValidationError{}
+	for _, row := range r.Rows {
+		if row.Status != RowFailed {
+			continue
+		}
+		prefix := fmt.Sprintf("rows[%d]", row.Line)
+		if len(row.Errors) == 0 {
+			violations.Add(prefix, apperror.CodeConflict, row.Detail)
+			continue
+		}
+		for _, field := range row.Errors {
+			name := prefix
+			if field.Field != "" {
+				name += "." + field.Field
+			}
+			violations.Add(name, field.Code, field.Message)
+		}
+	}
+	return violations.Err()
+}
+
+// fail marks a row as failed with the validation errors or message of err
+func (r *ImportReport) fail(i int, err error) {
+	row := &r.Rows[i]
+	row.Status = RowFailed
+	var validationErr *apperror.ValidationError
+	if errors.As(err, &validationErr) {
+		row.Detail = "the row contains invalid fields"
+		row.Errors = validationErr.Fields
+	} else {
+		row.Detail = apperror.Message(err)
+	}
+	r.Failed++
+}
+
+// created marks a row as imported
+func (r *ImportReport) created(i int, company Company) {
+	id := company.ID
+	r.Rows[i].Status = RowCreated
+	r.Rows[i].CompanyID = &id
+	r.Created++
+	r.companies = append(r.companies, company)
+}
+
+// skipRemaining marks every row without an outcome as skipped
+func (r *ImportReport) skipRemaining(detail string) {
+	for i := range r.Rows {
+		if r.Rows[i].Status == "" {
+			r.Rows[i].Status = RowSkipped
+			r.Rows[i].Detail = detail
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package company
+
+import (
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"xm-microservice/pkg/apperror"
+
+	"github.com/google/uuid"
+)
+
+// CompanyStatus is the stage of a company in its lifecycle
+type CompanyStatus string
+
+const (
+	StatusDraft               CompanyStatus = "draft"
+	StatusPendingRegistration CompanyStatus = "pending_registration"
+	StatusRegistered          CompanyStatus = "registered"
+	StatusSuspended           CompanyStatus = "suspended"
+	StatusDissolved           CompanyStatus = "dissolved"
+)
+
+// maxReasonLength caps the length of a transition reason in characters
+const maxReasonLength = 1000
+
+// valid reports whether s is a known status
+func (s CompanyStatus) valid() bool {
+	switch s {
+	case StatusDraft, StatusPendingRegistration, StatusRegistered, StatusSuspended, StatusDissolved:
+		return true
+	}
+	return false
+}
+
+// initial reports whether a company may be created with status s
+func (s CompanyStatus) initial() bool {
+	return s == StatusDraft || s == StatusPendingRegistration || s == StatusRegistered
+}
+
+// Transition is an allowed move of a company from one of several statuses to another
+type Transition struct {
+	From           []CompanyStatus
+	To             CompanyStatus
+	RequiresReason bool
+	AdminOnly      bool
+}
+
+// transitions lists every allowed status change by the name used in its endpoint
+var transitions = map[string]Transition{
+	"submit":    {From: []CompanyStatus{StatusDraft}, To: StatusPendingRegistration},
+	"reject":    {From: []CompanyStatus{StatusPendingRegistration}, To: StatusDraft, RequiresReason: true},
+	"register":  {From: []CompanyStatus{StatusPendingRegistration}, To: StatusRegistered},
+	"suspend":   {From: []CompanyStatus{StatusRegistered}, To: StatusSuspended, RequiresReason: true, AdminOnly: true},
+	"reinstate": {From: []CompanyStatus{StatusSuspended}, To: StatusRegistered, AdminOnly: true},
+	"dissolve":  {From: []CompanyStatus{StatusRegistered, StatusSuspended}, To: StatusDissolved, RequiresReason: true, AdminOnly: true},
+}
+
+// allows reports whether the transition can start from status
+func (t Transition) allows(status CompanyStatus) bool {
+	for _, from := range t.From {
+		if from == status {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedTransitions returns the names of the transitions that can start from status, sorted
+func allowedTransitions(status CompanyStatus) []string {
+	names := []string{}
+	for name, transition := range transitions {
+		if transition.allows(status) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Actor is the user requesting a status transition
+type Actor struct {
+	ID    uuid.UUID
+	Admin bool
+}
+
+// StatusChange records one status transition of a company
+type StatusChange struct {
+	ID         uuid.UUID     `json:"id"`
+	TenantID   uuid.UUID     `json:"tenant_id"`
+	CompanyID  uuid.UUID     `json:"company_id"`
+	Transition string        `json:"transition"`
+	From       CompanyStatus `json:"from"`
+	To         CompanyStatus `json:"to"`
+	Reason     string        `json:"reason,omitempty"`
+	ActorID    uuid.UUID     `json:"actor_id"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+var (
+	ErrUnknownTransition    = apperror.New(apperror.ErrNotFound, "unknown status transition")
+	ErrTransitionForbidden  = apperror.New(apperror.ErrForbidden, "only admins can perform this status transition")
+	ErrTransitionNotAllowed = apperror.New(apperror.ErrConflict, "the status transition is not allowed from the current status")
+	ErrCompanyDissolved     = apperror.New(apperror.ErrConflict, "dissolved companies cannot be changed")
+)
+
+// TransitionError carries the current status of a company whose transition was refused
+type TransitionError struct {
+	Status CompanyStatus
+}
+
+// Error returns the message of ErrTransitionNotAllowed
+func (e *TransitionError) Error() string {
+	return ErrTransitionNotAllowed.Error()
+}
+
+// Unwrap exposes ErrTransitionNotAllowed to errors.Is and errors.As
+func (e *TransitionError) Unwrap() error {
+	return ErrTransitionNotAllowed
+}
+
+// ProblemExtensions tells clients the current status and the transitions it allows
+func (e *TransitionError) ProblemExtensions() map[string]interface{} {
+	return map[string]interface{}{"status": e.Status, "allowed_transitions": allowedTransitions(e.Status)}
+}
+
+// checkTransition applies the guards of a transition to a company in status and returns the transition
+func checkTransition(name string, status CompanyStatus, reason string, actor Actor) (Transition, error) {
+	transition, ok := transitions[name]
+	if !ok {
+		return Transition{}, ErrUnknownTransition
+	}
+
+	violations := &apperror.ValidationError{}
+	if transition.RequiresReason && reason == "" {
+		violations.Add("reason", apperror.CodeRequired, "a reason is required to "+name+" a company")
+	} else if utf8.RuneCountInString(reason) > maxReasonLength {
+		violations.Add("reason", apperror.CodeTooLong, "reason must be up to 1000 characters")
+	}
+	if err := violations.Err(); err != nil {
+		return Transition{}, err
+	}
+
+	if transition.AdminOnly && !actor.Admin {
+		return Transition{}, ErrTransitionForbidden
+	}
+	if !transition.allows(status) {
+		return Transition{}, &TransitionError{Status: status}
+	}
+	return transition, nil
+}
+
+// setInitialStatus validates the status of a new company and derives the missing one of status and registered.
+// Without either, a company starts as a draft.
+func setInitialStatus(company *Company) error {
+	violations := &apperror.ValidationError{}
+	switch {
+	case company.Status == "" && company.Registered != nil && *company.Registered:
+		company.Status = StatusRegistered
+	case company.Status == "":
+		company.Status = StatusDraft
+	case !company.Status.valid():
+		violations.Add("status", apperror.CodeInvalidValue, "invalid company status")
+	case !company.Status.initial():
+		violations.Add("status", apperror.CodeInvalidValue, "a company must be created as draft, pending_registration or registered")
+	}
+	if err := violations.Err(); err != nil {
+		return err
+	}
+
+	registered := company.Status == StatusRegistered
+	if company.Registered != nil && *company.Registered != registered {
+		violations.Add("registered", apperror.CodeInvalidValue, "registered must match the status")
+		return violations.Err()
+	}
+	company.Registered = &registered
+	return nil
+}
@@ -0,0 +1,141 @@
+package company
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"xm-microservice/pkg/apperror"
+
+	"github.com/google/uuid"
+)
+
+var allStatuses = []CompanyStatus{StatusDraft, StatusPendingRegistration, StatusRegistered, StatusSuspended, StatusDissolved}
+
+func TestCheckTransitionEdges(t *testing.T) {
+	// Every allowed edge, by transition; all other statuses are rejected
+	edges := map[string][]CompanyStatus{
+		"submit":    {StatusDraft},
+		"reject":    {StatusPendingRegistration},
+		"register":  {StatusPendingRegistration},
+		"suspend":   {StatusRegistered},
+		"reinstate": {StatusSuspended},
+		"dissolve":  {StatusRegistered, StatusSuspended},
+	}
+	if len(edges) != len(transitions) {
+		t.Fatalf("test covers %d transitions, want all %d", len(edges), len(transitions))
+	}
+
+	admin := Actor{ID: uuid.New(), Admin: true}
+	for name, from := range edges {
+		for _, status := range allStatuses {
+			t.Run(name+" from "+string(status), func(t *testing.T) {
+				transition, err := checkTransition(name, status, "a reason", admin)
+				if !slices.Contains(from, status) {
+					var transitionErr *TransitionError
+					if !errors.As(err, &transitionErr) || transitionErr.Status != status {
+						t.Fatalf("checkTransition() = %v, want a TransitionError for %s", err, status)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("checkTransition() = %v", err)
+				}
+				if transition.To != transitions[name].To {
+					t.Errorf("checkTransition() moves to %s, want %s", transition.To, transitions[name].To)
+				}
+			})
+		}
+	}
+}
+
+func TestCheckTransitionFromDissolved(t *testing.T) {
+	if got := allowedTransitions(StatusDissolved); len(got) != 0 {
+		t.Errorf("allowedTransitions(dissolved) = %v, want none", got)
+	}
+	for name := range transitions {
+		if _, err := checkTransition(name, StatusDissolved, "a reason", Actor{Admin: true}); !errors.Is(err, ErrTransitionNotAllowed) {
+			t.Errorf("checkTransition(%s) from dissolved = %v, want %v", name, err, ErrTransitionNotAllowed)
+		}
+	}
+}
+
+func TestCheckTransitionGuards(t *testing.T) {
+	tests := []struct {
+		name       string
+		transition string
+		status     CompanyStatus
+		reason     string
+		admin      bool
+		wantErr    error
+		wantField  string
+	}{
+		{name: "unknown transition", transition: "archive", status: StatusDraft, admin: true, wantErr: ErrUnknownTransition},
+		{name: "reason is optional", transition: "submit", status: StatusDraft},
+		{name: "reason is required", transition: "reject", status: StatusPendingRegistration, wantErr: apperror.ErrValidation, wantField: "reason"},
+		{name: "reason too long", transition: "submit", status: StatusDraft, reason: strings.Repeat("r", maxReasonLength+1), wantErr: apperror.ErrValidation, wantField: "reason"},
+		{name: "longest reason", transition: "submit", status: StatusDraft, reason: strings.Repeat("é", maxReasonLength)},
+		{name: "admin transition by a user", transition: "suspend", status: StatusRegistered, reason: "overdue", wantErr: ErrTransitionForbidden},
+		{name: "admin transition by an admin", transition: "suspend", status: StatusRegistered, reason: "overdue", admin: true},
+		{name: "reason checked before the role", transition: "dissolve", status: StatusRegistered, wantErr: apperror.ErrValidation, wantField: "reason"},
+		{name: "role checked before the status", transition: "reinstate", status: StatusDraft, wantErr: ErrTransitionForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := checkTransition(tt.transition, tt.status, tt.reason, Actor{ID: uuid.New(), Admin: tt.admin})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("checkTransition() = %v, want %v", err, tt.wantErr)
+			}
+			var violations *apperror.ValidationError
+			if tt.wantField != "" && (!errors.As(err, &violations) || violations.Fields[0].Field != tt.wantField) {
+				t.Errorf("checkTransition() = %v, want a violation of %s", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestSetInitialStatus(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name           string
+		status         CompanyStatus
+		registered     *bool
+		wantStatus     CompanyStatus
+		wantRegistered bool
+		wantField      string
+	}{
+		{name: "neither given", wantStatus: StatusDraft},
+		{name: "registered only", registered: &yes, wantStatus: StatusRegistered, wantRegistered: true},
+		{name: "not registered only", registered: &no, wantStatus: StatusDraft},
+		{name: "draft", status: StatusDraft, wantStatus: StatusDraft},
+		{name: "pending registration", status: StatusPendingRegistration, wantStatus: StatusPendingRegistration},
+		{name: "registered", status: StatusRegistered, wantStatus: StatusRegistered, wantRegistered: true},
+		{name: "registered and matching flag", status: StatusRegistered, registered: &yes, wantStatus: StatusRegistered, wantRegistered: true},
+		{name: "draft and matching flag", status: StatusDraft, registered: &no, wantStatus: StatusDraft},
+		{name: "registered and contradicting flag", status: StatusRegistered, registered: &no, wantField: "registered"},
+		{name: "pending and contradicting flag", status: StatusPendingRegistration, registered: &yes, wantField: "registered"},
+		{name: "suspended", status: StatusSuspended, wantField: "status"},
+		{name: "dissolved", status: StatusDissolved, wantField: "status"},
+		{name: "unknown status", status: "archived", wantField: "status"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			company := &Company{Status: tt.status, Registered: tt.registered}
+			err := setInitialStatus(company)
+			if tt.wantField != "" {
+				var violations *apperror.ValidationError
+				if !errors.As(err, &violations) || violations.Fields[0].Field != tt.wantField {
+					t.Fatalf("setInitialStatus() = %v, want a violation of %s", err, tt.wantField)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("setInitialStatus() = %v", err)
+			}
+			if company.Status != tt.wantStatus || company.Registered == nil || *company.Registered != tt.wantRegistered {
+				t.Errorf("setInitialStatus() left %s, %v, want %s, %v", company.Status, company.Registered, tt.wantStatus, tt.wantRegistered)
+			}
+		})
+	}
+}
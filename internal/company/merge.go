@@ -0,0 +1,92 @@
+package company
+
+import (
+	"xm-microservice/pkg/apperror"
+
+	"github.com/google/uuid"
+)
+
+// MergeRule decides whose value a merged company keeps for a field
+type MergeRule string
+
+const (
+	KeepTarget MergeRule = "target"
+	KeepSource MergeRule = "source"
+)
+
+// mergeFields copies each mergeable field from the source to the target company.
+// The status is not mergeable since it only changes through transitions. The jurisdiction and the official
+// identifiers it scopes move together as identifiers. Tags are not mergeable either: the merged company
+// carries the tags of both companies.
+var mergeFields = map[string]func(target, source *Company){
+	"name":                func(target, source *Company) { target.Name = source.Name },
+	"description":         func(target, source *Company) { target.Description = source.Description },
+	"amount_of_employees": func(target, source *Company) { target.AmountOfEmployees = source.AmountOfEmployees },
+	"type":                func(target, source *Company) { target.Type = source.Type },
+	"attributes":          func(target, source *Company) { target.Attributes = source.Attributes },
+	"identifiers": func(target, source *Company) {
+		target.Jurisdiction, target.RegistrationNumber = source.Jurisdiction, source.RegistrationNumber
+		target.TaxID, target.LEI = source.TaxID, source.LEI
+	},
+}
+
+// MergeRequest names the company merged into the target and, per field, whose value wins.
+// Fields without a rule keep the value of the target.
+type MergeRequest struct {
+	SourceID uuid.UUID            `json:"source_id"`
+	Fields   map[string]MergeRule `json:"fields"`
+}
+
+// validate reports a missing or self-referencing source and unknown fields or rules
+func (m MergeRequest) validate(targetID uuid.UUID) error {
+	violations := &apperror.ValidationError{}
+	switch m.SourceID {
+	case uuid.Nil:
+		violations.Add("source_id", apperror.CodeRequired, "source_id is required")
+	case targetID:
+		violations.Add("source_id", apperror.CodeInvalidValue, "a company cannot be merged into itself")
+	}
+	for field, rule := range m.Fields {
+		if _, ok := mergeFields[field]; !ok {
+			violations.Add("fields."+field, apperror.CodeInvalidValue, "unknown field "+field)
+		} else if rule != KeepTarget && rule != KeepSource {
+			violations.Add("fields."+field, apperror.CodeInvalidValue, "rule must be target or source")
+		}
+	}
+	return violations.Err()
+}
+
+// apply returns the target company with the fields that the rules take from the source
+func (m MergeRequest) apply(target, source *Company) *Company {
+	merged := *target
+	for field, rule := range m.Fields {
+		if rule == KeepSource {
+			mergeFields[field](&merged, source)
+		}
+	}
+	merged.Tags = append(append(Tags{}, target.Tags...), source.Tags...)
+	return &merged
+}
+
+// ErrCompanyMerged reports a company that no longer exists because it was merged into another one
+var ErrCompanyMerged = apperror.New(apperror.ErrNotFound, "company was merged into another company")
+
+// MergedError carries the company that a merged company now lives on in
+type MergedError struct {
+	Into uuid.UUID
+}
+
+// Error returns the message of ErrCompanyMerged
+func (e *MergedError) Error() string {
+	return ErrCompanyMerged.Error()
+}
+
+// Unwrap exposes ErrCompanyMerged to errors.Is and errors.As
+func (e *MergedError) Unwrap() error {
+	return ErrCompanyMerged
+}
+
+// ProblemExtensions points clients to the surviving company
+func (e *MergedError) ProblemExtensions() map[string]interface{} {
+	return map[string]interface{}{"merged_into": e.Into}
+}
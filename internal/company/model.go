@@ -1,23 +1,64 @@
 package company
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
 	"github.com/google/uuid"
 )
 
+// CompanyType is the code of one of the company types kept in the company_types table
 type CompanyType string
 
-const (
-	Corporation        CompanyType = "Corporation"
-	NonProfit          CompanyType = "NonProfit"
-	Cooperative        CompanyType = "Cooperative"
-	SoleProprietorship CompanyType = "Sole Proprietorship"
-)
+// maxAttributesSize caps the size of the attributes of a company as JSON in bytes
+const maxAttributesSize = 16 * 1024
+
+// Attributes holds the custom fields of a company, which are checked against the admin-managed attribute schema
+type Attributes map[string]interface{}
+
+// Value stores the attributes as a JSON object, empty when there are none
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads the attributes from a JSONB column
+func (a *Attributes) Scan(src interface{}) error {
+	var data []byte
+	switch src := src.(type) {
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return fmt.Errorf("cannot scan %T into company attributes", src)
+	}
+	*a = nil
+	return json.Unmarshal(data, a)
+}
 
 type Company struct {
-	ID                uuid.UUID   `json:"id"`
-	Name              string      `json:"name"`
-	Description       string      `json:"description,omitempty"`
-	AmountOfEmployees *int        `json:"amount_of_employees"`
-	Registered        *bool       `json:"registered"`
-	Type              CompanyType `json:"type"`
+	ID                  uuid.UUID     `json:"id"`
+	TenantID            uuid.UUID     `json:"tenant_id"`
+	Name                string        `json:"name"`
+	Description         string        `json:"description,omitempty"`
+	AmountOfEmployees   *int          `json:"amount_of_employees"`
+	Registered          *bool         `json:"registered"`
+	Status              CompanyStatus `json:"status"`
+	Type                CompanyType   `json:"type"`
+	ParentID            *uuid.UUID    `json:"parent_id,omitempty"`
+	OwnershipPercentage *float64      `json:"ownership_percentage,omitempty"`
+	Jurisdiction        string        `json:"jurisdiction,omitempty"`
+	RegistrationNumber  string        `json:"registration_number,omitempty"`
+	TaxID               string        `json:"tax_id,omitempty"`
+	LEI                 string        `json:"lei,omitempty"`
+	Attributes          Attributes    `json:"attributes"`
+	Tags                Tags          `json:"tags"`
 }
@@ -1,62 +1,789 @@
 package company
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"xm-microservice/internal/database"
 
 	"github.com/google/uuid"
 )
 
 type Repository interface {
-	Create(company *Company) error
-	Update(id uuid.UUID, company *Company) error
-	Delete(id uuid.UUID) error
-	GetByID(id uuid.UUID) (*Company, error)
+	Create(ctx context.Context, company *Company) error
+	Update(ctx context.Context, tenantID, id uuid.UUID, company *Company) error
+	Delete(ctx context.Context, tenantID, id uuid.UUID) error
+	GetByID(ctx context.Context, tenantID, id uuid.UUID) (*Company, error)
+	GetForUpdate(ctx context.Context, tenantID, id uuid.UUID) (*Company, error)
+	FindByIdentifier(ctx context.Context, tenantID uuid.UUID, lookup Lookup) (*Company, error)
+	MergeInto(ctx context.Context, tenantID, sourceID, targetID uuid.UUID) ([]HierarchyChange, error)
+	ChangeStatus(ctx context.Context, change *StatusChange) error
+	SetParent(ctx context.Context, tenantID, id uuid.UUID, parentID *uuid.UUID, ownership *float64) error
+	Subsidiaries(ctx context.Context, tenantID, id uuid.UUID, maxDepth int) ([]Relative, error)
+	Ancestors(ctx context.Context, tenantID, id uuid.UUID, maxDepth int) ([]Relative, error)
+	GroupSummary(ctx context.Context, tenantID, id uuid.UUID) (*GroupSummary, error)
+	FindSimilar(ctx context.Context, tenantID uuid.UUID, name string, threshold float64, limit int) ([]DuplicateCandidate, error)
+	List(ctx context.Context, tenantID uuid.UUID, filter Filter, limit, offset int) ([]Company, error)
+	Search(ctx context.Context, tenantID uuid.UUID, search Search, limit, offset int) ([]SearchResult, error)
+	Stream(ctx context.Context, tenantID uuid.UUID, filter Filter, fn func(*Company) error) error
+	SnapshotTime(ctx context.Context) (time.Time, error)
+	CreateImportJob(ctx context.Context, job *ImportJob) error
+	UpdateImportJob(ctx context.Context, job *ImportJob) error
+	TouchImportJob(ctx context.Context, job *ImportJob) error
+	FailStaleImportJobs(ctx context.Context, staleAfter time.Duration, message string) (int64, error)
+	GetImportJob(ctx context.Context, tenantID, id uuid.UUID) (*ImportJob, error)
+	CreateAddress(ctx context.Context, tenantID uuid.UUID, address *Address) error
+	UpdateAddress(ctx context.Context, tenantID uuid.UUID, address *Address) error
+	DeleteAddress(ctx context.Context, tenantID, companyID, id uuid.UUID) error
+	ListAddresses(ctx context.Context, tenantID, companyID uuid.UUID) ([]Address, error)
+	CreateContact(ctx context.Context, tenantID uuid.UUID, contact *Contact) error
+	UpdateContact(ctx context.Context, tenantID uuid.UUID, contact *Contact) error
+	DeleteContact(ctx context.Context, tenantID, companyID, id uuid.UUID) error
+	ListContacts(ctx context.Context, tenantID, companyID uuid.UUID) ([]Contact, error)
+	SetTags(ctx context.Context, tenantID, id uuid.UUID, tags Tags) error
+	TagUsage(ctx context.Context, tenantID uuid.UUID, prefix string, limit, offset int) ([]TagUsage, error)
+	PutCuratedTag(ctx context.Context, tag *CuratedTag) error
+	DeleteCuratedTag(ctx context.Context, name string) error
 }
 
+// companyColumns lists the columns read into a Company, in the order scanCompany expects
+const companyColumns = `id, tenant_id, name, description, amount_of_employees, registered, status, type, parent_id, ownership_percentage, jurisdiction, registration_number, tax_id, lei, attributes, tags`
+
 type repository struct {
-	db *sql.DB
+	db      *database.DB
+	timeout time.Duration
 }
 
-// NewRepository initializes a new company repository with the database connection
-func NewRepository(db *sql.DB) Repository {
-	return &repository{db: db}
+// NewRepository initializes a new company repository with the database connection and query timeout
+func NewRepository(db *database.DB, timeout time.Duration) Repository {
+	return &repository{db: db, timeout: timeout}
 }
 
 // Create inserts a new company into the database
-func (r *repository) Create(company *Company) error {
-	query := `INSERT INTO companies (id, name, description, amount_of_employees, registered, type) VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err := r.db.Exec(query, company.ID, company.Name, company.Description, company.AmountOfEmployees, company.Registered, company.Type)
-	return err
+func (r *repository) Create(ctx context.Context, company *Company) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `INSERT INTO companies (id, tenant_id, name, normalized_name, description, amount_of_employees, status, type, parent_id, ownership_percentage, jurisdiction, registration_number, tax_id, lei, attributes, tags)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`
+	_, err := r.db.Writer(ctx).ExecContext(ctx, query, company.ID, company.TenantID, company.Name, normalizeName(company.Name), company.Description, company.AmountOfEmployees, company.Status, company.Type, company.ParentID, company.OwnershipPercentage,
+		company.Jurisdiction, company.RegistrationNumber, company.TaxID, company.LEI, company.Attributes, company.Tags)
+	return mapError(err)
+}
+
+// Update modifies the details of an existing company within a tenant. The status only changes through ChangeStatus.
+func (r *repository) Update(ctx context.Context, tenantID, id uuid.UUID, company *Company) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE companies SET name=$1, normalized_name=$2, description=$3, amount_of_employees=$4, type=$5, jurisdiction=$6, registration_number=$7, tax_id=$8, lei=$9, attributes=$10, tags=$11
+		WHERE id=$12 AND tenant_id=$13 AND merged_into IS NULL`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, company.Name, normalizeName(company.Name), company.Description, company.AmountOfEmployees, company.Type,
+		company.Jurisdiction, company.RegistrationNumber, company.TaxID, company.LEI, company.Attributes, company.Tags, id, tenantID)
+	if err != nil {
+		return mapError(err)
+	}
+	return mapError(database.ExpectRows(result))
+}
+
+// Delete removes a company of a tenant from the database based on its ID, turning its subsidiaries into
+// top-level companies. It must run in a transaction so that subsidiaries are not detached for a failed delete.
+func (r *repository) Delete(ctx context.Context, tenantID, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE companies SET parent_id=NULL, ownership_percentage=NULL WHERE parent_id=$1 AND tenant_id=$2`
+	if _, err := r.db.Writer(ctx).ExecContext(ctx, query, id, tenantID); err != nil {
+		return mapError(err)
+	}
+
+	query = `DELETE FROM companies WHERE id=$1 AND tenant_id=$2 AND merged_into IS NULL`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, id, tenantID)
+	if err != nil {
+		return mapError(err)
+	}
+	return mapError(database.ExpectRows(result))
 }
 
-// Update modifies the details of an existing company
-func (r *repository) Update(id uuid.UUID, company *Company) error {
-	query := `UPDATE companies SET name=$1, description=$2, amount_of_employees=$3, registered=$4, type=$5 WHERE id=$6`
-	_, err := r.db.Exec(query, company.Name, company.Description, company.AmountOfEmployees, company.Registered, company.Type, id)
-	return err
+// GetByID retrieves a company of a tenant by its unique identifier, or a *MergedError when it was merged
+func (r *repository) GetByID(ctx context.Context, tenantID, id uuid.UUID) (*Company, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT ` + companyColumns + `, merged_into FROM companies WHERE id=$1 AND tenant_id=$2`
+	return r.get(r.db.Reader(ctx).QueryRowContext(ctx, query, id, tenantID))
 }
 
-// Delete removes a company from the database based on its ID
-func (r *repository) Delete(id uuid.UUID) error {
-	query := `DELETE FROM companies WHERE id=$1`
-	_, err := r.db.Exec(query, id)
-	return err
+// GetForUpdate retrieves a company of a tenant like GetByID and locks it until the transaction ends
+func (r *repository) GetForUpdate(ctx context.Context, tenantID, id uuid.UUID) (*Company, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT ` + companyColumns + `, merged_into FROM companies WHERE id=$1 AND tenant_id=$2 FOR UPDATE`
+	return r.get(r.db.Writer(ctx).QueryRowContext(ctx, query, id, tenantID))
 }
 
-// GetByID retrieves a company by its unique identifier
-func (r *repository) GetByID(id uuid.UUID) (*Company, error) {
-	query := `SELECT id, name, description, amount_of_employees, registered, type FROM companies WHERE id=$1`
+// FindByIdentifier retrieves the live company of a tenant with the identifier of the lookup
+func (r *repository) FindByIdentifier(ctx context.Context, tenantID uuid.UUID, lookup Lookup) (*Company, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var row *sql.Row
+	switch lookup.Kind {
+	case IdentifierRegistrationNumber:
+		query := `SELECT ` + companyColumns + `, merged_into FROM companies WHERE tenant_id=$1 AND jurisdiction=$2 AND registration_number=$3 AND merged_into IS NULL`
+		row = r.db.Reader(ctx).QueryRowContext(ctx, query, tenantID, lookup.Jurisdiction, lookup.Value)
+	case IdentifierTaxID:
+		query := `SELECT ` + companyColumns + `, merged_into FROM companies WHERE tenant_id=$1 AND jurisdiction=$2 AND tax_id=$3 AND merged_into IS NULL`
+		row = r.db.Reader(ctx).QueryRowContext(ctx, query, tenantID, lookup.Jurisdiction, lookup.Value)
+	default:
+		query := `SELECT ` + companyColumns + `, merged_into FROM companies WHERE tenant_id=$1 AND lei=$2 AND merged_into IS NULL`
+		row = r.db.Reader(ctx).QueryRowContext(ctx, query, tenantID, lookup.Value)
+	}
+	return r.get(row)
+}
+
+// get scans a company selected with companyColumns followed by merged_into
+func (r *repository) get(row *sql.Row) (*Company, error) {
 	company := &Company{}
-	err := r.db.QueryRow(query, id).Scan(
+	var mergedInto uuid.NullUUID
+	err := row.Scan(append(companyFields(company), &mergedInto)...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if mergedInto.Valid {
+		return nil, &MergedError{Into: mergedInto.UUID}
+	}
+	return company, nil
+}
+
+// MergeInto turns the source company into a tombstone of the target and re-points the tombstones
+// of earlier merges into the source, the subsidiaries of the source and its addresses and contacts to the target.
+// It returns a hierarchy change for every re-pointed subsidiary.
+// The target must not be a subsidiary of the source, or the re-pointed subsidiaries would form a cycle.
+func (r *repository) MergeInto(ctx context.Context, tenantID, sourceID, targetID uuid.UUID) ([]HierarchyChange, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE companies SET merged_into=$1 WHERE merged_into=$2 AND tenant_id=$3`
+	if _, err := r.db.Writer(ctx).ExecContext(ctx, query, targetID, sourceID, tenantID); err != nil {
+		return nil, mapError(err)
+	}
+
+	changes, err := r.repointSubsidiaries(ctx, tenantID, sourceID, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The target keeps its registered address; the source's one becomes a trading address when both have one
+	query = `UPDATE company_addresses SET company_id=$1, updated_at=NOW(),
+		kind = CASE WHEN kind = 'registered' AND EXISTS (SELECT 1 FROM company_addresses WHERE company_id=$1 AND kind='registered') THEN 'trading' ELSE kind END
+		WHERE company_id=$2 AND tenant_id=$3`
+	if _, err := r.db.Writer(ctx).ExecContext(ctx, query, targetID, sourceID, tenantID); err != nil {
+		return nil, mapError(err)
+	}
+
+	// A contact whose email the target already has fills the blank role and phone of the target's contact
+	// and is then dropped, so that no contact is left behind on the tombstone
+	query = `UPDATE company_contacts t SET role = COALESCE(NULLIF(t.role, ''), s.role), phone = COALESCE(NULLIF(t.phone, ''), s.phone), updated_at=NOW()
+		FROM company_contacts s
+		WHERE t.company_id=$1 AND s.company_id=$2 AND s.tenant_id=$3 AND s.email <> '' AND t.email = s.email`
+	if _, err := r.db.Writer(ctx).ExecContext(ctx, query, targetID, sourceID, tenantID); err != nil {
+		return nil, mapError(err)
+	}
+
+	query = `DELETE FROM company_contacts s USING company_contacts t
+		WHERE s.company_id=$2 AND s.tenant_id=$3 AND s.email <> '' AND t.company_id=$1 AND t.email = s.email`
+	if _, err := r.db.Writer(ctx).ExecContext(ctx, query, targetID, sourceID, tenantID); err != nil {
+		return nil, mapError(err)
+	}
+
+	query = `UPDATE company_contacts SET company_id=$1, updated_at=NOW() WHERE company_id=$2 AND tenant_id=$3`
+	if _, err := r.db.Writer(ctx).ExecContext(ctx, query, targetID, sourceID, tenantID); err != nil {
+		return nil, mapError(err)
+	}
+
+	query = `UPDATE companies SET merged_into=$1, merged_at=NOW(), parent_id=NULL, ownership_percentage=NULL WHERE id=$2 AND tenant_id=$3 AND merged_into IS NULL`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, targetID, sourceID, tenantID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if err := database.ExpectRows(result); err != nil {
+		return nil, mapError(err)
+	}
+	return changes, nil
+}
+
+// repointSubsidiaries makes the target the parent of the subsidiaries of the source and describes each change
+func (r *repository) repointSubsidiaries(ctx context.Context, tenantID, sourceID, targetID uuid.UUID) ([]HierarchyChange, error) {
+	query := `UPDATE companies SET parent_id=$1 WHERE parent_id=$2 AND tenant_id=$3 RETURNING id, ownership_percentage`
+	rows, err := r.db.Writer(ctx).QueryContext(ctx, query, targetID, sourceID, tenantID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	changes := []HierarchyChange{}
+	for rows.Next() {
+		change := HierarchyChange{ParentID: &targetID, PreviousParentID: &sourceID}
+		if err := rows.Scan(&change.CompanyID, &change.OwnershipPercentage); err != nil {
+			return nil, mapError(err)
+		}
+		changes = append(changes, change)
+	}
+	return changes, mapError(rows.Err())
+}
+
+// ChangeStatus moves a company from the change's from status to its to status and records the change.
+// It reports ErrNotFound when the company is gone or no longer in the from status.
+func (r *repository) ChangeStatus(ctx context.Context, change *StatusChange) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE companies SET status=$1 WHERE id=$2 AND tenant_id=$3 AND status=$4 AND merged_into IS NULL`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, change.To, change.CompanyID, change.TenantID, change.From)
+	if err != nil {
+		return mapError(err)
+	}
+	if err := database.ExpectRows(result); err != nil {
+		return mapError(err)
+	}
+
+	query = `INSERT INTO company_status_changes (id, tenant_id, company_id, transition, from_status, to_status, reason, actor_id) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8) RETURNING created_at`
+	actorID := uuid.NullUUID{UUID: change.ActorID, Valid: change.ActorID != uuid.Nil}
+	err = r.db.Writer(ctx).QueryRowContext(ctx, query, change.ID, change.TenantID, change.CompanyID, change.Transition, change.From, change.To, change.Reason, actorID).Scan(&change.CreatedAt)
+	return mapError(err)
+}
+
+// SetParent makes parentID the parent of a company of a tenant, or detaches it when parentID is nil
+func (r *repository) SetParent(ctx context.Context, tenantID, id uuid.UUID, parentID *uuid.UUID, ownership *float64) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE companies SET parent_id=$1, ownership_percentage=$2 WHERE id=$3 AND tenant_id=$4 AND merged_into IS NULL`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, parentID, ownership, id, tenantID)
+	if err != nil {
+		return mapError(err)
+	}
+	return mapError(database.ExpectRows(result))
+}
+
+// Subsidiaries retrieves the companies below a company of a tenant up to maxDepth levels down,
+// nearest first. Depth 1 holds the direct subsidiaries.
+func (r *repository) Subsidiaries(ctx context.Context, tenantID, id uuid.UUID, maxDepth int) ([]Relative, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT ` + companyColumns + `, 1 AS depth FROM companies WHERE parent_id=$1 AND tenant_id=$2 AND merged_into IS NULL
+			UNION ALL
+			SELECT ` + qualifiedColumns("c") + `, t.depth + 1 FROM companies c JOIN tree t ON c.parent_id = t.id
+			WHERE t.depth < $3 AND c.tenant_id=$2 AND c.merged_into IS NULL
+		)
+		SELECT ` + companyColumns + `, depth FROM tree ORDER BY depth, name, id`
+	return r.relatives(ctx, query, id, tenantID, maxDepth)
+}
+
+// Ancestors retrieves the companies above a company of a tenant up to maxDepth levels up,
+// nearest first. Depth 1 holds the parent.
+func (r *repository) Ancestors(ctx context.Context, tenantID, id uuid.UUID, maxDepth int) ([]Relative, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE chain AS (
+			SELECT ` + qualifiedColumns("p") + `, 1 AS depth FROM companies p JOIN companies c ON p.id = c.parent_id
+			WHERE c.id=$1 AND c.tenant_id=$2 AND p.tenant_id=$2 AND p.merged_into IS NULL
+			UNION ALL
+			SELECT ` + qualifiedColumns("p") + `, ch.depth + 1 FROM companies p JOIN chain ch ON p.id = ch.parent_id
+			WHERE ch.depth < $3 AND p.tenant_id=$2 AND p.merged_into IS NULL
+		)
+		SELECT ` + companyColumns + `, depth FROM chain ORDER BY depth`
+	return r.relatives(ctx, query, id, tenantID, maxDepth)
+}
+
+// relatives runs a hierarchy query selecting companyColumns followed by the depth
+func (r *repository) relatives(ctx context.Context, query string, args ...interface{}) ([]Relative, error) {
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	relatives := []Relative{}
+	for rows.Next() {
+		var relative Relative
+		if err := rows.Scan(append(companyFields(&relative.Company), &relative.Depth)...); err != nil {
+			return nil, mapError(err)
+		}
+		relatives = append(relatives, relative)
+	}
+	return relatives, mapError(rows.Err())
+}
+
+// GroupSummary counts a company of a tenant and its transitive subsidiaries and sums their employees
+func (r *repository) GroupSummary(ctx context.Context, tenantID, id uuid.UUID) (*GroupSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, amount_of_employees, 0 AS depth FROM companies WHERE id=$1 AND tenant_id=$2 AND merged_into IS NULL
+			UNION ALL
+			SELECT c.id, c.amount_of_employees, t.depth + 1 FROM companies c JOIN tree t ON c.parent_id = t.id
+			WHERE t.depth < $3 AND c.tenant_id=$2 AND c.merged_into IS NULL
+		)
+		SELECT COUNT(*), COALESCE(MAX(depth), 0), COALESCE(SUM(amount_of_employees), 0) FROM tree`
+	summary := &GroupSummary{CompanyID: id}
+	err := r.db.Reader(ctx).QueryRowContext(ctx, query, id, tenantID, maxHierarchyDepth).Scan(&summary.Companies, &summary.Depth, &summary.TotalEmployees)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if summary.Companies == 0 {
+		return nil, ErrNotFound
+	}
+	summary.Subsidiaries = summary.Companies - 1
+	return summary, nil
+}
+
+// FindSimilar retrieves the companies of a tenant whose normalized name is at least threshold similar to the
+// normalized form of name, most similar first. It reads from the primary so that recent companies are found.
+func (r *repository) FindSimilar(ctx context.Context, tenantID uuid.UUID, name string, threshold float64, limit int) ([]DuplicateCandidate, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT id, name, similarity(normalized_name, $2) AS score FROM companies WHERE tenant_id=$1 AND merged_into IS NULL AND similarity(normalized_name, $2) >= $3 ORDER BY score DESC, name LIMIT $4`
+	rows, err := r.db.Reader(database.WithPrimary(ctx)).QueryContext(ctx, query, tenantID, normalizeName(name), threshold, limit)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	candidates := []DuplicateCandidate{}
+	for rows.Next() {
+		var candidate DuplicateCandidate
+		if err := rows.Scan(&candidate.ID, &candidate.Name, &candidate.Similarity); err != nil {
+			return nil, mapError(err)
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, mapError(rows.Err())
+}
+
+// List retrieves a page of the companies of a tenant that match the filter, ordered by name
+func (r *repository) List(ctx context.Context, tenantID uuid.UUID, filter Filter, limit, offset int) ([]Company, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	where, args := filter.where(tenantID)
+	args = append(args, limit, offset)
+	query := fmt.Sprintf(`SELECT %s FROM companies WHERE %s ORDER BY name, id LIMIT $%d OFFSET $%d`, companyColumns, where, len(args)-1, len(args))
+
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	companies := []Company{}
+	for rows.Next() {
+		var company Company
+		if err := scanCompany(rows, &company); err != nil {
+			return nil, mapError(err)
+		}
+		companies = append(companies, company)
+	}
+	return companies, mapError(rows.Err())
+}
+
+// Search retrieves a page of the companies of a tenant that match the search, best matches first.
+// A company matches when its name or description contains the query terms, or its name resembles the query.
+// Highlights are only built for the returned page, since ts_headline reads the whole document.
+func (r *repository) Search(ctx context.Context, tenantID uuid.UUID, search Search, limit, offset int) ([]SearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	where, args := search.Filter.where(tenantID)
+	args = append(args, search.Query, limit, offset)
+	q, limitArg, offsetArg := len(args)-2, len(args)-1, len(args)
+	query := fmt.Sprintf(`
+		WITH matches AS (
+			SELECT %[1]s, query,
+				ts_rank(search_vector, query) + GREATEST(similarity(name, $%[3]d), word_similarity($%[3]d, name)) AS rank
+			FROM companies, websearch_to_tsquery('english', $%[3]d) AS query
+			WHERE %[2]s AND (search_vector @@ query OR name %% $%[3]d OR $%[3]d <%% name)
+			ORDER BY rank DESC, name, id
+			LIMIT $%[4]d OFFSET $%[5]d
+		)
+		SELECT %[1]s, rank,
+			ts_headline('english', name, query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+			ts_headline('english', COALESCE(description, ''), query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MinWords=5, MaxWords=20')
+		FROM matches
+		ORDER BY rank DESC, name, id`, companyColumns, where, q, limitArg, offsetArg)
+
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var result SearchResult
+		err := rows.Scan(append(companyFields(&result.Company), &result.Rank, &result.Highlight.Name, &result.Highlight.Description)...)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		results = append(results, result)
+	}
+	return results, mapError(rows.Err())
+}
+
+// Stream calls fn for every company of a tenant that matches the filter, one row at a time.
+// It is not bound by the query timeout, since exports of large tenants run for as long as the client reads.
+func (r *repository) Stream(ctx context.Context, tenantID uuid.UUID, filter Filter, fn func(*Company) error) error {
+	where, args := filter.where(tenantID)
+	query := fmt.Sprintf(`SELECT %s FROM companies WHERE %s ORDER BY name, id`, companyColumns, where)
+
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return mapError(err)
+	}
+	defer rows.Close()
+
+	var company Company
+	for rows.Next() {
+		if err := scanCompany(rows, &company); err != nil {
+			return mapError(err)
+		}
+		if err := fn(&company); err != nil {
+			return err
+		}
+	}
+	return mapError(rows.Err())
+}
+
+// SnapshotTime returns the start time of the current transaction, which identifies the snapshot it reads
+func (r *repository) SnapshotTime(ctx context.Context) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var snapshot time.Time
+	err := r.db.Reader(ctx).QueryRowContext(ctx, `SELECT transaction_timestamp()`).Scan(&snapshot)
+	return snapshot, mapError(err)
+}
+
+// CreateImportJob stores a new background import job
+func (r *repository) CreateImportJob(ctx context.Context, job *ImportJob) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `INSERT INTO import_jobs (id, tenant_id, created_by, status, mode, total_rows) VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at`
+	return mapError(r.db.Writer(ctx).QueryRowContext(ctx, query, job.ID, job.TenantID, job.CreatedBy, job.Status, job.Mode, job.TotalRows).Scan(&job.CreatedAt))
+}
+
+// UpdateImportJob stores the status, report and error of an import job
+func (r *repository) UpdateImportJob(ctx context.Context, job *ImportJob) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var report []byte
+	if job.Report != nil {
+		encoded, err := json.Marshal(job.Report)
+		if err != nil {
+			return err
+		}
+		report = encoded
+	}
+
+	query := `UPDATE import_jobs SET status=$1, report=$2, error=NULLIF($3, ''), finished_at=$4, heartbeat_at=NOW() WHERE id=$5 AND tenant_id=$6`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, job.Status, report, job.Error, job.FinishedAt, job.ID, job.TenantID)
+	if err != nil {
+		return mapError(err)
+	}
+	return mapJobError(database.ExpectRows(result))
+}
+
+// TouchImportJob records that the worker of a running import job is still alive
+func (r *repository) TouchImportJob(ctx context.Context, job *ImportJob) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE import_jobs SET heartbeat_at=NOW() WHERE id=$1 AND tenant_id=$2`
+	_, err := r.db.Writer(ctx).ExecContext(ctx, query, job.ID, job.TenantID)
+	return mapError(err)
+}
+
+// FailStaleImportJobs marks unfinished import jobs of every tenant as failed when their worker
+// has not recorded a heartbeat for staleAfter, and returns how many jobs it marked
+func (r *repository) FailStaleImportJobs(ctx context.Context, staleAfter time.Duration, message string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE import_jobs SET status=$1, error=$2, finished_at=NOW()
+		WHERE status IN ($3, $4) AND heartbeat_at < NOW() - make_interval(secs => $5)`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, JobFailed, message, JobPending, JobRunning, staleAfter.Seconds())
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return result.RowsAffected()
+}
+
+// GetImportJob retrieves an import job of a tenant, always from the primary since clients poll it for progress
+func (r *repository) GetImportJob(ctx context.Context, tenantID, id uuid.UUID) (*ImportJob, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT id, tenant_id, created_by, status, mode, total_rows, report, COALESCE(error, ''), created_at, finished_at FROM import_jobs WHERE id=$1 AND tenant_id=$2`
+	job := &ImportJob{}
+	var createdBy uuid.NullUUID
+	var report []byte
+	err := r.db.Primary().QueryRowContext(ctx, query, id, tenantID).Scan(
+		&job.ID,
+		&job.TenantID,
+		&createdBy,
+		&job.Status,
+		&job.Mode,
+		&job.TotalRows,
+		&report,
+		&job.Error,
+		&job.CreatedAt,
+		&job.FinishedAt,
+	)
+	if err != nil {
+		return nil, mapJobError(err)
+	}
+
+	job.CreatedBy = createdBy.UUID
+	if report != nil {
+		job.Report = &ImportReport{}
+		if err := json.Unmarshal(report, job.Report); err != nil {
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+// CreateAddress inserts a new address of a company of a tenant
+func (r *repository) CreateAddress(ctx context.Context, tenantID uuid.UUID, address *Address) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `INSERT INTO company_addresses (id, tenant_id, company_id, kind, line1, line2, city, region, postal_code, country)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING created_at, updated_at`
+	err := r.db.Writer(ctx).QueryRowContext(ctx, query, address.ID, tenantID, address.CompanyID, address.Kind, address.Line1, address.Line2,
+		address.City, address.Region, address.PostalCode, address.Country).Scan(&address.CreatedAt, &address.UpdatedAt)
+	return mapError(err)
+}
+
+// UpdateAddress replaces an address of a company of a tenant
+func (r *repository) UpdateAddress(ctx context.Context, tenantID uuid.UUID, address *Address) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE company_addresses SET kind=$1, line1=$2, line2=$3, city=$4, region=$5, postal_code=$6, country=$7, updated_at=NOW()
+		WHERE id=$8 AND company_id=$9 AND tenant_id=$10 RETURNING created_at, updated_at`
+	err := r.db.Writer(ctx).QueryRowContext(ctx, query, address.Kind, address.Line1, address.Line2, address.City, address.Region,
+		address.PostalCode, address.Country, address.ID, address.CompanyID, tenantID).Scan(&address.CreatedAt, &address.UpdatedAt)
+	return mapAddressError(err)
+}
+
+// DeleteAddress removes an address of a company of a tenant
+func (r *repository) DeleteAddress(ctx context.Context, tenantID, companyID, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.Writer(ctx).ExecContext(ctx, `DELETE FROM company_addresses WHERE id=$1 AND company_id=$2 AND tenant_id=$3`, id, companyID, tenantID)
+	if err != nil {
+		return mapAddressError(err)
+	}
+	return mapAddressError(database.ExpectRows(result))
+}
+
+// ListAddresses retrieves the addresses of a company of a tenant, the registered address first
+func (r *repository) ListAddresses(ctx context.Context, tenantID, companyID uuid.UUID) ([]Address, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT id, company_id, kind, line1, line2, city, region, postal_code, country, created_at, updated_at
+		FROM company_addresses WHERE company_id=$1 AND tenant_id=$2 ORDER BY kind <> 'registered', created_at, id`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, companyID, tenantID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	addresses := []Address{}
+	for rows.Next() {
+		var a Address
+		if err := rows.Scan(&a.ID, &a.CompanyID, &a.Kind, &a.Line1, &a.Line2, &a.City, &a.Region, &a.PostalCode, &a.Country, &a.CreatedAt, &a.UpdatedAt); err != nil {
+			return nil, mapError(err)
+		}
+		addresses = append(addresses, a)
+	}
+	return addresses, mapError(rows.Err())
+}
+
+// CreateContact inserts a new contact of a company of a tenant
+func (r *repository) CreateContact(ctx context.Context, tenantID uuid.UUID, contact *Contact) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `INSERT INTO company_contacts (id, tenant_id, company_id, name, role, email, phone) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at, updated_at`
+	err := r.db.Writer(ctx).QueryRowContext(ctx, query, contact.ID, tenantID, contact.CompanyID, contact.Name, contact.Role, contact.Email, contact.Phone).
+		Scan(&contact.CreatedAt, &contact.UpdatedAt)
+	return mapError(err)
+}
+
+// UpdateContact replaces a contact of a company of a tenant
+func (r *repository) UpdateContact(ctx context.Context, tenantID uuid.UUID, contact *Contact) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE company_contacts SET name=$1, role=$2, email=$3, phone=$4, updated_at=NOW()
+		WHERE id=$5 AND company_id=$6 AND tenant_id=$7 RETURNING created_at, updated_at`
+	err := r.db.Writer(ctx).QueryRowContext(ctx, query, contact.Name, contact.Role, contact.Email, contact.Phone, contact.ID, contact.CompanyID, tenantID).
+		Scan(&contact.CreatedAt, &contact.UpdatedAt)
+	return mapContactError(err)
+}
+
+// DeleteContact removes a contact of a company of a tenant
+func (r *repository) DeleteContact(ctx context.Context, tenantID, companyID, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.Writer(ctx).ExecContext(ctx, `DELETE FROM company_contacts WHERE id=$1 AND company_id=$2 AND tenant_id=$3`, id, companyID, tenantID)
+	if err != nil {
+		return mapContactError(err)
+	}
+	return mapContactError(database.ExpectRows(result))
+}
+
+// ListContacts retrieves the contacts of a company of a tenant ordered by name
+func (r *repository) ListContacts(ctx context.Context, tenantID, companyID uuid.UUID) ([]Contact, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT id, company_id, name, role, email, phone, created_at, updated_at
+		FROM company_contacts WHERE company_id=$1 AND tenant_id=$2 ORDER BY name, id`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, companyID, tenantID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	contacts := []Contact{}
+	for rows.Next() {
+		var c Contact
+		if err := rows.Scan(&c.ID, &c.CompanyID, &c.Name, &c.Role, &c.Email, &c.Phone, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, mapError(err)
+		}
+		contacts = append(contacts, c)
+	}
+	return contacts, mapError(rows.Err())
+}
+
+// SetTags replaces the tags of a live company of a tenant
+func (r *repository) SetTags(ctx context.Context, tenantID, id uuid.UUID, tags Tags) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE companies SET tags=$1 WHERE id=$2 AND tenant_id=$3 AND merged_into IS NULL`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, tags, id, tenantID)
+	if err != nil {
+		return mapError(err)
+	}
+	return mapError(database.ExpectRows(result))
+}
+
+// TagUsage lists the curated tags and the tags the live companies of a tenant carry, starting with prefix,
+// the most used first
+func (r *repository) TagUsage(ctx context.Context, tenantID uuid.UUID, prefix string, limit, offset int) ([]TagUsage, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `WITH usage AS (
+			SELECT tag, COUNT(*) AS count FROM companies, unnest(tags) AS tag
+			WHERE tenant_id=$1 AND merged_into IS NULL GROUP BY tag
+		)
+		SELECT COALESCE(u.tag, c.name) AS name, COALESCE(c.description, ''), c.name IS NOT NULL, COALESCE(u.count, 0) AS count
+		FROM usage u FULL OUTER JOIN curated_tags c ON c.name = u.tag
+		WHERE COALESCE(u.tag, c.name) LIKE $2 || '%'
+		ORDER BY count DESC, name LIMIT $3 OFFSET $4`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, tenantID, escapeLike(prefix), limit, offset)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	tags := []TagUsage{}
+	for rows.Next() {
+		var t TagUsage
+		if err := rows.Scan(&t.Name, &t.Description, &t.Curated, &t.Count); err != nil {
+			return nil, mapError(err)
+		}
+		tags = append(tags, t)
+	}
+	return tags, mapError(rows.Err())
+}
+
+// PutCuratedTag creates a curated tag or updates its description
+func (r *repository) PutCuratedTag(ctx context.Context, tag *CuratedTag) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `INSERT INTO curated_tags (name, description) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET description=EXCLUDED.description, updated_at=NOW()
+		RETURNING created_at, updated_at`
+	err := r.db.Writer(ctx).QueryRowContext(ctx, query, tag.Name, tag.Description).Scan(&tag.CreatedAt, &tag.UpdatedAt)
+	return mapError(err)
+}
+
+// DeleteCuratedTag removes a tag from the curated ones. Companies keep it as a free-form tag.
+func (r *repository) DeleteCuratedTag(ctx context.Context, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.Writer(ctx).ExecContext(ctx, `DELETE FROM curated_tags WHERE name=$1`, name)
+	if err != nil {
+		return mapCuratedTagError(err)
+	}
+	return mapCuratedTagError(database.ExpectRows(result))
+}
+
+// scanCompany reads a row selected with companyColumns into company
+func scanCompany(row interface{ Scan(...interface{}) error }, company *Company) error {
+	return row.Scan(companyFields(company)...)
+}
+
+// companyFields returns the scan destinations of companyColumns in company
+func companyFields(company *Company) []interface{} {
+	return []interface{}{
 		&company.ID,
+		&company.TenantID,
 		&company.Name,
 		&company.Description,
 		&company.AmountOfEmployees,
 		&company.Registered,
+		&company.Status,
 		&company.Type,
-	)
-	if err != nil {
-		return nil, err
+		&company.ParentID,
+		&company.OwnershipPercentage,
+		&company.Jurisdiction,
+		&company.RegistrationNumber,
+		&company.TaxID,
+		&company.LEI,
+		&company.Attributes,
+		&company.Tags,
 	}
-	return company, nil
+}
+
+// qualifiedColumns prefixes each of companyColumns with a table alias
+func qualifiedColumns(alias string) string {
+	return alias + "." + strings.ReplaceAll(companyColumns, ", ", ", "+alias+".")
 }
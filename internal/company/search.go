@@ -0,0 +1,48 @@
+package company
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"xm-microservice/pkg/apperror"
+)
+
+// maxSearchQueryLength caps the length of a search query in characters
+const maxSearchQueryLength = 200
+
+// Search is a ranked full-text and fuzzy query over company names and descriptions
+type Search struct {
+	Query  string
+	Filter Filter
+}
+
+// SearchResult is a company matching a search, with its relevance and highlighted matches
+type SearchResult struct {
+	Company
+	Rank      float64   `json:"rank"`
+	Highlight Highlight `json:"highlight"`
+}
+
+// Highlight holds the matched terms of a search result wrapped in <mark> tags.
+// The text is not HTML-escaped, so clients rendering it as HTML must escape everything outside the tags.
+type Highlight struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// ParseSearch reads a Search from the q parameter and the listing filters, reporting every invalid parameter
+func ParseSearch(query url.Values) (Search, error) {
+	filter, err := ParseFilter(query)
+	violations := &apperror.ValidationError{}
+	errors.As(err, &violations)
+
+	search := Search{Query: strings.TrimSpace(query.Get("q")), Filter: filter}
+	if search.Query == "" {
+		violations.Add("q", apperror.CodeRequired, "q is required")
+	} else if utf8.RuneCountInString(search.Query) > maxSearchQueryLength {
+		violations.Add("q", apperror.CodeTooLong, "q must be at most 200 characters")
+	}
+	return search, violations.Err()
+}
@@ -1,87 +1,981 @@
 package company
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"xm-microservice/internal/attributeschema"
+	"xm-microservice/internal/companytype"
+	"xm-microservice/internal/database"
+	"xm-microservice/pkg/apperror"
 
 	"github.com/google/uuid"
 )
 
 // Service defines the business logic interface for companies
 type Service interface {
-	CreateCompany(company *Company) error
-	UpdateCompany(id uuid.UUID, company *Company) error
-	DeleteCompany(id uuid.UUID) error
-	GetCompanyByID(id uuid.UUID) (*Company, error)
+	CreateCompany(ctx context.Context, tenantID uuid.UUID, company *Company, force bool) ([]DuplicateCandidate, error)
+	UpdateCompany(ctx context.Context, tenantID, id uuid.UUID, company *Company) (*Company, *TagChange, error)
+	DeleteCompany(ctx context.Context, tenantID, id uuid.UUID) error
+	GetCompanyByID(ctx context.Context, tenantID, id uuid.UUID) (*Company, error)
+	GetCompanyDetails(ctx context.Context, tenantID, id uuid.UUID, expand Expand) (*CompanyDetails, error)
+	LookupCompany(ctx context.Context, tenantID uuid.UUID, lookup Lookup) (*Company, error)
+	MergeCompanies(ctx context.Context, tenantID, targetID uuid.UUID, request MergeRequest) (*Company, []HierarchyChange, error)
+	TransitionCompany(ctx context.Context, tenantID, id uuid.UUID, name, reason string, actor Actor) (*Company, *StatusChange, error)
+	SetParent(ctx context.Context, tenantID, id uuid.UUID, request ParentRequest) (*Company, *HierarchyChange, error)
+	RemoveParent(ctx context.Context, tenantID, id uuid.UUID) (*Company, *HierarchyChange, error)
+	ListSubsidiaries(ctx context.Context, tenantID, id uuid.UUID, transitive bool) ([]Relative, error)
+	ListAncestors(ctx context.Context, tenantID, id uuid.UUID, transitive bool) ([]Relative, error)
+	GetGroupSummary(ctx context.Context, tenantID, id uuid.UUID) (*GroupSummary, error)
+	ListCompanies(ctx context.Context, tenantID uuid.UUID, filter Filter, limit, offset int) ([]Company, error)
+	SearchCompanies(ctx context.Context, tenantID uuid.UUID, search Search, limit, offset int) ([]SearchResult, error)
+	ExportCompanies(ctx context.Context, tenantID uuid.UUID, filter Filter, begin func(snapshot time.Time) error, fn func(*Company) error) error
+	ImportCompanies(ctx context.Context, tenantID uuid.UUID, rows []ImportRow, mode ImportMode) (*ImportReport, error)
+	StartImportJob(ctx context.Context, tenantID, userID uuid.UUID, mode ImportMode, rows []ImportRow, done ImportJobDone) (*ImportJob, error)
+	GetImportJob(ctx context.Context, tenantID, id uuid.UUID) (*ImportJob, error)
+	FailStaleImportJobs(ctx context.Context) (int64, error)
+	Shutdown(ctx context.Context) error
+	ListAddresses(ctx context.Context, tenantID, companyID uuid.UUID) ([]Address, error)
+	CreateAddress(ctx context.Context, tenantID, companyID uuid.UUID, address *Address) error
+	UpdateAddress(ctx context.Context, tenantID, companyID, id uuid.UUID, address *Address) error
+	DeleteAddress(ctx context.Context, tenantID, companyID, id uuid.UUID) error
+	ListContacts(ctx context.Context, tenantID, companyID uuid.UUID) ([]Contact, error)
+	CreateContact(ctx context.Context, tenantID, companyID uuid.UUID, contact *Contact) error
+	UpdateContact(ctx context.Context, tenantID, companyID, id uuid.UUID, contact *Contact) error
+	DeleteContact(ctx context.Context, tenantID, companyID, id uuid.UUID) error
+	AddTags(ctx context.Context, tenantID, id uuid.UUID, tags Tags) (*Company, *TagChange, error)
+	ReplaceTags(ctx context.Context, tenantID, id uuid.UUID, tags Tags) (*Company, *TagChange, error)
+	RemoveTag(ctx context.Context, tenantID, id uuid.UUID, tag string) (*Company, *TagChange, error)
+	ListTags(ctx context.Context, tenantID uuid.UUID, prefix string, limit, offset int) ([]TagUsage, error)
+	PutCuratedTag(ctx context.Context, tag *CuratedTag) error
+	DeleteCuratedTag(ctx context.Context, name string) error
+}
+
+// TypeSource provides the current company types for validation
+type TypeSource interface {
+	Catalog(ctx context.Context) (companytype.Catalog, error)
+}
+
+// AttributeSchemaSource provides the current attribute schema for validation
+type AttributeSchemaSource interface {
+	Validator(ctx context.Context) (*attributeschema.Validator, error)
+}
+
+// transactor runs functions in transactions, as database.TxManager does
+type transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	WithinTxOptions(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error
+	WithinReadTx(ctx context.Context, isolation sql.IsolationLevel, fn func(ctx context.Context) error) error
 }
 
 type service struct {
-	repo Repository
+	repo       Repository
+	tx         transactor
+	types      TypeSource
+	attributes AttributeSchemaSource
+	duplicates DuplicateCheck
+
+	// jobs tracks the workers of background imports, which run under jobsCtx until Shutdown cancels it
+	jobs     sync.WaitGroup
+	jobsCtx  context.Context
+	stopJobs context.CancelFunc
+}
+
+// NewService initializes a new company service with the repository, transaction manager, company types,
+// attribute schema and near-duplicate detection
+func NewService(repo Repository, tx *database.TxManager, types TypeSource, attributes AttributeSchemaSource, duplicates DuplicateCheck) Service {
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	return &service{
+		repo:       repo,
+		tx:         tx,
+		types:      types,
+		attributes: attributes,
+		duplicates: duplicates,
+		jobsCtx:    jobsCtx,
+		stopJobs:   stopJobs,
+	}
 }
 
-// NewService initializes a new company service with the repository
-func NewService(repo Repository) Service {
-	return &service{repo: repo}
+// companyRules is the admin-managed reference data that companies are validated against
+type companyRules struct {
+	types      companytype.Catalog
+	attributes *attributeschema.Validator
 }
 
-// CreateCompany validates and creates a new company
-func (s *service) CreateCompany(company *Company) error {
-	if err := validateCompany(company); err != nil {
-		return err
+// rules loads the current company types and attribute schema
+func (s *service) rules(ctx context.Context) (companyRules, error) {
+	types, err := s.types.Catalog(ctx)
+	if err != nil {
+		return companyRules{}, err
+	}
+	attributes, err := s.attributes.Validator(ctx)
+	if err != nil {
+		return companyRules{}, err
+	}
+	return companyRules{types: types, attributes: attributes}, nil
+}
+
+// CreateCompany validates and creates a new company within a tenant.
+// Unless force is set, existing companies with a similar name are returned as candidates; with the reject
+// action they prevent the creation and come back in a *DuplicateError, with the warn action the company is
+// created anyway.
+func (s *service) CreateCompany(ctx context.Context, tenantID uuid.UUID, company *Company, force bool) ([]DuplicateCandidate, error) {
+	rules, err := s.rules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCompany(company, rules); err != nil {
+		return nil, err
+	}
+	if err := setInitialStatus(company); err != nil {
+		return nil, err
+	}
+	if company.ParentID != nil {
+		request := ParentRequest{ParentID: company.ParentID, OwnershipPercentage: company.OwnershipPercentage}
+		if err := request.validate(uuid.Nil); err != nil {
+			return nil, err
+		}
+		if _, err := s.repo.GetByID(database.WithPrimary(ctx), tenantID, *company.ParentID); err != nil {
+			return nil, parentError(err)
+		}
+	} else if company.OwnershipPercentage != nil {
+		violations := &apperror.ValidationError{}
+		violations.Add("ownership_percentage", apperror.CodeInvalidValue, "ownership_percentage requires a parent_id")
+		return nil, violations
+	}
+
+	var candidates []DuplicateCandidate
+	if !force && normalizeName(company.Name) != "" {
+		var err error
+		candidates, err = s.repo.FindSimilar(ctx, tenantID, company.Name, s.duplicates.Threshold, maxDuplicateCandidates)
+		if err != nil {
+			return nil, err
+		}
+		if len(candidates) > 0 && s.duplicates.Action == DuplicateReject {
+			return candidates, &DuplicateError{Candidates: candidates}
+		}
 	}
 
 	company.ID = uuid.New()
-	return s.repo.Create(company)
+	company.TenantID = tenantID
+	return candidates, s.repo.Create(ctx, company)
+}
+
+// UpdateCompany validates and updates an existing company within a tenant.
+// The status only changes through transitions, so a status or registered value that differs from the
+// current one is rejected, and dissolved companies cannot be updated at all. The parent and ownership only
+// change through the hierarchy endpoints and are kept. It returns the stored company and how the tags changed.
+func (s *service) UpdateCompany(ctx context.Context, tenantID, id uuid.UUID, company *Company) (*Company, *TagChange, error) {
+	rules, err := s.rules(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var change *TagChange
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		current, err := s.repo.GetForUpdate(ctx, tenantID, id)
+		if err != nil {
+			return err
+		}
+		// Clients that do not send attributes keep the ones the company has
+		if company.Attributes == nil {
+			company.Attributes = current.Attributes
+		}
+		// Likewise for tags, which also have endpoints of their own
+		if company.Tags == nil {
+			company.Tags = current.Tags
+		}
+		if err := validateCompany(company, rules, current.Type); err != nil {
+			return err
+		}
+		if current.Status == StatusDissolved {
+			return ErrCompanyDissolved
+		}
+
+		violations := &apperror.ValidationError{}
+		if company.Status != "" && company.Status != current.Status {
+			violations.Add("status", apperror.CodeInvalidValue, "the status can only be changed through a transition")
+		}
+		if company.Registered != nil && *company.Registered != *current.Registered {
+			violations.Add("registered", apperror.CodeInvalidValue, "registered follows the status and can only be changed through a transition")
+		}
+		if err := violations.Err(); err != nil {
+			return err
+		}
+
+		company.ID = id
+		company.TenantID = tenantID
+		company.Status = current.Status
+		company.Registered = current.Registered
+		company.ParentID = current.ParentID
+		company.OwnershipPercentage = current.OwnershipPercentage
+		change = diffTags(current.Tags, company.Tags)
+		return s.repo.Update(ctx, tenantID, id, company)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return company, change, nil
+}
+
+// TransitionCompany moves a company of a tenant to another status through the named transition,
+// after checking the transition's guards against the company's current status, the reason and the actor
+func (s *service) TransitionCompany(ctx context.Context, tenantID, id uuid.UUID, name, reason string, actor Actor) (*Company, *StatusChange, error) {
+	var company *Company
+	var change *StatusChange
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		company, err = s.repo.GetForUpdate(ctx, tenantID, id)
+		if err != nil {
+			return err
+		}
+		transition, err := checkTransition(name, company.Status, reason, actor)
+		if err != nil {
+			return err
+		}
+
+		change = &StatusChange{
+			ID:         uuid.New(),
+			TenantID:   tenantID,
+			CompanyID:  id,
+			Transition: name,
+			From:       company.Status,
+			To:         transition.To,
+			Reason:     reason,
+			ActorID:    actor.ID,
+		}
+		if err := s.repo.ChangeStatus(ctx, change); err != nil {
+			return err
+		}
+
+		registered := transition.To == StatusRegistered
+		company.Status = transition.To
+		company.Registered = &registered
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return company, change, nil
+}
+
+// DeleteCompany removes a company of a tenant by its ID, leaving its subsidiaries without a parent.
+// The tombstones of the companies merged into it go with it through the merged_into foreign key.
+func (s *service) DeleteCompany(ctx context.Context, tenantID, id uuid.UUID) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return s.repo.Delete(ctx, tenantID, id)
+	})
+}
+
+// GetCompanyByID retrieves a company of a tenant by its ID
+func (s *service) GetCompanyByID(ctx context.Context, tenantID, id uuid.UUID) (*Company, error) {
+	return s.repo.GetByID(ctx, tenantID, id)
+}
+
+// GetCompanyDetails retrieves a company of a tenant with the expanded sub-resources
+func (s *service) GetCompanyDetails(ctx context.Context, tenantID, id uuid.UUID, expand Expand) (*CompanyDetails, error) {
+	company, err := s.repo.GetByID(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &CompanyDetails{Company: company}
+	if expand.Addresses {
+		addresses, err := s.repo.ListAddresses(ctx, tenantID, id)
+		if err != nil {
+			return nil, err
+		}
+		details.Addresses = &addresses
+	}
+	if expand.Contacts {
+		contacts, err := s.repo.ListContacts(ctx, tenantID, id)
+		if err != nil {
+			return nil, err
+		}
+		details.Contacts = &contacts
+	}
+	return details, nil
+}
+
+// LookupCompany retrieves the company of a tenant that has an official identifier
+func (s *service) LookupCompany(ctx context.Context, tenantID uuid.UUID, lookup Lookup) (*Company, error) {
+	return s.repo.FindByIdentifier(ctx, tenantID, lookup)
+}
+
+// MergeCompanies folds the source company of the request into the target company in one transaction.
+// The target takes the source's value for every field whose rule says so, and the source becomes a tombstone
+// pointing to the target. It returns the hierarchy changes of the target and the re-pointed subsidiaries.
+func (s *service) MergeCompanies(ctx context.Context, tenantID, targetID uuid.UUID, request MergeRequest) (*Company, []HierarchyChange, error) {
+	if err := request.validate(targetID); err != nil {
+		return nil, nil, err
+	}
+
+	rules, err := s.rules(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var merged *Company
+	var changes []HierarchyChange
+	err = s.tx.WithinTxOptions(ctx, hierarchyTx, func(ctx context.Context) error {
+		// Both companies are locked in ID order so that concurrent merges of the same pair cannot deadlock
+		locked := map[uuid.UUID]*Company{}
+		ids := []uuid.UUID{targetID, request.SourceID}
+		if bytes.Compare(ids[1][:], ids[0][:]) < 0 {
+			ids[0], ids[1] = ids[1], ids[0]
+		}
+		for _, id := range ids {
+			company, err := s.repo.GetForUpdate(ctx, tenantID, id)
+			if err != nil && id == request.SourceID {
+				return sourceError(err)
+			}
+			if err != nil {
+				return err
+			}
+			locked[id] = company
+		}
+
+		if locked[targetID].Status == StatusDissolved {
+			return ErrCompanyDissolved
+		}
+
+		merged = request.apply(locked[targetID], locked[request.SourceID])
+		if err := validateCompany(merged, rules, locked[targetID].Type, locked[request.SourceID].Type); err != nil {
+			return err
+		}
+
+		// A target below the source takes the source's place first, so that the subsidiaries
+		// re-pointed from the source to the target cannot include one of the target's ancestors
+		ancestors, err := s.repo.Ancestors(ctx, tenantID, targetID, maxHierarchyDepth)
+		if err != nil {
+			return err
+		}
+		// The source's subsidiaries, which include the target when it is below the source, move one level
+		// below the target
+		subsidiaries, err := s.repo.Subsidiaries(ctx, tenantID, request.SourceID, maxHierarchyDepth)
+		if err != nil {
+			return err
+		}
+		// A retried transaction starts over with no changes
+		changes = nil
+		if containsCompany(ancestors, request.SourceID) {
+			source := locked[request.SourceID]
+			sourceAncestors, err := s.repo.Ancestors(ctx, tenantID, request.SourceID, maxHierarchyDepth)
+			if err != nil {
+				return err
+			}
+			ancestors = sourceAncestors
+			if err := s.repo.SetParent(ctx, tenantID, targetID, source.ParentID, source.OwnershipPercentage); err != nil {
+				return err
+			}
+			changes = append(changes, HierarchyChange{CompanyID: targetID, ParentID: source.ParentID, PreviousParentID: merged.ParentID, OwnershipPercentage: source.OwnershipPercentage})
+			merged.ParentID, merged.OwnershipPercentage = source.ParentID, source.OwnershipPercentage
+		}
+		if err := checkDepth(len(ancestors), subsidiaries); err != nil {
+			return err
+		}
+		repointed, err := s.repo.MergeInto(ctx, tenantID, request.SourceID, targetID)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, repointed...)
+		return s.repo.Update(ctx, tenantID, targetID, merged)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return merged, changes, nil
+}
+
+// hierarchyTx isolates changes to the company hierarchy, so that concurrent changes cannot combine into a cycle
+var hierarchyTx = &sql.TxOptions{Isolation: sql.LevelSerializable}
+
+// SetParent makes another company of the tenant the parent of a company, refusing parents that would create a cycle
+func (s *service) SetParent(ctx context.Context, tenantID, id uuid.UUID, request ParentRequest) (*Company, *HierarchyChange, error) {
+	if err := request.validate(id); err != nil {
+		return nil, nil, err
+	}
+
+	var company *Company
+	var change *HierarchyChange
+	err := s.tx.WithinTxOptions(ctx, hierarchyTx, func(ctx context.Context) error {
+		var err error
+		company, err = s.repo.GetForUpdate(ctx, tenantID, id)
+		if err != nil {
+			return err
+		}
+		if _, err := s.repo.GetForUpdate(ctx, tenantID, *request.ParentID); err != nil {
+			return parentError(err)
+		}
+
+		ancestors, err := s.repo.Ancestors(ctx, tenantID, *request.ParentID, maxHierarchyDepth)
+		if err != nil {
+			return err
+		}
+		if containsCompany(ancestors, id) {
+			return ErrHierarchyCycle
+		}
+		subsidiaries, err := s.repo.Subsidiaries(ctx, tenantID, id, maxHierarchyDepth)
+		if err != nil {
+			return err
+		}
+		// The company goes one level below its new parent, and its subsidiaries with it
+		if err := checkDepth(len(ancestors)+1, subsidiaries); err != nil {
+			return err
+		}
+
+		if err := s.repo.SetParent(ctx, tenantID, id, request.ParentID, request.OwnershipPercentage); err != nil {
+			return err
+		}
+		change = &HierarchyChange{CompanyID: id, ParentID: request.ParentID, PreviousParentID: company.ParentID, OwnershipPercentage: request.OwnershipPercentage}
+		company.ParentID, company.OwnershipPercentage = request.ParentID, request.OwnershipPercentage
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return company, change, nil
+}
+
+// RemoveParent turns a company of a tenant into a top-level company
+func (s *service) RemoveParent(ctx context.Context, tenantID, id uuid.UUID) (*Company, *HierarchyChange, error) {
+	var company *Company
+	var change *HierarchyChange
+	err := s.tx.WithinTxOptions(ctx, hierarchyTx, func(ctx context.Context) error {
+		var err error
+		company, err = s.repo.GetForUpdate(ctx, tenantID, id)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.SetParent(ctx, tenantID, id, nil, nil); err != nil {
+			return err
+		}
+		change = &HierarchyChange{CompanyID: id, PreviousParentID: company.ParentID}
+		company.ParentID, company.OwnershipPercentage = nil, nil
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return company, change, nil
+}
+
+// ListSubsidiaries retrieves the direct subsidiaries of a company of a tenant, or all of them when transitive is set
+func (s *service) ListSubsidiaries(ctx context.Context, tenantID, id uuid.UUID, transitive bool) ([]Relative, error) {
+	if _, err := s.repo.GetByID(ctx, tenantID, id); err != nil {
+		return nil, err
+	}
+	return s.repo.Subsidiaries(ctx, tenantID, id, hierarchyDepth(transitive))
 }
 
-// UpdateCompany validates and updates an existing company
-func (s *service) UpdateCompany(id uuid.UUID, company *Company) error {
-	if err := validateCompany(company); err != nil {
+// ListAncestors retrieves the parent of a company of a tenant, or its whole chain of owners when transitive is set
+func (s *service) ListAncestors(ctx context.Context, tenantID, id uuid.UUID, transitive bool) ([]Relative, error) {
+	if _, err := s.repo.GetByID(ctx, tenantID, id); err != nil {
+		return nil, err
+	}
+	return s.repo.Ancestors(ctx, tenantID, id, hierarchyDepth(transitive))
+}
+
+// GetGroupSummary aggregates a company of a tenant and all of its transitive subsidiaries
+func (s *service) GetGroupSummary(ctx context.Context, tenantID, id uuid.UUID) (*GroupSummary, error) {
+	if _, err := s.repo.GetByID(ctx, tenantID, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GroupSummary(ctx, tenantID, id)
+}
+
+// hierarchyDepth returns how many levels a hierarchy listing follows
+func hierarchyDepth(transitive bool) int {
+	if transitive {
+		return maxHierarchyDepth
+	}
+	return 1
+}
+
+// parentError reports a missing or merged parent as an invalid parent_id
+func parentError(err error) error {
+	violations := &apperror.ValidationError{}
+	switch {
+	case errors.Is(err, ErrCompanyMerged):
+		violations.Add("parent_id", apperror.CodeInvalidValue, "the parent company was merged into another company")
+	case errors.Is(err, ErrNotFound):
+		violations.Add("parent_id", apperror.CodeInvalidValue, "the parent company does not exist")
+	default:
 		return err
 	}
-	return s.repo.Update(id, company)
+	return violations
 }
 
-// DeleteCompany removes a company by its ID
-func (s *service) DeleteCompany(id uuid.UUID) error {
-	return s.repo.Delete(id)
+// sourceError reports a missing or already merged merge source as an invalid source_id
+func sourceError(err error) error {
+	violations := &apperror.ValidationError{}
+	switch {
+	case errors.Is(err, ErrCompanyMerged):
+		violations.Add("source_id", apperror.CodeInvalidValue, "the source company was already merged")
+	case errors.Is(err, ErrNotFound):
+		violations.Add("source_id", apperror.CodeInvalidValue, "the source company does not exist")
+	default:
+		return err
+	}
+	return violations
+}
+
+// ListCompanies retrieves a page of the companies of a tenant that match the filter
+func (s *service) ListCompanies(ctx context.Context, tenantID uuid.UUID, filter Filter, limit, offset int) ([]Company, error) {
+	return s.repo.List(ctx, tenantID, filter, limit, offset)
 }
 
-// GetCompanyByID retrieves a company by its ID
-func (s *service) GetCompanyByID(id uuid.UUID) (*Company, error) {
-	return s.repo.GetByID(id)
+// SearchCompanies retrieves a page of the companies of a tenant that match the search, best matches first
+func (s *service) SearchCompanies(ctx context.Context, tenantID uuid.UUID, search Search, limit, offset int) ([]SearchResult, error) {
+	return s.repo.Search(ctx, tenantID, search, limit, offset)
 }
 
-// validateCompany checks the business rules for company creation and updates
-func validateCompany(company *Company) error {
-	if company.Name == "" || len(company.Name) > 15 {
-		return errors.New("invalid company name: must be non-empty and up to 15 characters")
+// ExportCompanies streams the companies of a tenant that match the filter from a single consistent snapshot.
+// begin is called with the snapshot time before the first company is passed to fn.
+func (s *service) ExportCompanies(ctx context.Context, tenantID uuid.UUID, filter Filter, begin func(snapshot time.Time) error, fn func(*Company) error) error {
+	// One repeatable-read snapshot on the replica keeps the file consistent without loading the primary
+	return s.tx.WithinReadTx(ctx, sql.LevelRepeatableRead, func(ctx context.Context) error {
+		at, err := s.repo.SnapshotTime(ctx)
+		if err != nil {
+			return err
+		}
+		if err := begin(at); err != nil {
+			return err
+		}
+		return s.repo.Stream(ctx, tenantID, filter, fn)
+	})
+}
+
+// ImportCompanies validates every row and creates the valid companies of a tenant.
+// In atomic mode nothing is created unless every row succeeds. In best-effort mode every valid row is created.
+// Row failures are reported in the returned report, while an error means the import could not be completed;
+// the report then still lists what was created before it stopped.
+func (s *service) ImportCompanies(ctx context.Context, tenantID uuid.UUID, rows []ImportRow, mode ImportMode) (*ImportReport, error) {
+	rules, err := s.rules(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	if company.AmountOfEmployees == nil {
-		return errors.New("amount of employees is required")
+	report := &ImportReport{Mode: mode, Total: len(rows), Rows: make([]RowResult, len(rows))}
+	for i := range rows {
+		report.Rows[i].Line = rows[i].Line
+		err := rows[i].Err
+		if err == nil {
+			err = validateCompany(&rows[i].Company, rules)
+		}
+		if err == nil {
+			err = setInitialStatus(&rows[i].Company)
+		}
+		// Imports create top-level companies; parents are set afterwards through the hierarchy endpoints
+		rows[i].Company.ParentID, rows[i].Company.OwnershipPercentage = nil, nil
+		if err != nil {
+			report.fail(i, err)
+		}
 	}
 
-	if *company.AmountOfEmployees < 0 {
-		return errors.New("invalid amount of employees: cannot be negative")
+	if mode == ImportBestEffort {
+		return report, s.importEach(ctx, tenantID, rows, report)
 	}
+	return report, s.importAll(ctx, tenantID, rows, report)
+}
+
+// importEach creates the valid rows one by one, recording conflicts per row
+func (s *service) importEach(ctx context.Context, tenantID uuid.UUID, rows []ImportRow, report *ImportReport) error {
+	for i := range rows {
+		if report.Rows[i].Status != "" {
+			continue
+		}
 
-	if company.Registered == nil {
-		return errors.New("registered status is required")
+		company := rows[i].Company
+		company.ID = uuid.New()
+		company.TenantID = tenantID
+		if err := s.repo.Create(ctx, &company); err != nil {
+			if apperror.HTTPStatus(err) >= http.StatusInternalServerError {
+				report.skipRemaining("not imported because the import was aborted")
+				return err
+			}
+			report.fail(i, err)
+			continue
+		}
+		report.created(i, company)
 	}
+	return nil
+}
 
-	if company.Type == "" {
-		return errors.New("company type is required")
+// importAll creates every row in one transaction, or none if any row is invalid or fails
+func (s *service) importAll(ctx context.Context, tenantID uuid.UUID, rows []ImportRow, report *ImportReport) error {
+	if report.Failed > 0 {
+		report.skipRemaining("not imported because other rows failed")
+		return nil
 	}
 
-	switch company.Type {
-	case "Corporation", "NonProfit", "Cooperative", "Sole Proprietorship":
+	var companies []Company
+	failedRow := -1
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		companies = companies[:0]
+		failedRow = -1
+		for i := range rows {
+			company := rows[i].Company
+			company.ID = uuid.New()
+			company.TenantID = tenantID
+			if err := s.repo.Create(ctx, &company); err != nil {
+				failedRow = i
+				return err
+			}
+			companies = append(companies, company)
+		}
+		return nil
+	})
+	if err != nil {
+		if failedRow >= 0 && apperror.HTTPStatus(err) < http.StatusInternalServerError {
+			report.fail(failedRow, err)
+			report.skipRemaining("not imported because other rows failed")
+			return nil
+		}
+		report.skipRemaining("not imported because the import was aborted")
+		return err
+	}
+
+	for i, company := range companies {
+		report.created(i, company)
+	}
+	return nil
+}
+
+// StartImportJob records a background import and processes its rows in a worker that Shutdown waits for.
+// The worker outlives the request and calls done once the outcome is stored.
+func (s *service) StartImportJob(ctx context.Context, tenantID, userID uuid.UUID, mode ImportMode, rows []ImportRow, done ImportJobDone) (*ImportJob, error) {
+	job := &ImportJob{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		CreatedBy: userID,
+		Status:    JobPending,
+		Mode:      mode,
+		TotalRows: len(rows),
+	}
+	if err := s.repo.CreateImportJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	// The worker updates its own copy, the caller keeps the job as it was created
+	running := *job
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		report, err := s.runImportJob(s.jobsCtx, &running, rows)
+		done(s.jobsCtx, &running, report, err)
+	}()
+	return job, nil
+}
+
+// runImportJob imports the rows of a background job and stores its outcome, even when ctx was cancelled
+func (s *service) runImportJob(ctx context.Context, job *ImportJob, rows []ImportRow) (*ImportReport, error) {
+	job.Status = JobRunning
+	if err := s.repo.UpdateImportJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	stopHeartbeat := s.heartbeat(ctx, job)
+	report, importErr := s.ImportCompanies(ctx, job.TenantID, rows, job.Mode)
+	stopHeartbeat()
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	job.Report = report
+	switch {
+	case importErr != nil:
+		job.Status = JobFailed
+		job.Error = apperror.Message(importErr)
+	case job.Mode == ImportAtomic && report.Failed > 0:
+		job.Status = JobFailed
+		job.Error = "no companies were imported because some rows failed"
 	default:
-		return errors.New("invalid company type")
+		job.Status = JobSucceeded
+	}
+
+	if err := s.repo.UpdateImportJob(context.WithoutCancel(ctx), job); err != nil {
+		return report, err
+	}
+	return report, importErr
+}
+
+// heartbeat touches a running job every importHeartbeat until the returned function is called
+func (s *service) heartbeat(ctx context.Context, job *ImportJob) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(importHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// A missed heartbeat only matters after several in a row, so errors are left to the next tick
+				_ = s.repo.TouchImportJob(ctx, job)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-stopped
+	}
+}
+
+// FailStaleImportJobs marks import jobs whose worker stopped without finishing them as failed, such as
+// the jobs of an instance that crashed or did not finish them before shutting down
+func (s *service) FailStaleImportJobs(ctx context.Context) (int64, error) {
+	return s.repo.FailStaleImportJobs(ctx, importStaleAfter, "the import was interrupted before it finished, upload the file again")
+}
+
+// Shutdown waits for the workers of background imports. When ctx is done first, the imports still
+// running are cancelled and recorded as failed.
+func (s *service) Shutdown(ctx context.Context) error {
+	finished := make(chan struct{})
+	go func() {
+		s.jobs.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		s.stopJobs()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+// GetImportJob retrieves a background import job of a tenant
+func (s *service) GetImportJob(ctx context.Context, tenantID, id uuid.UUID) (*ImportJob, error) {
+	return s.repo.GetImportJob(ctx, tenantID, id)
+}
+
+// ListAddresses retrieves the addresses of a company of a tenant
+func (s *service) ListAddresses(ctx context.Context, tenantID, companyID uuid.UUID) ([]Address, error) {
+	if _, err := s.repo.GetByID(ctx, tenantID, companyID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListAddresses(ctx, tenantID, companyID)
+}
+
+// CreateAddress validates and adds an address to a company of a tenant
+func (s *service) CreateAddress(ctx context.Context, tenantID, companyID uuid.UUID, address *Address) error {
+	if err := address.validate(); err != nil {
+		return err
+	}
+	return s.withinCompany(ctx, tenantID, companyID, func(ctx context.Context) error {
+		address.ID, address.CompanyID = uuid.New(), companyID
+		return s.repo.CreateAddress(ctx, tenantID, address)
+	})
+}
+
+// UpdateAddress validates and replaces an address of a company of a tenant
+func (s *service) UpdateAddress(ctx context.Context, tenantID, companyID, id uuid.UUID, address *Address) error {
+	if err := address.validate(); err != nil {
+		return err
+	}
+	return s.withinCompany(ctx, tenantID, companyID, func(ctx context.Context) error {
+		address.ID, address.CompanyID = id, companyID
+		return s.repo.UpdateAddress(ctx, tenantID, address)
+	})
+}
+
+// DeleteAddress removes an address of a company of a tenant
+func (s *service) DeleteAddress(ctx context.Context, tenantID, companyID, id uuid.UUID) error {
+	return s.withinCompany(ctx, tenantID, companyID, func(ctx context.Context) error {
+		return s.repo.DeleteAddress(ctx, tenantID, companyID, id)
+	})
+}
+
+// ListContacts retrieves the contacts of a company of a tenant
+func (s *service) ListContacts(ctx context.Context, tenantID, companyID uuid.UUID) ([]Contact, error) {
+	if _, err := s.repo.GetByID(ctx, tenantID, companyID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListContacts(ctx, tenantID, companyID)
+}
+
+// CreateContact validates and adds a contact to a company of a tenant
+func (s *service) CreateContact(ctx context.Context, tenantID, companyID uuid.UUID, contact *Contact) error {
+	if err := contact.validate(); err != nil {
+		return err
+	}
+	return s.withinCompany(ctx, tenantID, companyID, func(ctx context.Context) error {
+		contact.ID, contact.CompanyID = uuid.New(), companyID
+		return s.repo.CreateContact(ctx, tenantID, contact)
+	})
+}
+
+// UpdateContact validates and replaces a contact of a company of a tenant
+func (s *service) UpdateContact(ctx context.Context, tenantID, companyID, id uuid.UUID, contact *Contact) error {
+	if err := contact.validate(); err != nil {
+		return err
+	}
+	return s.withinCompany(ctx, tenantID, companyID, func(ctx context.Context) error {
+		contact.ID, contact.CompanyID = id, companyID
+		return s.repo.UpdateContact(ctx, tenantID, contact)
+	})
+}
+
+// DeleteContact removes a contact of a company of a tenant
+func (s *service) DeleteContact(ctx context.Context, tenantID, companyID, id uuid.UUID) error {
+	return s.withinCompany(ctx, tenantID, companyID, func(ctx context.Context) error {
+		return s.repo.DeleteContact(ctx, tenantID, companyID, id)
+	})
+}
+
+// AddTags adds tags to a company, keeping the ones it already has
+func (s *service) AddTags(ctx context.Context, tenantID, id uuid.UUID, tags Tags) (*Company, *TagChange, error) {
+	return s.changeTags(ctx, tenantID, id, func(current Tags) Tags {
+		return append(append(Tags{}, current...), tags...)
+	})
+}
+
+// ReplaceTags replaces all the tags of a company
+func (s *service) ReplaceTags(ctx context.Context, tenantID, id uuid.UUID, tags Tags) (*Company, *TagChange, error) {
+	if tags == nil {
+		violations := &apperror.ValidationError{}
+		violations.Add("tags", apperror.CodeRequired, "tags are required")
+		return nil, nil, violations
+	}
+	return s.changeTags(ctx, tenantID, id, func(Tags) Tags { return tags })
+}
+
+// RemoveTag removes a tag from a company. Removing a tag the company does not have changes nothing.
+func (s *service) RemoveTag(ctx context.Context, tenantID, id uuid.UUID, tag string) (*Company, *TagChange, error) {
+	normalized, ok := normalizeTag(tag)
+	if !ok {
+		violations := &apperror.ValidationError{}
+		violations.Add("tag", apperror.CodeInvalidValue, "tags must consist of letters, digits, hyphens, underscores and spaces")
+		return nil, nil, violations
+	}
+	return s.changeTags(ctx, tenantID, id, func(current Tags) Tags {
+		return slices.DeleteFunc(slices.Clone(current), func(t string) bool { return t == normalized })
+	})
+}
+
+// changeTags locks a live company, sets its tags to the normalised result of fn and reports the change.
+// The tags are only written when they changed.
+func (s *service) changeTags(ctx context.Context, tenantID, id uuid.UUID, fn func(current Tags) Tags) (*Company, *TagChange, error) {
+	var company *Company
+	var change *TagChange
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		company, err = s.repo.GetForUpdate(ctx, tenantID, id)
+		if err != nil {
+			return err
+		}
+		if company.Status == StatusDissolved {
+			return ErrCompanyDissolved
+		}
+
+		violations := &apperror.ValidationError{}
+		tags := normalizeTags(fn(company.Tags), "tags", violations)
+		if err := violations.Err(); err != nil {
+			return err
+		}
+		change = diffTags(company.Tags, tags)
+		if change.empty() {
+			return nil
+		}
+		company.Tags = tags
+		return s.repo.SetTags(ctx, tenantID, id, tags)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return company, change, nil
+}
+
+// ListTags returns the tag catalogue of a tenant, optionally narrowed to the tags starting with a prefix
+func (s *service) ListTags(ctx context.Context, tenantID uuid.UUID, prefix string, limit, offset int) ([]TagUsage, error) {
+	return s.repo.TagUsage(ctx, tenantID, strings.ToLower(strings.TrimSpace(prefix)), limit, offset)
+}
+
+// PutCuratedTag validates and creates or updates a curated tag
+func (s *service) PutCuratedTag(ctx context.Context, tag *CuratedTag) error {
+	if err := tag.validate(); err != nil {
+		return err
+	}
+	return s.repo.PutCuratedTag(ctx, tag)
+}
+
+// DeleteCuratedTag removes a curated tag, which companies keep as a free-form tag
+func (s *service) DeleteCuratedTag(ctx context.Context, name string) error {
+	normalized, ok := normalizeTag(name)
+	if !ok {
+		return ErrCuratedTagNotFound
+	}
+	return s.repo.DeleteCuratedTag(ctx, normalized)
+}
+
+// withinCompany runs fn in a transaction that holds the lock of a live company of a tenant, so that the company
+// cannot be merged or deleted meanwhile. Dissolved companies cannot be changed.
+func (s *service) withinCompany(ctx context.Context, tenantID, companyID uuid.UUID, fn func(ctx context.Context) error) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		company, err := s.repo.GetForUpdate(ctx, tenantID, companyID)
+		if err != nil {
+			return err
+		}
+		if company.Status == StatusDissolved {
+			return ErrCompanyDissolved
+		}
+		return fn(ctx)
+	})
+}
+
+// validateCompany checks the business rules for company creation and updates and reports every violation.
+// A deprecated type is only accepted when it is one of the kept types, which the company already had.
+// Missing attributes are stored as an empty object, which the attribute schema must also accept.
+func validateCompany(company *Company, rules companyRules, kept ...CompanyType) error {
+	violations := &apperror.ValidationError{}
+
+	if company.Name == "" {
+		violations.Add("name", apperror.CodeRequired, "company name is required")
+	} else if len(company.Name) > 15 {
+		violations.Add("name", apperror.CodeTooLong, "company name must be up to 15 characters")
+	}
+
+	if company.AmountOfEmployees == nil {
+		violations.Add("amount_of_employees", apperror.CodeRequired, "amount of employees is required")
+	} else if *company.AmountOfEmployees < 0 {
+		violations.Add("amount_of_employees", apperror.CodeOutOfRange, "amount of employees cannot be negative")
+	}
+
+	if company.Type == "" {
+		violations.Add("type", apperror.CodeRequired, "company type is required")
+	} else if t, ok := rules.types.Lookup(string(company.Type)); !ok {
+		violations.Add("type", apperror.CodeInvalidValue, "invalid company type")
+	} else if !t.Active && !slices.Contains(kept, company.Type) {
+		violations.Add("type", apperror.CodeInvalidValue, "company type "+t.Code+" is deprecated")
 	}
 
 	if len(company.Description) > 3000 {
-		return errors.New("invalid description: must be up to 3000 characters")
+		violations.Add("description", apperror.CodeTooLong, "description must be up to 3000 characters")
 	}
 
-	return nil
+	validateIdentifiers(company, violations)
+	company.Tags = normalizeTags(company.Tags, "tags", violations)
+
+	if company.Attributes == nil {
+		company.Attributes = Attributes{}
+	}
+	if data, err := json.Marshal(company.Attributes); err != nil || len(data) > maxAttributesSize {
+		violations.Add("attributes", apperror.CodeTooLong, "attributes must be up to 16 KiB of JSON")
+	} else {
+		rules.attributes.Validate(company.Attributes, violations)
+	}
+
+	return violations.Err()
 }
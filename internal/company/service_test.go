@@ -0,0 +1,261 @@
+package company
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"xm-microservice/internal/attributeschema"
+	"xm-microservice/internal/companytype"
+
+	"github.com/google/uuid"
+)
+
+var testTenant = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+
+// fakeRepository keeps the companies of one tenant in memory
+type fakeRepository struct {
+	Repository
+	companies map[uuid.UUID]*Company
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{companies: map[uuid.UUID]*Company{}}
+}
+
+// add stores a company below parent, or a top-level company when parent is nil
+func (r *fakeRepository) add(name string, parent *Company) *Company {
+	employees, registered := 10, false
+	company := &Company{ID: uuid.New(), TenantID: testTenant, Name: name, AmountOfEmployees: &employees, Registered: &registered, Status: StatusDraft, Type: "Corporation"}
+	if parent != nil {
+		company.ParentID = &parent.ID
+	}
+	r.companies[company.ID] = company
+	return company
+}
+
+// chain stores n companies, each the parent of the next, and returns them from the top down
+func (r *fakeRepository) chain(n int) []*Company {
+	companies := make([]*Company, n)
+	var parent *Company
+	for i := range companies {
+		companies[i] = r.add(fmt.Sprintf("c%d", i), parent)
+		parent = companies[i]
+	}
+	return companies
+}
+
+func (r *fakeRepository) GetByID(_ context.Context, tenantID, id uuid.UUID) (*Company, error) {
+	company, ok := r.companies[id]
+	if !ok || company.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	copied := *company
+	return &copied, nil
+}
+
+func (r *fakeRepository) GetForUpdate(ctx context.Context, tenantID, id uuid.UUID) (*Company, error) {
+	return r.GetByID(ctx, tenantID, id)
+}
+
+func (r *fakeRepository) Update(_ context.Context, _, id uuid.UUID, company *Company) error {
+	stored := r.companies[id]
+	stored.Name, stored.AmountOfEmployees, stored.Type, stored.Attributes, stored.Tags = company.Name, company.AmountOfEmployees, company.Type, company.Attributes, company.Tags
+	return nil
+}
+
+func (r *fakeRepository) SetParent(_ context.Context, _, id uuid.UUID, parentID *uuid.UUID, ownership *float64) error {
+	r.companies[id].ParentID, r.companies[id].OwnershipPercentage = parentID, ownership
+	return nil
+}
+
+func (r *fakeRepository) MergeInto(_ context.Context, _, sourceID, targetID uuid.UUID) ([]HierarchyChange, error) {
+	var changes []HierarchyChange
+	for _, company := range r.companies {
+		if company.ParentID != nil && *company.ParentID == sourceID {
+			company.ParentID = &targetID
+			changes = append(changes, HierarchyChange{CompanyID: company.ID, ParentID: &targetID, PreviousParentID: &sourceID})
+		}
+	}
+	delete(r.companies, sourceID)
+	return changes, nil
+}
+
+func (r *fakeRepository) Ancestors(_ context.Context, _, id uuid.UUID, maxDepth int) ([]Relative, error) {
+	relatives := []Relative{}
+	for company := r.companies[id]; company.ParentID != nil && len(relatives) < maxDepth; {
+		company = r.companies[*company.ParentID]
+		relatives = append(relatives, Relative{Company: *company, Depth: len(relatives) + 1})
+	}
+	return relatives, nil
+}
+
+func (r *fakeRepository) Subsidiaries(_ context.Context, _, id uuid.UUID, maxDepth int) ([]Relative, error) {
+	relatives := []Relative{}
+	level := []uuid.UUID{id}
+	for depth := 1; depth <= maxDepth && len(level) > 0; depth++ {
+		var next []uuid.UUID
+		for _, company := range r.companies {
+			for _, parentID := range level {
+				if company.ParentID != nil && *company.ParentID == parentID {
+					relatives = append(relatives, Relative{Company: *company, Depth: depth})
+					next = append(next, company.ID)
+				}
+			}
+		}
+		level = next
+	}
+	return relatives, nil
+}
+
+// inlineTx runs transactions without a database
+type inlineTx struct{}
+
+func (inlineTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (inlineTx) WithinTxOptions(ctx context.Context, _ *sql.TxOptions, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (inlineTx) WithinReadTx(ctx context.Context, _ sql.IsolationLevel, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// staticRules provides a single active company type and an attribute schema that accepts anything
+type staticRules struct{}
+
+func (staticRules) Catalog(context.Context) (companytype.Catalog, error) {
+	return companytype.Catalog{"Corporation": {Code: "Corporation", Active: true}}, nil
+}
+
+func (staticRules) Validator(context.Context) (*attributeschema.Validator, error) {
+	return attributeschema.Compile([]byte(`{}`))
+}
+
+func newTestService(repo Repository) *service {
+	return &service{repo: repo, tx: inlineTx{}, types: staticRules{}, attributes: staticRules{}}
+}
+
+func TestSetParentRejectsCycles(t *testing.T) {
+	tests := []struct {
+		name string
+		// depth is the length of the chain; its top company is given its bottom company as parent
+		depth   int
+		wantErr error
+	}{
+		{name: "direct subsidiary", depth: 2, wantErr: ErrHierarchyCycle},
+		{name: "deepest reachable subsidiary", depth: maxHierarchyDepth + 1, wantErr: ErrHierarchyCycle},
+		// A chain deeper than the ancestor query can follow cannot be told apart from a cycle
+		{name: "subsidiary out of reach", depth: maxHierarchyDepth + 50, wantErr: ErrHierarchyTooDeep},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			chain := repo.chain(tt.depth)
+			top, bottom := chain[0], chain[len(chain)-1]
+
+			_, _, err := newTestService(repo).SetParent(context.Background(), testTenant, top.ID, ParentRequest{ParentID: &bottom.ID})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetParent() = %v, want %v", err, tt.wantErr)
+			}
+			if top.ParentID != nil {
+				t.Error("SetParent() changed the parent of the top company")
+			}
+		})
+	}
+}
+
+func TestSetParentLimitsDepth(t *testing.T) {
+	tests := []struct {
+		name string
+		// above and below are the lengths of the chain given as parent and of the chain being moved.
+		// A chain of maxHierarchyDepth+1 companies is as deep as allowed.
+		above, below int
+		wantErr      error
+	}{
+		{name: "deepest allowed", above: 50, below: 51},
+		{name: "one level too deep", above: 50, below: 52, wantErr: ErrHierarchyTooDeep},
+		{name: "single company below the deepest", above: maxHierarchyDepth + 1, below: 1, wantErr: ErrHierarchyTooDeep},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			above, below := repo.chain(tt.above), repo.chain(tt.below)
+			parent, company := above[len(above)-1], below[0]
+
+			moved, change, err := newTestService(repo).SetParent(context.Background(), testTenant, company.ID, ParentRequest{ParentID: &parent.ID})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("SetParent() = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if *moved.ParentID != parent.ID || *change.ParentID != parent.ID || change.PreviousParentID != nil {
+				t.Errorf("SetParent() = %v, %+v, want the company below %s", moved.ParentID, change, parent.ID)
+			}
+		})
+	}
+}
+
+func TestMergeCompaniesLimitsDepth(t *testing.T) {
+	repo := newFakeRepository()
+	// The source's subsidiaries move below the target, which is much deeper than the source
+	deep := repo.chain(maxHierarchyDepth)
+	source := repo.add("source", nil)
+	repo.add("subsidiary", repo.add("subsidiary", source))
+	target := deep[len(deep)-1]
+
+	_, _, err := newTestService(repo).MergeCompanies(context.Background(), testTenant, target.ID, MergeRequest{SourceID: source.ID})
+	if !errors.Is(err, ErrHierarchyTooDeep) {
+		t.Fatalf("MergeCompanies() = %v, want %v", err, ErrHierarchyTooDeep)
+	}
+	if _, ok := repo.companies[source.ID]; !ok {
+		t.Error("MergeCompanies() merged the source")
+	}
+}
+
+func TestMergeCompaniesTargetBelowSource(t *testing.T) {
+	repo := newFakeRepository()
+	top := repo.add("top", nil)
+	source := repo.add("source", top)
+	middle := repo.add("middle", source)
+	target := repo.add("target", middle)
+
+	merged, changes, err := newTestService(repo).MergeCompanies(context.Background(), testTenant, target.ID, MergeRequest{SourceID: source.ID})
+	if err != nil {
+		t.Fatalf("MergeCompanies() = %v", err)
+	}
+	if *merged.ParentID != top.ID || *repo.companies[middle.ID].ParentID != target.ID {
+		t.Errorf("after the merge target is below %v and middle below %v, want %s and %s", merged.ParentID, repo.companies[middle.ID].ParentID, top.ID, target.ID)
+	}
+	if len(changes) != 2 {
+		t.Errorf("MergeCompanies() reported %d hierarchy changes, want 2", len(changes))
+	}
+}
+
+func TestUpdateCompanyReturnsStoredCompany(t *testing.T) {
+	repo := newFakeRepository()
+	parent := repo.add("parent", nil)
+	company := repo.add("company", parent)
+	ownership := 51.0
+	company.OwnershipPercentage = &ownership
+
+	employees := 20
+	// The hierarchy only changes through its own endpoints, so a parent in the update is ignored
+	other := uuid.New()
+	update := &Company{Name: "renamed", AmountOfEmployees: &employees, Type: "Corporation", ParentID: &other}
+	updated, _, err := newTestService(repo).UpdateCompany(context.Background(), testTenant, company.ID, update)
+	if err != nil {
+		t.Fatalf("UpdateCompany() = %v", err)
+	}
+	if updated.ID != company.ID || updated.Name != "renamed" || updated.Status != StatusDraft {
+		t.Errorf("UpdateCompany() = %+v", updated)
+	}
+	if updated.ParentID == nil || *updated.ParentID != parent.ID || updated.OwnershipPercentage == nil || *updated.OwnershipPercentage != ownership {
+		t.Errorf("UpdateCompany() returned parent %v with ownership %v, want %s with %v", updated.ParentID, updated.OwnershipPercentage, parent.ID, ownership)
+	}
+}
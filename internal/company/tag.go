@@ -0,0 +1,168 @@
+package company
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"xm-microservice/pkg/apperror"
+
+	"github.com/lib/pq"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// maxTagLength caps the length of a normalised tag name in characters
+	maxTagLength = 40
+	// maxTags caps how many tags one company can carry
+	maxTags = 20
+)
+
+// Tags labels a company, either with curated tags or free-form ones. Tags are kept normalised, unique and sorted.
+type Tags []string
+
+// Value stores the tags as a text array, empty when there are none
+func (t Tags) Value() (driver.Value, error) {
+	if t == nil {
+		return "{}", nil
+	}
+	return pq.StringArray(t).Value()
+}
+
+// Scan reads the tags from a text array column
+func (t *Tags) Scan(src interface{}) error {
+	var tags pq.StringArray
+	if err := tags.Scan(src); err != nil {
+		return fmt.Errorf("cannot scan %T into company tags: %w", src, err)
+	}
+	*t = Tags(tags)
+	if *t == nil {
+		*t = Tags{}
+	}
+	return nil
+}
+
+// normalizeTag lower-cases a tag name in Unicode normal form C and joins its words with single hyphens,
+// so that "KYC Pending" and "kyc_pending" are the same tag. It reports false for names without letters
+// or digits, or with any character other than letters, digits, hyphens, underscores and spaces.
+func normalizeTag(name string) (string, bool) {
+	var b strings.Builder
+	separated := false
+	for _, r := range strings.ToLower(norm.NFC.String(name)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if separated && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			separated = false
+			b.WriteRune(r)
+		case r == '-' || r == '_' || unicode.IsSpace(r):
+			separated = true
+		default:
+			return "", false
+		}
+	}
+	return b.String(), b.Len() > 0
+}
+
+// normalizeTags returns the normalised, unique and sorted tags, reporting invalid ones as field.<index>
+func normalizeTags(tags Tags, field string, violations *apperror.ValidationError) Tags {
+	normalized := make(Tags, 0, len(tags))
+	for i, name := range tags {
+		tag, ok := normalizeTag(name)
+		switch {
+		case !ok:
+			violations.Add(fmt.Sprintf("%s.%d", field, i), apperror.CodeInvalidValue, "tags must consist of letters, digits, hyphens, underscores and spaces")
+		case utf8.RuneCountInString(tag) > maxTagLength:
+			violations.Add(fmt.Sprintf("%s.%d", field, i), apperror.CodeTooLong, fmt.Sprintf("tags must be up to %d characters", maxTagLength))
+		default:
+			normalized = append(normalized, tag)
+		}
+	}
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+	if len(normalized) > maxTags {
+		violations.Add(field, apperror.CodeOutOfRange, fmt.Sprintf("a company can have at most %d tags", maxTags))
+	}
+	return normalized
+}
+
+// TagChange lists the tags that a change added to and removed from a company
+type TagChange struct {
+	Added   Tags `json:"added"`
+	Removed Tags `json:"removed"`
+}
+
+// diffTags compares two sets of normalised tags
+func diffTags(before, after Tags) *TagChange {
+	change := &TagChange{Added: Tags{}, Removed: Tags{}}
+	for _, tag := range after {
+		if !slices.Contains(before, tag) {
+			change.Added = append(change.Added, tag)
+		}
+	}
+	for _, tag := range before {
+		if !slices.Contains(after, tag) {
+			change.Removed = append(change.Removed, tag)
+		}
+	}
+	return change
+}
+
+// empty tells whether the change left the tags as they were
+func (c *TagChange) empty() bool {
+	return c == nil || len(c.Added) == 0 && len(c.Removed) == 0
+}
+
+// TagRequest carries the tags to add to a company or to replace its tags with
+type TagRequest struct {
+	Tags Tags `json:"tags"`
+}
+
+// TagMatch tells whether a tag filter selects companies with any or all of the tags
+type TagMatch string
+
+const (
+	TagMatchAny TagMatch = "any"
+	TagMatchAll TagMatch = "all"
+)
+
+// CuratedTag is a tag suggested to every tenant and described in the tag catalogue
+type CuratedTag struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// validate normalises the name of a curated tag and reports every violation
+func (t *CuratedTag) validate() error {
+	violations := &apperror.ValidationError{}
+	if name, ok := normalizeTag(t.Name); !ok {
+		violations.Add("name", apperror.CodeInvalidValue, "tags must consist of letters, digits, hyphens, underscores and spaces")
+	} else if utf8.RuneCountInString(name) > maxTagLength {
+		violations.Add("name", apperror.CodeTooLong, fmt.Sprintf("tags must be up to %d characters", maxTagLength))
+	} else {
+		t.Name = name
+	}
+	t.Description = strings.TrimSpace(t.Description)
+	if utf8.RuneCountInString(t.Description) > 200 {
+		violations.Add("description", apperror.CodeTooLong, "description must be up to 200 characters")
+	}
+	return violations.Err()
+}
+
+// TagUsage is an entry of the tag catalogue of a tenant: a curated tag or a tag in use, with how many companies carry it
+type TagUsage struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Curated     bool   `json:"curated"`
+	Count       int    `json:"count"`
+}
+
+// ErrCuratedTagNotFound reports a curated tag that does not exist
+var ErrCuratedTagNotFound = apperror.New(apperror.ErrNotFound, "curated tag not found")
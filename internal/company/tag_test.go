@@ -0,0 +1,148 @@
+package company
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"xm-microservice/pkg/apperror"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{name: "kyc-pending", want: "kyc-pending", wantOK: true},
+		{name: "KYC Pending", want: "kyc-pending", wantOK: true},
+		{name: "kyc_pending", want: "kyc-pending", wantOK: true},
+		{name: "  KYC -_ pending  ", want: "kyc-pending", wantOK: true},
+		{name: "-kyc-", want: "kyc", wantOK: true},
+		{name: "kyc pending", want: "kyc-pending", wantOK: true},
+		{name: "tier 1", want: "tier-1", wantOK: true},
+		{name: "2024", want: "2024", wantOK: true},
+		{name: "ÄRGER", want: "ärger", wantOK: true},
+		{name: "Cafe\u0301", want: "café", wantOK: true},
+		{name: "café", want: "café", wantOK: true},
+		{name: "日本", want: "日本", wantOK: true},
+		{name: "", wantOK: false},
+		{name: "   ", wantOK: false},
+		{name: "-_-", wantOK: false},
+		{name: "kyc.pending", wantOK: false},
+		{name: "kyc/pending", wantOK: false},
+		{name: "#vip", wantOK: false},
+		{name: "vip!", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := normalizeTag(tt.name)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("normalizeTag(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	violations := &apperror.ValidationError{}
+	got := normalizeTags(Tags{"VIP", "kyc pending", "vip", "KYC_Pending", "Cafe\u0301", "café"}, "tags", violations)
+	if err := violations.Err(); err != nil {
+		t.Fatalf("normalizeTags() reported %v", err)
+	}
+	if want := (Tags{"café", "kyc-pending", "vip"}); !slices.Equal(got, want) {
+		t.Errorf("normalizeTags() = %q, want %q", got, want)
+	}
+}
+
+func TestNormalizeTagsViolations(t *testing.T) {
+	tests := []struct {
+		name       string
+		tags       Tags
+		want       Tags
+		wantFields []apperror.FieldError
+	}{
+		{
+			name:       "invalid characters",
+			tags:       Tags{"vip", "kyc.pending", "--"},
+			want:       Tags{"vip"},
+			wantFields: []apperror.FieldError{{Field: "tags.1", Code: apperror.CodeInvalidValue}, {Field: "tags.2", Code: apperror.CodeInvalidValue}},
+		},
+		{
+			name: "longest tag",
+			tags: Tags{strings.Repeat("a", maxTagLength), strings.Repeat("é", maxTagLength)},
+			want: Tags{strings.Repeat("a", maxTagLength), strings.Repeat("é", maxTagLength)},
+		},
+		{
+			name:       "too long",
+			tags:       Tags{strings.Repeat("a", maxTagLength+1)},
+			want:       Tags{},
+			wantFields: []apperror.FieldError{{Field: "tags.0", Code: apperror.CodeTooLong}},
+		},
+		{
+			name: "most tags counted after removing duplicates",
+			tags: append(numberedTags(maxTags), "TAG 0", "tag_1"),
+			want: sortedTags(numberedTags(maxTags)),
+		},
+		{
+			name:       "too many tags",
+			tags:       numberedTags(maxTags + 1),
+			want:       sortedTags(numberedTags(maxTags + 1)),
+			wantFields: []apperror.FieldError{{Field: "tags", Code: apperror.CodeOutOfRange}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := &apperror.ValidationError{}
+			got := normalizeTags(tt.tags, "tags", violations)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("normalizeTags() = %q, want %q", got, tt.want)
+			}
+			if len(violations.Fields) != len(tt.wantFields) {
+				t.Fatalf("normalizeTags() reported %+v, want %+v", violations.Fields, tt.wantFields)
+			}
+			for i, field := range violations.Fields {
+				if field.Field != tt.wantFields[i].Field || field.Code != tt.wantFields[i].Code {
+					t.Errorf("violation %d = %s %s, want %s %s", i, field.Field, field.Code, tt.wantFields[i].Field, tt.wantFields[i].Code)
+				}
+			}
+		})
+	}
+}
+
+func TestCuratedTagValidate(t *testing.T) {
+	tag := &CuratedTag{Name: "KYC Pending", Description: "  Checks are not complete  "}
+	if err := tag.validate(); err != nil {
+		t.Fatalf("validate() = %v", err)
+	}
+	if tag.Name != "kyc-pending" || tag.Description != "Checks are not complete" {
+		t.Errorf("validate() left %q, %q", tag.Name, tag.Description)
+	}
+
+	for _, tag := range []*CuratedTag{
+		{Name: "kyc.pending"},
+		{Name: strings.Repeat("a", maxTagLength+1)},
+		{Name: "vip", Description: strings.Repeat("d", 201)},
+	} {
+		if err := tag.validate(); err == nil {
+			t.Errorf("validate() accepted %q with a %d character description", tag.Name, len(tag.Description))
+		}
+	}
+}
+
+// numberedTags returns n distinct tags named tag-0 to tag-<n-1>
+func numberedTags(n int) Tags {
+	tags := make(Tags, n)
+	for i := range tags {
+		tags[i] = fmt.Sprintf("tag-%d", i)
+	}
+	return tags
+}
+
+// sortedTags returns a sorted copy of the tags
+func sortedTags(tags Tags) Tags {
+	sorted := slices.Clone(tags)
+	slices.Sort(sorted)
+	return sorted
+}
@@ -0,0 +1,51 @@
+package companytype
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache keeps a copy of the company types and reloads it once it is older than its TTL.
+// Changes made through this instance invalidate it at once; other instances see them within the TTL.
+type Cache struct {
+	repo     Repository
+	ttl      time.Duration
+	mu       sync.Mutex
+	catalog  Catalog
+	loadedAt time.Time
+}
+
+// NewCache initializes an empty company type cache that loads from the repository on first use
+func NewCache(repo Repository, ttl time.Duration) *Cache {
+	return &Cache{repo: repo, ttl: ttl}
+}
+
+// Catalog returns the cached company types, reloading them when they are missing or expired.
+// The returned catalog must not be modified.
+func (c *Cache) Catalog(ctx context.Context) (Catalog, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.catalog != nil && time.Since(c.loadedAt) < c.ttl {
+		return c.catalog, nil
+	}
+
+	types, err := c.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	catalog := make(Catalog, len(types))
+	for _, t := range types {
+		catalog[t.Code] = t
+	}
+	c.catalog, c.loadedAt = catalog, time.Now()
+	return catalog, nil
+}
+
+// Invalidate drops the cached company types so that the next lookup reloads them
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.catalog = nil
+}
@@ -0,0 +1,34 @@
+package companytype
+
+import (
+	"database/sql"
+	"errors"
+
+	"xm-microservice/internal/database"
+	"xm-microservice/pkg/apperror"
+)
+
+var (
+	ErrNotFound     = apperror.New(apperror.ErrNotFound, "company type not found")
+	ErrCodeConflict = apperror.New(apperror.ErrConflict, "company type code already exists")
+	ErrInUse        = apperror.New(apperror.ErrConflict, "company type is in use; deactivate it instead")
+	ErrUnavailable  = apperror.New(apperror.ErrUnavailable, "company type storage is unavailable")
+)
+
+// mapError translates database errors into company type domain errors
+func mapError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case database.IsUniqueViolation(err):
+		return ErrCodeConflict.WithCause(err)
+	case database.IsForeignKeyViolation(err):
+		return ErrInUse.WithCause(err)
+	case database.IsUnavailable(err):
+		return ErrUnavailable.WithCause(err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,95 @@
+package companytype
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"xm-microservice/pkg/logger"
+	"xm-microservice/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// Handler manages the endpoints for company types
+type Handler struct {
+	service Service
+	logger  *logger.Logger
+}
+
+// NewHandler initializes a new company type handler
+func NewHandler(service Service, logger *logger.Logger) *Handler {
+	return &Handler{service: service, logger: logger}
+}
+
+// CreateType handles the creation of a new company type
+func (h *Handler) CreateType(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("CreateType handler invoked")
+
+	t := Type{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		h.logger.Error(err, "Invalid input while decoding company type data")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	if err := h.service.CreateType(r.Context(), &t); err != nil {
+		h.logger.Error(err, "Failed to create company type")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.logger.Info("Company type created successfully with code: %s", t.Code)
+	utils.JSONResponse(w, http.StatusCreated, t)
+}
+
+// UpdateType handles renaming, deprecating and reactivating a company type
+func (h *Handler) UpdateType(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("UpdateType handler invoked")
+
+	var changes Changes
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		h.logger.Error(err, "Invalid input while decoding company type changes")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	code := mux.Vars(r)["code"]
+	t, err := h.service.UpdateType(r.Context(), code, changes)
+	if err != nil {
+		h.logger.Error(err, "Failed to update company type")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.logger.Info("Company type updated successfully with code: %s", code)
+	utils.JSONResponse(w, http.StatusOK, t)
+}
+
+// DeleteType handles deleting a company type that no company uses
+func (h *Handler) DeleteType(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("DeleteType handler invoked")
+
+	code := mux.Vars(r)["code"]
+	if err := h.service.DeleteType(r.Context(), code); err != nil {
+		h.logger.Error(err, "Failed to delete company type")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.logger.Info("Company type deleted successfully with code: %s", code)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListTypes returns all company types, including deprecated ones
+func (h *Handler) ListTypes(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("ListTypes handler invoked")
+
+	types, err := h.service.ListTypes(r.Context())
+	if err != nil {
+		h.logger.Error(err, "Failed to list company types")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, types)
+}
@@ -0,0 +1,30 @@
+package companytype
+
+import (
+	"time"
+)
+
+// Type is a kind of company that companies can be classified as.
+// Inactive types are deprecated: companies that have one keep it, but no other company can be given it.
+type Type struct {
+	Code        string    `json:"code"`
+	DisplayName string    `json:"display_name"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Changes holds the fields of a type to update; nil fields are left as they are
+type Changes struct {
+	DisplayName *string `json:"display_name"`
+	Active      *bool   `json:"active"`
+}
+
+// Catalog is a snapshot of all company types by code
+type Catalog map[string]Type
+
+// Lookup returns the type with the given code
+func (c Catalog) Lookup(code string) (Type, bool) {
+	t, ok := c[code]
+	return t, ok
+}
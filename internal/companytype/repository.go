@@ -0,0 +1,83 @@
+package companytype
+
+import (
+	"context"
+	"time"
+
+	"xm-microservice/internal/database"
+)
+
+type Repository interface {
+	Create(ctx context.Context, t *Type) error
+	Update(ctx context.Context, code string, changes Changes) (*Type, error)
+	Delete(ctx context.Context, code string) error
+	List(ctx context.Context) ([]Type, error)
+}
+
+type repository struct {
+	db      *database.DB
+	timeout time.Duration
+}
+
+// NewRepository initializes a new company type repository with the database connection and query timeout
+func NewRepository(db *database.DB, timeout time.Duration) Repository {
+	return &repository{db: db, timeout: timeout}
+}
+
+// Create inserts a new company type into the database
+func (r *repository) Create(ctx context.Context, t *Type) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `INSERT INTO company_types (code, display_name, active) VALUES ($1, $2, $3) RETURNING created_at, updated_at`
+	return mapError(r.db.Writer(ctx).QueryRowContext(ctx, query, t.Code, t.DisplayName, t.Active).Scan(&t.CreatedAt, &t.UpdatedAt))
+}
+
+// Update changes the display name and active flag of a company type, keeping the fields that are nil
+func (r *repository) Update(ctx context.Context, code string, changes Changes) (*Type, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE company_types SET display_name=COALESCE($1, display_name), active=COALESCE($2, active), updated_at=NOW()
+		WHERE code=$3 RETURNING code, display_name, active, created_at, updated_at`
+	t := &Type{}
+	err := r.db.Writer(ctx).QueryRowContext(ctx, query, changes.DisplayName, changes.Active, code).Scan(&t.Code, &t.DisplayName, &t.Active, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return t, nil
+}
+
+// Delete removes a company type that no company uses
+func (r *repository) Delete(ctx context.Context, code string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.Writer(ctx).ExecContext(ctx, `DELETE FROM company_types WHERE code=$1`, code)
+	if err != nil {
+		return mapError(err)
+	}
+	return mapError(database.ExpectRows(result))
+}
+
+// List retrieves all company types ordered by code, always from the primary since it fills the validation cache
+func (r *repository) List(ctx context.Context) ([]Type, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.Primary().QueryContext(ctx, `SELECT code, display_name, active, created_at, updated_at FROM company_types ORDER BY code`)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	types := []Type{}
+	for rows.Next() {
+		var t Type
+		if err := rows.Scan(&t.Code, &t.DisplayName, &t.Active, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, mapError(err)
+		}
+		types = append(types, t)
+	}
+	return types, mapError(rows.Err())
+}
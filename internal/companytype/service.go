@@ -0,0 +1,101 @@
+package companytype
+
+import (
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"xm-microservice/pkg/apperror"
+)
+
+// Service defines the business logic interface for company types
+type Service interface {
+	CreateType(ctx context.Context, t *Type) error
+	UpdateType(ctx context.Context, code string, changes Changes) (*Type, error)
+	DeleteType(ctx context.Context, code string) error
+	ListTypes(ctx context.Context) ([]Type, error)
+}
+
+type service struct {
+	repo  Repository
+	cache *Cache
+}
+
+// NewService initializes a new company type service with the repository and the cache it keeps current
+func NewService(repo Repository, cache *Cache) Service {
+	return &service{repo: repo, cache: cache}
+}
+
+// CreateType validates and creates a new company type, active unless stated otherwise
+func (s *service) CreateType(ctx context.Context, t *Type) error {
+	t.Code = strings.TrimSpace(t.Code)
+	t.DisplayName = strings.TrimSpace(t.DisplayName)
+	violations := &apperror.ValidationError{}
+	switch {
+	case t.Code == "":
+		violations.Add("code", apperror.CodeRequired, "code is required")
+	case utf8.RuneCountInString(t.Code) > 50:
+		violations.Add("code", apperror.CodeTooLong, "code must be up to 50 characters")
+	case strings.IndexFunc(t.Code, invalidCodeRune) >= 0:
+		violations.Add("code", apperror.CodeInvalidValue, "code may only contain letters, digits, spaces, hyphens and underscores")
+	}
+	validateDisplayName(t.DisplayName, violations)
+	if err := violations.Err(); err != nil {
+		return err
+	}
+
+	if err := s.repo.Create(ctx, t); err != nil {
+		return err
+	}
+	s.cache.Invalidate()
+	return nil
+}
+
+// UpdateType renames, deprecates or reactivates a company type
+func (s *service) UpdateType(ctx context.Context, code string, changes Changes) (*Type, error) {
+	if changes.DisplayName != nil {
+		name := strings.TrimSpace(*changes.DisplayName)
+		changes.DisplayName = &name
+		violations := &apperror.ValidationError{}
+		validateDisplayName(name, violations)
+		if err := violations.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	t, err := s.repo.Update(ctx, code, changes)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Invalidate()
+	return t, nil
+}
+
+// DeleteType removes a company type that no company uses
+func (s *service) DeleteType(ctx context.Context, code string) error {
+	if err := s.repo.Delete(ctx, code); err != nil {
+		return err
+	}
+	s.cache.Invalidate()
+	return nil
+}
+
+// ListTypes retrieves all company types, including deprecated ones
+func (s *service) ListTypes(ctx context.Context) ([]Type, error) {
+	return s.repo.List(ctx)
+}
+
+// validateDisplayName reports a missing or too long display name
+func validateDisplayName(name string, violations *apperror.ValidationError) {
+	if name == "" {
+		violations.Add("display_name", apperror.CodeRequired, "display_name is required")
+	} else if utf8.RuneCountInString(name) > 100 {
+		violations.Add("display_name", apperror.CodeTooLong, "display_name must be up to 100 characters")
+	}
+}
+
+// invalidCodeRune reports runes that may not appear in a type code, which is also used in URLs
+func invalidCodeRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' && r != '-' && r != '_'
+}
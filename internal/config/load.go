@@ -0,0 +1,267 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileEnv names the environment variable that points to the configuration file
+const fileEnv = "CONFIG_FILE"
+
+// setting describes one configuration value and where it can be overridden
+type setting struct {
+	env    string
+	usage  string
+	target interface{}
+}
+
+// flagName derives the command-line flag of a setting from its environment variable
+func (s setting) flagName() string {
+	return strings.ReplaceAll(strings.ToLower(s.env), "_", "-")
+}
+
+// settings lists every configurable value, bound to the fields of c
+func (c *Config) settings() []setting {
+	return []setting{
+		{"LOG_LEVEL", "minimum log level: debug, info or error", &c.LogLevel},
+		{"PORT", "HTTP port to listen on", &c.Port},
+		{"SHUTDOWN_TIMEOUT", "how long to wait for requests and background imports to finish on shutdown", &c.ShutdownTimeout},
+		{"DATABASE_URL", "PostgreSQL connection URL of the primary", &c.DatabaseURL},
+		{"DATABASE_REPLICA_URL", "PostgreSQL connection URL of an optional read replica", &c.DatabaseReplicaURL},
+		{"DB_MAX_OPEN_CONNS", "maximum number of open connections per pool", &c.DBMaxOpenConns},
+		{"DB_MAX_IDLE_CONNS", "maximum number of idle connections per pool", &c.DBMaxIdleConns},
+		{"DB_CONN_MAX_LIFETIME", "maximum lifetime of a pooled connection", &c.DBConnMaxLifetime},
+		{"DB_CONN_MAX_IDLE_TIME", "maximum idle time of a pooled connection", &c.DBConnMaxIdleTime},
+		{"MIGRATE_ON_STARTUP", "apply pending migrations when the server starts", &c.MigrateOnStartup},
+		{"JWT_SECRET", "secret used to sign tokens", &c.JWTSecret},
+		{"KAFKA_BROKER", "Kafka broker address as host:port", &c.KafkaBroker},
+		{"KAFKA_PARTITIONS", "partitions of the company topic", &c.KafkaPartitions},
+		{"KAFKA_REPLICATION_FACTOR", "replication factor of the company topic", &c.KafkaReplicationFactor},
+		{"KAFKA_TOPIC_COMPANY", "topic for company events", &c.KafkaTopicCompany},
+		{"KAFKA_PUBLISH_TIMEOUT", "timeout for publishing one event", &c.KafkaPublishTimeout},
+		{"DB_QUERY_TIMEOUT", "timeout for one database query", &c.DBQueryTimeout},
+		{"DB_TX_MAX_RETRIES", "retries of a transaction after a serialization failure", &c.DBTxMaxRetries},
+		{"OIDC_ISSUER_URL", "OIDC issuer URL, enables SSO when set", &c.OIDCIssuerURL},
+		{"OIDC_CLIENT_ID", "OIDC client ID", &c.OIDCClientID},
+		{"OIDC_CLIENT_SECRET", "OIDC client secret", &c.OIDCClientSecret},
+		{"OIDC_REDIRECT_URL", "OIDC callback URL", &c.OIDCRedirectURL},
+		{"OIDC_SCOPES", "comma-separated OIDC scopes", &c.OIDCScopes},
+		{"OIDC_GROUPS_CLAIM", "ID token claim holding the groups", &c.OIDCGroupsClaim},
+		{"OIDC_ROLE_MAPPING", "comma-separated group:role pairs", &c.OIDCRoleMapping},
+		{"OIDC_DEFAULT_ROLE", "role for users without a mapped group", &c.OIDCDefaultRole},
+		{"OIDC_TENANT_CLAIM", "ID token claim holding the tenant ID of a user", &c.OIDCTenantClaim},
+		{"OIDC_TENANT_ID", "tenant of OIDC users whose ID token names no tenant", &c.OIDCTenantID},
+		{"MFA_ISSUER", "issuer shown in authenticator apps", &c.MFAIssuer},
+		{"IDEMPOTENCY_TTL", "how long responses to requests with an Idempotency-Key are replayed", &c.IdempotencyTTL},
+		{"IMPORT_MAX_ROWS", "maximum number of rows in a company import", &c.ImportMaxRows},
+		{"IMPORT_SYNC_ROWS", "imports with more rows run as background jobs", &c.ImportSyncRows},
+		{"RATE_LIMIT_ENABLED", "enable per-client rate limiting", &c.RateLimitEnabled},
+		{"RATE_LIMIT_LOGIN", "login requests per IP, as requests/window", &c.RateLimitLogin},
+		{"RATE_LIMIT_SIGNUP", "registration requests per IP, as requests/window", &c.RateLimitSignup},
+		{"RATE_LIMIT_API", "API requests per user, as requests/window", &c.RateLimitAPI},
+		{"TRUSTED_PROXIES", "comma-separated IP addresses or CIDR networks of proxies whose X-Forwarded-For is trusted", &c.TrustedProxies},
+		{"SIGNUP_ENABLED", "allow self-registration of users", &c.SignupEnabled},
+		{"IMPORT_ENABLED", "allow bulk company imports", &c.ImportEnabled},
+		{"EXPORT_ENABLED", "allow company exports", &c.ExportEnabled},
+		{"DUPLICATE_THRESHOLD", "name similarity from 0 to 1 at which a new company counts as a near-duplicate", &c.DuplicateThreshold},
+		{"DUPLICATE_ACTION", "what to do with near-duplicate companies: warn or reject", &c.DuplicateAction},
+		{"COMPANY_TYPES_CACHE_TTL", "how long company types are cached before other instances' changes are seen", &c.CompanyTypesCacheTTL},
+		{"ATTRIBUTE_SCHEMA_CACHE_TTL", "how long the company attribute schema is cached before other instances' changes are seen", &c.AttrSchemaCacheTTL},
+	}
+}
+
+// Load builds the configuration from the defaults, an optional YAML file, environment
+// variables and command-line flags, each layer overriding the previous one. Every setting
+// can also be read from a file named by its variable with a _FILE suffix, which is meant
+// for secrets. Load returns the arguments left after the flags, such as a subcommand.
+func Load(args []string) (*Config, []string, error) {
+	cfg := defaults()
+	settings := cfg.settings()
+
+	flags := flag.NewFlagSet("xm-microservice", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	configFile := flags.String("config", os.Getenv(fileEnv), "path to a YAML configuration file")
+	values := make([]*flagValue, len(settings))
+	for i, s := range settings {
+		values[i] = &flagValue{}
+		flags.Var(values[i], s.flagName(), s.usage)
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, nil, errors.New(Usage())
+		}
+		return nil, nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			return nil, nil, err
+		}
+		cfg.File = *configFile
+	}
+
+	var problems []string
+	for i, s := range settings {
+		value, source, ok, err := lookupEnv(s.env)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if ok {
+			if err := setValue(s.target, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", source, err))
+			}
+		}
+		if values[i].set {
+			if err := setValue(s.target, values[i].value); err != nil {
+				problems = append(problems, fmt.Sprintf("--%s: %v", s.flagName(), err))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+// Usage describes the configuration flags and their environment variables
+func Usage() string {
+	var b strings.Builder
+	b.WriteString("usage: xm-microservice [flags] [migrate <command> | config print]\n\nflags:\n")
+	fmt.Fprintf(&b, "  --config\n        path to a YAML configuration file (%s)\n", fileEnv)
+	for _, s := range defaults().settings() {
+		fmt.Fprintf(&b, "  --%s\n        %s (%s, %s_FILE)\n", s.flagName(), s.usage, s.env, s.env)
+	}
+	return b.String()
+}
+
+// loadFile overlays the settings of a YAML file, rejecting keys that do not exist
+func loadFile(cfg *Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// lookupEnv reads a setting from its environment variable or from the file named by its _FILE variable
+func lookupEnv(key string) (value, source string, ok bool, err error) {
+	fileKey := key + "_FILE"
+	path, hasFile := os.LookupEnv(fileKey)
+	value, hasValue := os.LookupEnv(key)
+
+	switch {
+	case hasFile && hasValue:
+		return "", "", false, fmt.Errorf("%s and %s are both set, use only one", key, fileKey)
+	case hasFile:
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", false, fmt.Errorf("%s: %w", fileKey, err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), fileKey, true, nil
+	default:
+		return value, key, hasValue, nil
+	}
+}
+
+// setValue parses a string into the type of the configuration field it points to
+func setValue(target interface{}, value string) error {
+	switch t := target.(type) {
+	case *string:
+		*t = value
+	case *int:
+		parsed, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*t = parsed
+	case *float64:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*t = parsed
+	case *bool:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		*t = parsed
+	case *time.Duration:
+		parsed, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 5s or 1m", value)
+		}
+		*t = parsed
+	case *[]string:
+		*t = parseList(value)
+	case *map[string]string:
+		parsed, err := parseMap(value)
+		if err != nil {
+			return err
+		}
+		*t = parsed
+	default:
+		return fmt.Errorf("unsupported setting type %T", target)
+	}
+	return nil
+}
+
+// parseList splits a comma-separated value and drops empty items
+func parseList(value string) []string {
+	values := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// parseMap parses a comma-separated list of key:value pairs
+func parseMap(value string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, item := range parseList(value) {
+		k, v, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("entry %q is not a key:value pair", item)
+		}
+		values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return values, nil
+}
+
+// flagValue records a flag so it can be applied after the file and environment layers
+type flagValue struct {
+	value string
+	set   bool
+}
+
+func (f *flagValue) String() string {
+	return f.value
+}
+
+func (f *flagValue) Set(value string) error {
+	f.value = value
+	f.set = true
+	return nil
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testJWTSecret = "0123456789abcdef0123456789abcdef"
+
+// setRequired sets the settings without defaults so that Load passes validation
+func setRequired(t *testing.T) {
+	t.Helper()
+	t.Setenv(fileEnv, "")
+	t.Setenv("DATABASE_URL", "postgres://user:secret@db:5432/xm")
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	t.Setenv("KAFKA_BROKER", "kafka:9092")
+}
+
+// writeFile writes content to a file in a temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	setRequired(t)
+
+	cfg, rest, err := Load([]string{"migrate", "up"})
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if !slices.Equal(rest, []string{"migrate", "up"}) {
+		t.Errorf("Load() left arguments %q, want migrate up", rest)
+	}
+	if cfg.Port != "8080" || cfg.DBQueryTimeout != 5*time.Second || cfg.DuplicateThreshold != 0.6 || !cfg.SignupEnabled {
+		t.Errorf("Load() did not apply the defaults: %+v", cfg)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	setRequired(t)
+	file := writeFile(t, "config.yaml", `
+port: "8081"
+log_level: debug
+db_max_open_conns: 40
+duplicate_action: warn
+oidc_role_mapping:
+  admins: admin
+`)
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("LOG_LEVEL", "error")
+	t.Setenv("DB_MAX_OPEN_CONNS", "50")
+	t.Setenv("RATE_LIMIT_API", "100/1m")
+
+	cfg, _, err := Load([]string{"--db-max-open-conns", "60", "--import-enabled=false"})
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+
+	tests := []struct {
+		setting string
+		got     interface{}
+		want    interface{}
+	}{
+		{setting: "default only", got: cfg.KafkaTopicCompany, want: "company-events"},
+		{setting: "file over default", got: cfg.Port, want: "8081"},
+		{setting: "file map", got: cfg.OIDCRoleMapping["admins"], want: "admin"},
+		{setting: "file only", got: cfg.DuplicateAction, want: "warn"},
+		{setting: "environment over file", got: cfg.LogLevel, want: "error"},
+		{setting: "environment over default", got: cfg.RateLimitAPI, want: "100/1m"},
+		{setting: "flag over environment and file", got: cfg.DBMaxOpenConns, want: 60},
+		{setting: "flag over default", got: cfg.ImportEnabled, want: false},
+		{setting: "file path", got: cfg.File, want: file},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.setting, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigFlagOverridesEnvironment(t *testing.T) {
+	setRequired(t)
+	t.Setenv("CONFIG_FILE", writeFile(t, "env.yaml", "port: \"8081\"\n"))
+	flagFile := writeFile(t, "flag.yaml", "port: \"8082\"\n")
+
+	cfg, _, err := Load([]string{"--config", flagFile})
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if cfg.Port != "8082" {
+		t.Errorf("Port = %q, want the one from the --config file", cfg.Port)
+	}
+}
+
+func TestLoadFileIndirection(t *testing.T) {
+	setRequired(t)
+	os.Unsetenv("JWT_SECRET")
+	t.Setenv("JWT_SECRET_FILE", writeFile(t, "jwt_secret", testJWTSecret+"-from-file\r\n"))
+	t.Setenv("DB_QUERY_TIMEOUT_FILE", writeFile(t, "timeout", "7s\n"))
+
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if cfg.JWTSecret != testJWTSecret+"-from-file" {
+		t.Errorf("JWTSecret = %q, want the trimmed file content", cfg.JWTSecret)
+	}
+	if cfg.DBQueryTimeout != 7*time.Second {
+		t.Errorf("DBQueryTimeout = %v, want 7s", cfg.DBQueryTimeout)
+	}
+}
+
+func TestLoadFileIndirectionErrors(t *testing.T) {
+	setRequired(t)
+	t.Setenv("JWT_SECRET_FILE", writeFile(t, "jwt_secret", testJWTSecret))
+	t.Setenv("DATABASE_URL_FILE", filepath.Join(t.TempDir(), "missing"))
+	os.Unsetenv("DATABASE_URL")
+
+	_, _, err := Load(nil)
+	if err == nil {
+		t.Fatal("Load() accepted a variable set both directly and through a file")
+	}
+	for _, want := range []string{"JWT_SECRET and JWT_SECRET_FILE are both set", "DATABASE_URL_FILE:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Load() = %v, want it to mention %q", err, want)
+		}
+	}
+}
+
+func TestLoadReportsEveryInvalidValue(t *testing.T) {
+	setRequired(t)
+	t.Setenv("DB_MAX_OPEN_CONNS", "many")
+	t.Setenv("RATE_LIMIT_ENABLED", "sometimes")
+
+	_, _, err := Load([]string{"--db-query-timeout", "soon"})
+	if err == nil {
+		t.Fatal("Load() accepted unparsable values")
+	}
+	for _, want := range []string{
+		`DB_MAX_OPEN_CONNS: "many" is not an integer`,
+		`RATE_LIMIT_ENABLED: "sometimes" is not a boolean`,
+		`--db-query-timeout: "soon" is not a duration`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Load() = %v, want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownFileKeysAndFlags(t *testing.T) {
+	setRequired(t)
+	t.Setenv("CONFIG_FILE", writeFile(t, "config.yaml", "prot: \"8081\"\n"))
+	if _, _, err := Load(nil); err == nil || !strings.Contains(err.Error(), "prot") {
+		t.Errorf("Load() = %v, want an error naming the unknown key", err)
+	}
+
+	t.Setenv("CONFIG_FILE", "")
+	if _, _, err := Load([]string{"--prot", "8081"}); err == nil {
+		t.Error("Load() accepted an unknown flag")
+	}
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"xm-microservice/pkg/logger"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce groups the burst of file events an editor or config map update produces into one reload
+const reloadDebounce = 250 * time.Millisecond
+
+// errRestartRequired explains why a changed setting was not applied
+var errRestartRequired = errors.New("setting can only be changed with a restart")
+
+// reloadable lists the settings that take effect without a restart, keyed by environment variable
+var reloadable = map[string]bool{
+	"LOG_LEVEL":          true,
+	"RATE_LIMIT_ENABLED": true,
+	"RATE_LIMIT_LOGIN":   true,
+	"RATE_LIMIT_SIGNUP":  true,
+	"RATE_LIMIT_API":     true,
+	"SIGNUP_ENABLED":     true,
+	"IMPORT_ENABLED":     true,
+	"EXPORT_ENABLED":     true,
+}
+
+// Reloader holds the current configuration and swaps it atomically when the config file changes or on SIGHUP
+type Reloader struct {
+	current   atomic.Pointer[Config]
+	args      []string
+	log       *logger.Logger
+	mu        sync.Mutex
+	listeners []func(*Config)
+}
+
+// NewReloader initializes a Reloader with the configuration loaded at startup and the
+// command-line arguments it was loaded from, which are applied again on every reload
+func NewReloader(cfg *Config, args []string, log *logger.Logger) *Reloader {
+	r := &Reloader{args: args, log: log}
+	r.current.Store(cfg)
+	return r
+}
+
+// Current returns the configuration in effect, callers must treat it as read-only
+func (r *Reloader) Current() *Config {
+	return r.current.Load()
+}
+
+// OnReload registers a function that applies a new configuration, it is also called once with the current one
+func (r *Reloader) OnReload(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+	fn(r.Current())
+}
+
+// Reload loads the configuration again and applies the settings that can change at runtime.
+// Changes to other settings are logged and ignored, and an invalid configuration is rejected as a whole.
+func (r *Reloader) Reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, _, err := Load(r.args)
+	if err != nil {
+		r.log.Error(err, "Configuration reload rejected, keeping the current configuration")
+		return
+	}
+
+	current := r.Current()
+	currentSettings := current.settings()
+	changed := 0
+	for i, s := range next.settings() {
+		before := reflect.ValueOf(currentSettings[i].target).Elem()
+		after := reflect.ValueOf(s.target).Elem()
+		if reflect.DeepEqual(before.Interface(), after.Interface()) {
+			continue
+		}
+		if !reloadable[s.env] {
+			r.log.Error(errRestartRequired, "Ignoring change to %s", s.env)
+			after.Set(before)
+			continue
+		}
+		changed++
+	}
+
+	if changed == 0 {
+		r.log.Info("Configuration reloaded, no runtime settings changed")
+		return
+	}
+
+	r.current.Store(next)
+	for _, fn := range r.listeners {
+		fn(next)
+	}
+	r.log.Info("Configuration reloaded, %d runtime setting(s) applied", changed)
+}
+
+// Watch reloads the configuration on SIGHUP and whenever the config file changes, until ctx is done.
+// The signal handler is installed before Watch returns, so a SIGHUP sent right after startup is not lost.
+func (r *Reloader) Watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go r.watch(ctx, hup, r.watchFile())
+}
+
+// watchFile watches the config file, or returns nil when there is no file or it cannot be watched
+func (r *Reloader) watchFile() *fsnotify.Watcher {
+	file := r.Current().File
+	if file == "" {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		r.log.Error(err, "Failed to watch the config file, reload with SIGHUP instead")
+		return nil
+	}
+	// Watch the directory so that files replaced by rename, as editors and config maps do, are still seen
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		watcher.Close()
+		r.log.Error(err, "Failed to watch the config file, reload with SIGHUP instead")
+		return nil
+	}
+	r.log.Info("Watching %s for configuration changes", file)
+	return watcher
+}
+
+// watch reloads the configuration on each signal and debounced file change until ctx is done
+func (r *Reloader) watch(ctx context.Context, hup chan os.Signal, watcher *fsnotify.Watcher) {
+	defer signal.Stop(hup)
+
+	var fileEvents <-chan fsnotify.Event
+	var fileErrors <-chan error
+	if watcher != nil {
+		defer watcher.Close()
+		fileEvents, fileErrors = watcher.Events, watcher.Errors
+	}
+
+	debounce := time.NewTimer(reloadDebounce)
+	debounce.Stop()
+	target := filepath.Clean(r.Current().File)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.log.Info("Received SIGHUP, reloading configuration")
+			r.Reload()
+		case event := <-fileEvents:
+			if filepath.Clean(event.Name) == target || filepath.Base(event.Name) == "..data" {
+				debounce.Reset(reloadDebounce)
+			}
+		case err := <-fileErrors:
+			r.log.Error(err, "Config file watcher error")
+		case <-debounce.C:
+			r.log.Info("Config file changed, reloading configuration")
+			r.Reload()
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"xm-microservice/pkg/logger"
+)
+
+func newTestReloader(t *testing.T, content string) (*Reloader, string) {
+	t.Helper()
+	setRequired(t)
+	file := writeFile(t, "config.yaml", content)
+	args := []string{"--config", file}
+	cfg, _, err := Load(args)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	log := logger.NewLogger()
+	log.SetLevel(logger.LevelError)
+	return NewReloader(cfg, args, log), file
+}
+
+func TestReloadAppliesRuntimeSettingsOnly(t *testing.T) {
+	r, file := newTestReloader(t, "log_level: info\nport: \"8080\"\nrate_limit_api: 300/1m\n")
+
+	var applied []*Config
+	r.OnReload(func(cfg *Config) { applied = append(applied, cfg) })
+
+	if err := os.WriteFile(file, []byte("log_level: debug\nport: \"9090\"\nrate_limit_api: 50/1m\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r.Reload()
+
+	cfg := r.Current()
+	if cfg.LogLevel != "debug" || cfg.RateLimitAPI != "50/1m" {
+		t.Errorf("runtime settings = %q, %q, want debug, 50/1m", cfg.LogLevel, cfg.RateLimitAPI)
+	}
+	if cfg.Port != "8080" {
+		t.Errorf("Port = %q, want the value from startup until a restart", cfg.Port)
+	}
+	if len(applied) != 2 || applied[1] != cfg {
+		t.Errorf("listeners were called %d times, want once on registration and once on reload", len(applied))
+	}
+}
+
+func TestReloadKeepsCurrentConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "invalid", content: "log_level: verbose\n"},
+		{name: "unparsable", content: "log_level: [debug\n"},
+		{name: "restart only", content: "log_level: info\nport: \"9090\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, file := newTestReloader(t, "log_level: info\n")
+			before := r.Current()
+			calls := 0
+			r.OnReload(func(*Config) { calls++ })
+
+			if err := os.WriteFile(file, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			r.Reload()
+
+			if r.Current() != before {
+				t.Error("Reload() replaced the configuration")
+			}
+			if calls != 1 {
+				t.Errorf("listeners were called %d times after registering, want no reload call", calls-1)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"xm-microservice/internal/ratelimit"
+	"xm-microservice/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// minJWTSecretLength is the shortest JWT secret accepted, matching the 256-bit key size of HS256
+const minJWTSecretLength = 32
+
+// roles lists the roles that OIDC users can be mapped to
+var roles = []string{"user", "admin", "platform_admin"}
+
+// Validate reports every missing or invalid setting at once
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if _, err := logger.ParseLevel(c.LogLevel); err != nil {
+		add("LOG_LEVEL must be one of debug, info, error, got %q", c.LogLevel)
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		add("PORT must be a number between 1 and 65535, got %q", c.Port)
+	}
+	if c.ShutdownTimeout <= 0 {
+		add("SHUTDOWN_TIMEOUT must be positive")
+	}
+
+	if c.DatabaseURL == "" {
+		add("DATABASE_URL is required")
+	} else if !validURL(c.DatabaseURL) {
+		add("DATABASE_URL must be a postgres:// URL or a key=value connection string")
+	}
+	if c.DatabaseReplicaURL != "" && !validURL(c.DatabaseReplicaURL) {
+		add("DATABASE_REPLICA_URL must be a postgres:// URL or a key=value connection string")
+	}
+	if c.DBMaxOpenConns < 1 {
+		add("DB_MAX_OPEN_CONNS must be at least 1")
+	}
+	if c.DBMaxIdleConns < 0 || c.DBMaxIdleConns > c.DBMaxOpenConns {
+		add("DB_MAX_IDLE_CONNS must be between 0 and DB_MAX_OPEN_CONNS")
+	}
+	if c.DBConnMaxLifetime < 0 || c.DBConnMaxIdleTime < 0 {
+		add("DB_CONN_MAX_LIFETIME and DB_CONN_MAX_IDLE_TIME must not be negative")
+	}
+	if c.DBQueryTimeout <= 0 {
+		add("DB_QUERY_TIMEOUT must be positive")
+	}
+	if c.DBTxMaxRetries < 0 {
+		add("DB_TX_MAX_RETRIES must not be negative")
+	}
+
+	if c.IdempotencyTTL <= 0 {
+		add("IDEMPOTENCY_TTL must be positive")
+	}
+
+	if c.ImportMaxRows < 1 {
+		add("IMPORT_MAX_ROWS must be at least 1")
+	}
+	if c.ImportSyncRows < 0 {
+		add("IMPORT_SYNC_ROWS must not be negative")
+	}
+
+	if c.DuplicateThreshold <= 0 || c.DuplicateThreshold > 1 {
+		add("DUPLICATE_THRESHOLD must be greater than 0 and at most 1")
+	}
+	if c.DuplicateAction != "warn" && c.DuplicateAction != "reject" {
+		add("DUPLICATE_ACTION must be one of warn, reject, got %q", c.DuplicateAction)
+	}
+	if c.CompanyTypesCacheTTL <= 0 {
+		add("COMPANY_TYPES_CACHE_TTL must be positive")
+	}
+	if c.AttrSchemaCacheTTL <= 0 {
+		add("ATTRIBUTE_SCHEMA_CACHE_TTL must be positive")
+	}
+
+	switch {
+	case c.JWTSecret == "":
+		add("JWT_SECRET is required")
+	case len(c.JWTSecret) < minJWTSecretLength:
+		add("JWT_SECRET must be at least %d characters long", minJWTSecretLength)
+	}
+
+	if c.KafkaBroker == "" {
+		add("KAFKA_BROKER is required")
+	} else if _, port, err := net.SplitHostPort(c.KafkaBroker); err != nil || port == "" {
+		add("KAFKA_BROKER must be a host:port address, got %q", c.KafkaBroker)
+	}
+	if c.KafkaPartitions < 1 {
+		add("KAFKA_PARTITIONS must be at least 1")
+	}
+	if c.KafkaReplicationFactor < 1 {
+		add("KAFKA_REPLICATION_FACTOR must be at least 1")
+	}
+	if c.KafkaTopicCompany == "" {
+		add("KAFKA_TOPIC_COMPANY is required")
+	}
+	if c.KafkaPublishTimeout <= 0 {
+		add("KAFKA_PUBLISH_TIMEOUT must be positive")
+	}
+
+	if c.OIDCEnabled() {
+		if c.OIDCClientID == "" {
+			add("OIDC_CLIENT_ID is required when OIDC_ISSUER_URL is set")
+		}
+		if c.OIDCClientSecret == "" {
+			add("OIDC_CLIENT_SECRET is required when OIDC_ISSUER_URL is set")
+		}
+		if u, err := url.Parse(c.OIDCRedirectURL); err != nil || u.Scheme == "" || u.Host == "" {
+			add("OIDC_REDIRECT_URL must be an absolute URL")
+		}
+		if c.OIDCTenantClaim == "" && c.OIDCTenantID == "" {
+			add("OIDC_TENANT_CLAIM or OIDC_TENANT_ID is required when OIDC_ISSUER_URL is set")
+		}
+	}
+	if c.OIDCTenantID != "" {
+		if _, err := uuid.Parse(c.OIDCTenantID); err != nil {
+			add("OIDC_TENANT_ID must be a UUID")
+		}
+	}
+	if !slices.Contains(roles, c.OIDCDefaultRole) {
+		add("OIDC_DEFAULT_ROLE must be one of %s, got %q", strings.Join(roles, ", "), c.OIDCDefaultRole)
+	}
+	for group, role := range c.OIDCRoleMapping {
+		if !slices.Contains(roles, role) {
+			add("OIDC_ROLE_MAPPING maps group %q to unknown role %q", group, role)
+		}
+	}
+
+	for key, value := range map[string]string{
+		"RATE_LIMIT_LOGIN":  c.RateLimitLogin,
+		"RATE_LIMIT_SIGNUP": c.RateLimitSignup,
+		"RATE_LIMIT_API":    c.RateLimitAPI,
+	} {
+		if _, err := ratelimit.ParsePolicy(value); err != nil {
+			add("%s: %v", key, err)
+		}
+	}
+	if _, err := ratelimit.ParseTrustedProxies(c.TrustedProxies); err != nil {
+		add("TRUSTED_PROXIES: %v", err)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// validURL reports whether value is a PostgreSQL connection URL or a key=value connection string
+func validURL(value string) bool {
+	if !strings.Contains(value, "://") {
+		return strings.Contains(value, "=")
+	}
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql")
+}
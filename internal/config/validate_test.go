@@ -0,0 +1,88 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// validConfig returns the defaults with every required setting filled in
+func validConfig() *Config {
+	cfg := defaults()
+	cfg.DatabaseURL = "postgres://user:secret@db:5432/xm"
+	cfg.JWTSecret = testJWTSecret
+	cfg.KafkaBroker = "kafka:9092"
+	return cfg
+}
+
+func TestValidateAcceptsValidConfig(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	cfg := validConfig()
+	cfg.DatabaseURL = "host=db user=xm dbname=xm"
+	cfg.OIDCDefaultRole = "platform_admin"
+	cfg.OIDCRoleMapping = map[string]string{"ops": "platform_admin", "staff": "user"}
+	cfg.TrustedProxies = []string{"10.0.0.0/8", "192.0.2.1"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+}
+
+func TestValidateRequiresSettingsWithoutDefaults(t *testing.T) {
+	err := defaults().Validate()
+	if err == nil {
+		t.Fatal("Validate() accepted the defaults alone")
+	}
+	for _, want := range []string{"DATABASE_URL is required", "JWT_SECRET is required", "KAFKA_BROKER is required"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := validConfig()
+	cfg.LogLevel = "verbose"
+	cfg.Port = "80800"
+	cfg.DatabaseURL = "mysql://db/xm"
+	cfg.DBMaxIdleConns = cfg.DBMaxOpenConns + 1
+	cfg.JWTSecret = "short"
+	cfg.KafkaBroker = "kafka"
+	cfg.DuplicateThreshold = 1.5
+	cfg.DuplicateAction = "ignore"
+	cfg.OIDCDefaultRole = "root"
+	cfg.OIDCRoleMapping = map[string]string{"ops": "superuser"}
+	cfg.RateLimitAPI = "lots"
+	cfg.TrustedProxies = []string{"not-an-ip"}
+	cfg.OIDCIssuerURL = "https://issuer.example.com"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() accepted an invalid configuration")
+	}
+	wants := []string{
+		`LOG_LEVEL must be one of debug, info, error, got "verbose"`,
+		`PORT must be a number between 1 and 65535, got "80800"`,
+		"DATABASE_URL must be a postgres:// URL",
+		"DB_MAX_IDLE_CONNS must be between 0 and DB_MAX_OPEN_CONNS",
+		"JWT_SECRET must be at least 32 characters long",
+		`KAFKA_BROKER must be a host:port address, got "kafka"`,
+		"DUPLICATE_THRESHOLD must be greater than 0 and at most 1",
+		`DUPLICATE_ACTION must be one of warn, reject, got "ignore"`,
+		`OIDC_DEFAULT_ROLE must be one of user, admin, platform_admin, got "root"`,
+		`OIDC_ROLE_MAPPING maps group "ops" to unknown role "superuser"`,
+		"RATE_LIMIT_API:",
+		"TRUSTED_PROXIES:",
+		"OIDC_CLIENT_ID is required when OIDC_ISSUER_URL is set",
+		"OIDC_TENANT_CLAIM or OIDC_TENANT_ID is required when OIDC_ISSUER_URL is set",
+	}
+	for _, want := range wants {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() does not report %q", want)
+		}
+	}
+	if got := strings.Count(err.Error(), "\n  "); got < len(wants) {
+		t.Errorf("Validate() reported %d problems, want at least %d:\n%v", got, len(wants), err)
+	}
+}
@@ -1,72 +1,141 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"errors"
+	"time"
 
 	"xm-microservice/pkg/logger"
 
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/lib/pq"
 )
 
-// Connect establishes a connection to the PostgreSQL database and runs migrations
-func Connect(databaseURL string, log *logger.Logger) (*sql.DB, error) {
-	db, err := sql.Open("postgres", databaseURL)
+// PoolConfig holds the connection pool settings applied to every database handle
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// DB routes queries to the primary database or, for reads that tolerate replication lag, to an optional read replica
+type DB struct {
+	primary *sql.DB
+	replica *sql.DB
+}
+
+type primaryKey struct{}
+
+// Connect establishes connections to the PostgreSQL primary and, when a replica URL is given, to the read replica
+func Connect(databaseURL, replicaURL string, pool PoolConfig, log *logger.Logger) (*DB, error) {
+	primary, err := open(databaseURL, pool)
 	if err != nil {
-		log.Error(err, "Failed to open database connection")
+		log.Error(err, "Failed to connect to the primary database")
 		return nil, err
 	}
+	log.Info("Successfully connected to the database")
 
-	if err = db.Ping(); err != nil {
-		log.Error(err, "Database ping failed")
-		return nil, err
+	db := &DB{primary: primary}
+	if replicaURL == "" {
+		return db, nil
 	}
 
-	log.Info("Successfully connected to the database")
-
-	// Run database migrations
-	if err := runMigrations(databaseURL, log); err != nil {
-		log.Error(err, "Migration error")
+	replica, err := open(replicaURL, pool)
+	if err != nil {
+		primary.Close()
+		log.Error(err, "Failed to connect to the read replica")
 		return nil, err
 	}
+	db.replica = replica
+	log.Info("Successfully connected to the read replica")
 
 	return db, nil
 }
 
-// runMigrations applies any pending database migrations
-func runMigrations(databaseURL string, log *logger.Logger) error {
+// open opens a database handle with the pool settings applied and verifies it with a ping
+func open(databaseURL string, pool PoolConfig) (*sql.DB, error) {
 	db, err := sql.Open("postgres", databaseURL)
 	if err != nil {
-		log.Error(err, "Failed to open database for migrations")
-		return fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
-	defer db.Close()
 
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
-	if err != nil {
-		log.Error(err, "Failed to create migration driver")
-		return fmt.Errorf("failed to create migration driver: %w", err)
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Primary returns the handle for writes and reads that must see the latest data
+func (d *DB) Primary() *sql.DB {
+	return d.primary
+}
+
+// Writer returns the transaction in ctx, or the primary when no transaction is running
+func (d *DB) Writer(ctx context.Context) Querier {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx
 	}
+	return d.primary
+}
+
+// Reader returns the transaction in ctx, or the read replica when one is configured, unless ctx asks for the primary
+func (d *DB) Reader(ctx context.Context) Querier {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx
+	}
+	return d.readHandle(ctx)
+}
+
+// readHandle returns the read replica when one is configured, unless ctx asks for the primary
+func (d *DB) readHandle(ctx context.Context) *sql.DB {
+	if d.replica == nil {
+		return d.primary
+	}
+	if forced, _ := ctx.Value(primaryKey{}).(bool); forced {
+		return d.primary
+	}
+	return d.replica
+}
+
+// Close closes the primary and replica handles
+func (d *DB) Close() error {
+	var replicaErr error
+	if d.replica != nil {
+		replicaErr = d.replica.Close()
+	}
+	return errors.Join(d.primary.Close(), replicaErr)
+}
+
+// WithPrimary marks ctx so that reads go to the primary, for paths that must read their own writes
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
 
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://./internal/database/migrations", // Path to migration files
-		"postgres",                              // Database name
-		driver,                                  // PostgreSQL migration driver
-	)
+// PrepareSchema optionally applies pending migrations and then refuses to continue
+// when the schema is dirty or ahead of the migrations embedded in the binary
+func PrepareSchema(db *DB, migrateOnStartup bool, log *logger.Logger) error {
+	migrator, err := NewMigrator(db, log)
 	if err != nil {
-		log.Error(err, "Failed to initialize migration")
-		return fmt.Errorf("failed to initialize migration: %w", err)
+		return err
 	}
+	defer migrator.Close()
 
-	// Apply migrations
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		log.Error(err, "Migration failed")
-		return fmt.Errorf("migration failed: %w", err)
+	if migrateOnStartup {
+		if err := migrator.CheckSchema(); err != nil {
+			return err
+		}
+		if err := migrator.Up(); err != nil {
+			log.Error(err, "Migration error")
+			return err
+		}
 	}
 
-	log.Info("Database migrated successfully")
-	return nil
+	return migrator.CheckSchema()
 }
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"github.com/lib/pq"
+)
+
+// PostgreSQL error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	codeUniqueViolation      = "23505"
+	codeForeignKeyViolation  = "23503"
+	codeSerializationFailure = "40001"
+	codeDeadlockDetected     = "40P01"
+	classConnection          = "08"
+	classResources           = "53"
+	classOperatorIntervene   = "57"
+)
+
+// IsUniqueViolation reports whether err was caused by a unique constraint
+func IsUniqueViolation(err error) bool {
+	return hasCode(err, codeUniqueViolation)
+}
+
+// IsForeignKeyViolation reports whether err was caused by a foreign key constraint
+func IsForeignKeyViolation(err error) bool {
+	return hasCode(err, codeForeignKeyViolation)
+}
+
+// Constraint returns the name of the constraint or index that err violated, or "" when err is not a constraint violation
+func Constraint(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Constraint
+	}
+	return ""
+}
+
+// IsRetryable reports whether err is a serialization failure or deadlock that may succeed when the transaction is retried
+func IsRetryable(err error) bool {
+	return hasCode(err, codeSerializationFailure) || hasCode(err, codeDeadlockDetected)
+}
+
+// IsUnavailable reports whether err means the database could not be reached or refused work
+func IsUnavailable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case classConnection, classResources, classOperatorIntervene:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr)
+}
+
+// ExpectRows returns sql.ErrNoRows when a write matched no rows, so that callers can treat it like a missed lookup
+func ExpectRows(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// hasCode reports whether err is a PostgreSQL error with the given code
+func hasCode(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code
+}
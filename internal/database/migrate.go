@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"xm-microservice/pkg/logger"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrationsFS holds the SQL migrations compiled into the binary
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// ErrSchemaDirty is returned when a previous migration failed halfway and needs to be fixed with force
+var ErrSchemaDirty = errors.New("database schema is dirty")
+
+// ErrSchemaAhead is returned when the database was migrated by a newer binary
+var ErrSchemaAhead = errors.New("database schema is newer than this binary")
+
+// Migrator applies the embedded migrations to a database
+type Migrator struct {
+	m   *migrate.Migrate
+	log *logger.Logger
+}
+
+// NewMigrator borrows a connection from the primary pool and prepares the embedded migrations
+func NewMigrator(db *DB, log *logger.Logger) (*Migrator, error) {
+	conn, err := db.Primary().Conn(context.Background())
+	if err != nil {
+		log.Error(err, "Failed to acquire connection for migrations")
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	// The driver returns the connection to the pool when the migrator is closed
+	driver, err := postgres.WithConnection(context.Background(), conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		log.Error(err, "Failed to create migration driver")
+		return nil, fmt.Errorf("failed to create migration driver: %w", err)
+	}
+
+	source, err := iofs.New(migrationsFS, "migrations")
+	if err != nil {
+		driver.Close()
+		log.Error(err, "Failed to load embedded migrations")
+		return nil, fmt.Errorf("failed to load embedded migrations: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		driver.Close()
+		log.Error(err, "Failed to initialize migration")
+		return nil, fmt.Errorf("failed to initialize migration: %w", err)
+	}
+
+	return &Migrator{m: m, log: log}, nil
+}
+
+// Up applies all pending migrations
+func (mg *Migrator) Up() error {
+	if err := mg.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	mg.log.Info("Database migrated successfully")
+	return nil
+}
+
+// Down rolls back the given number of migrations
+func (mg *Migrator) Down(steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("invalid number of steps: %d", steps)
+	}
+	if err := mg.m.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("rollback failed: %w", err)
+	}
+	mg.log.Info("Rolled back %d migration(s)", steps)
+	return nil
+}
+
+// Goto migrates up or down to the given version
+func (mg *Migrator) Goto(version uint) error {
+	if err := mg.m.Migrate(version); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migration to version %d failed: %w", version, err)
+	}
+	mg.log.Info("Database migrated to version %d", version)
+	return nil
+}
+
+// Force sets the schema version without running migrations and clears the dirty flag
+func (mg *Migrator) Force(version int) error {
+	if err := mg.m.Force(version); err != nil {
+		return fmt.Errorf("failed to force version %d: %w", version, err)
+	}
+	mg.log.Info("Database version forced to %d", version)
+	return nil
+}
+
+// Version returns the current schema version and whether it is dirty
+func (mg *Migrator) Version() (uint, bool, error) {
+	version, dirty, err := mg.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// CheckSchema refuses schemas that are dirty or were migrated past the latest embedded migration
+func (mg *Migrator) CheckSchema() error {
+	version, dirty, err := mg.Version()
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("%w at version %d, fix it and run 'migrate force'", ErrSchemaDirty, version)
+	}
+
+	latest, err := LatestVersion()
+	if err != nil {
+		return err
+	}
+	if version > latest {
+		return fmt.Errorf("%w: database is at version %d, binary knows up to %d", ErrSchemaAhead, version, latest)
+	}
+	if version < latest {
+		mg.log.Info("Database schema is at version %d, latest available is %d", version, latest)
+	}
+	return nil
+}
+
+// Close returns the migration connection to the pool
+func (mg *Migrator) Close() {
+	if sourceErr, dbErr := mg.m.Close(); sourceErr != nil || dbErr != nil {
+		mg.log.Error(errors.Join(sourceErr, dbErr), "Failed to close migrator")
+	}
+}
+
+// LatestVersion returns the highest migration version embedded in the binary
+func LatestVersion() (uint, error) {
+	source, err := iofs.New(migrationsFS, "migrations")
+	if err != nil {
+		return 0, fmt.Errorf("failed to load embedded migrations: %w", err)
+	}
+	defer source.Close()
+
+	version, err := source.First()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+	for {
+		next, err := source.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read embedded migrations: %w", err)
+		}
+		version = next
+	}
+}
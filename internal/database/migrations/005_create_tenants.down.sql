@@ -0,0 +1,5 @@
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_tenant_id_name_key;
+ALTER TABLE companies ADD CONSTRAINT companies_name_key UNIQUE (name);
+ALTER TABLE companies DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
+DROP TABLE IF EXISTS tenants;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS tenants (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Existing users and companies belong to the default tenant
+INSERT INTO tenants (id, name) VALUES ('00000000-0000-0000-0000-000000000001', 'default') ON CONFLICT (id) DO NOTHING;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES tenants(id);
+ALTER TABLE users ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS tenant_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES tenants(id);
+ALTER TABLE companies ALTER COLUMN tenant_id DROP DEFAULT;
+
+-- Company names are unique per tenant instead of globally
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_name_key;
+ALTER TABLE companies ADD CONSTRAINT companies_tenant_id_name_key UNIQUE (tenant_id, name);
+
+CREATE INDEX IF NOT EXISTS idx_users_tenant_id ON users (tenant_id);
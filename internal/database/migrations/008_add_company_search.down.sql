@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_companies_name_trgm;
+DROP INDEX IF EXISTS idx_companies_search_vector;
+ALTER TABLE companies DROP COLUMN IF EXISTS search_vector;
+DROP EXTENSION IF EXISTS pg_trgm;
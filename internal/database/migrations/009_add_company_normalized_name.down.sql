@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_companies_normalized_name_trgm;
+ALTER TABLE companies DROP COLUMN IF EXISTS normalized_name;
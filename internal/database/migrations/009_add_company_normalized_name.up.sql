@@ -0,0 +1,10 @@
+-- Mirrors company.normalizeName so that existing companies take part in near-duplicate detection
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS normalized_name TEXT NOT NULL DEFAULT '';
+
+UPDATE companies SET normalized_name = regexp_replace(
+    btrim(regexp_replace(regexp_replace(lower(name), '[''’]', '', 'g'), '[^[:alnum:]]+', ' ', 'g')),
+    '( (ltd|limited|inc|incorporated|llc|llp|lp|corp|corporation|co|company|plc|gmbh|ag|sa|sas|sarl|srl|spa|bv|nv|ab|oy|pty|pte))+$',
+    ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_companies_normalized_name_trgm ON companies USING GIN (normalized_name gin_trgm_ops);
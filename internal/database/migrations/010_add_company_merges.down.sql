@@ -0,0 +1,8 @@
+DELETE FROM companies WHERE merged_into IS NOT NULL;
+
+DROP INDEX IF EXISTS companies_tenant_id_name_key;
+ALTER TABLE companies ADD CONSTRAINT companies_tenant_id_name_key UNIQUE (tenant_id, name);
+
+DROP INDEX IF EXISTS idx_companies_merged_into;
+ALTER TABLE companies DROP COLUMN IF EXISTS merged_at;
+ALTER TABLE companies DROP COLUMN IF EXISTS merged_into;
@@ -0,0 +1,9 @@
+-- A merged company stays behind as a tombstone pointing to the company it was merged into
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS merged_into UUID REFERENCES companies(id) ON DELETE CASCADE;
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS merged_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_companies_merged_into ON companies (merged_into) WHERE merged_into IS NOT NULL;
+
+-- Tombstones keep their name without blocking it for live companies
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_tenant_id_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS companies_tenant_id_name_key ON companies (tenant_id, name) WHERE merged_into IS NULL;
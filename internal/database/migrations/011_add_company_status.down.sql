@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS company_status_changes;
+
+ALTER TABLE companies DROP COLUMN registered;
+ALTER TABLE companies ADD COLUMN registered BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE companies SET registered = (status = 'registered');
+ALTER TABLE companies ALTER COLUMN registered DROP DEFAULT;
+
+ALTER TABLE companies DROP COLUMN IF EXISTS status;
@@ -0,0 +1,20 @@
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS status VARCHAR(30) NOT NULL DEFAULT 'draft';
+UPDATE companies SET status = 'registered' WHERE registered;
+
+-- registered is kept for existing clients and now follows the status
+ALTER TABLE companies DROP COLUMN registered;
+ALTER TABLE companies ADD COLUMN registered BOOLEAN GENERATED ALWAYS AS (status = 'registered') STORED;
+
+CREATE TABLE IF NOT EXISTS company_status_changes (
+    id UUID PRIMARY KEY,
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    transition VARCHAR(30) NOT NULL,
+    from_status VARCHAR(30) NOT NULL,
+    to_status VARCHAR(30) NOT NULL,
+    reason VARCHAR(1000),
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_company_status_changes_company_id ON company_status_changes (company_id);
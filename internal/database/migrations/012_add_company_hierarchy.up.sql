@@ -0,0 +1,9 @@
+-- Subsidiaries are detached before their parent is deleted, so the foreign key only guards against stray references
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES companies(id) ON DELETE SET NULL;
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS ownership_percentage NUMERIC(5, 2)
+    CHECK (ownership_percentage > 0 AND ownership_percentage <= 100);
+
+ALTER TABLE companies ADD CONSTRAINT companies_parent_id_check CHECK (parent_id <> id);
+ALTER TABLE companies ADD CONSTRAINT companies_ownership_parent_check CHECK (ownership_percentage IS NULL OR parent_id IS NOT NULL);
+
+CREATE INDEX IF NOT EXISTS idx_companies_parent_id ON companies (parent_id) WHERE parent_id IS NOT NULL;
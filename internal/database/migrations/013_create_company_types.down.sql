@@ -0,0 +1,2 @@
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_type_fkey;
+DROP TABLE IF EXISTS company_types;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS company_types (
+    code VARCHAR(50) PRIMARY KEY,
+    display_name VARCHAR(100) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO company_types (code, display_name) VALUES
+    ('Corporation', 'Corporation'),
+    ('NonProfit', 'Non-profit'),
+    ('Cooperative', 'Cooperative'),
+    ('Sole Proprietorship', 'Sole proprietorship')
+ON CONFLICT (code) DO NOTHING;
+
+-- Any other type already in use is kept, but deprecated, so that existing companies stay valid
+INSERT INTO company_types (code, display_name, active)
+SELECT DISTINCT type, type, FALSE FROM companies
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE companies ADD CONSTRAINT companies_type_fkey FOREIGN KEY (type) REFERENCES company_types(code);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS company_attribute_schema;
+DROP INDEX IF EXISTS companies_attributes_idx;
+ALTER TABLE companies DROP COLUMN IF EXISTS attributes;
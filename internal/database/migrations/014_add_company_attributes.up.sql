@@ -0,0 +1,12 @@
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS companies_attributes_idx ON companies USING GIN (attributes jsonb_path_ops);
+
+-- A single row holds the schema; the check keeps a second one from being inserted
+CREATE TABLE IF NOT EXISTS company_attribute_schema (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    document JSONB NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO company_attribute_schema (document) VALUES ('{"type": "object"}') ON CONFLICT (id) DO NOTHING;
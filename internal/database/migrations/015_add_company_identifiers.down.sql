@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS companies_lei_key;
+DROP INDEX IF EXISTS companies_tax_id_key;
+DROP INDEX IF EXISTS companies_registration_number_key;
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_identifiers_jurisdiction_check;
+ALTER TABLE companies DROP COLUMN IF EXISTS lei;
+ALTER TABLE companies DROP COLUMN IF EXISTS tax_id;
+ALTER TABLE companies DROP COLUMN IF EXISTS registration_number;
+ALTER TABLE companies DROP COLUMN IF EXISTS jurisdiction;
@@ -0,0 +1,15 @@
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS jurisdiction VARCHAR(2) NOT NULL DEFAULT '';
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS registration_number VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS tax_id VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS lei VARCHAR(20) NOT NULL DEFAULT '';
+
+-- Registration numbers and tax identifiers only mean something within a jurisdiction
+ALTER TABLE companies ADD CONSTRAINT companies_identifiers_jurisdiction_check
+    CHECK (jurisdiction <> '' OR (registration_number = '' AND tax_id = ''));
+
+CREATE UNIQUE INDEX IF NOT EXISTS companies_registration_number_key ON companies (tenant_id, jurisdiction, registration_number)
+    WHERE registration_number <> '' AND merged_into IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS companies_tax_id_key ON companies (tenant_id, jurisdiction, tax_id)
+    WHERE tax_id <> '' AND merged_into IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS companies_lei_key ON companies (tenant_id, lei)
+    WHERE lei <> '' AND merged_into IS NULL;
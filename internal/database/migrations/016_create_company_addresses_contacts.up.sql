@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS company_addresses (
+    id UUID PRIMARY KEY,
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('registered', 'trading')),
+    line1 VARCHAR(200) NOT NULL,
+    line2 VARCHAR(200) NOT NULL DEFAULT '',
+    city VARCHAR(100) NOT NULL,
+    region VARCHAR(100) NOT NULL DEFAULT '',
+    postal_code VARCHAR(20) NOT NULL DEFAULT '',
+    country VARCHAR(2) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_company_addresses_company_id ON company_addresses (company_id);
+-- A company has at most one registered address
+CREATE UNIQUE INDEX IF NOT EXISTS company_addresses_registered_key ON company_addresses (company_id) WHERE kind = 'registered';
+
+CREATE TABLE IF NOT EXISTS company_contacts (
+    id UUID PRIMARY KEY,
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    role VARCHAR(100) NOT NULL DEFAULT '',
+    email VARCHAR(254) NOT NULL DEFAULT '',
+    phone VARCHAR(16) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (email <> '' OR phone <> '')
+);
+
+CREATE INDEX IF NOT EXISTS idx_company_contacts_company_id ON company_contacts (company_id);
+-- Emails are stored normalized, so the same address cannot be added twice to one company
+CREATE UNIQUE INDEX IF NOT EXISTS company_contacts_email_key ON company_contacts (company_id, email) WHERE email <> '';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS curated_tags;
+DROP INDEX IF EXISTS companies_tags_idx;
+ALTER TABLE companies DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,11 @@
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS companies_tags_idx ON companies USING GIN (tags);
+
+-- Curated tags are suggested to every tenant; companies may also carry free-form tags outside this list
+CREATE TABLE IF NOT EXISTS curated_tags (
+    name VARCHAR(40) PRIMARY KEY,
+    description VARCHAR(200) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1 @@
+DELETE FROM mfa_role_policies WHERE role = 'platform_admin';
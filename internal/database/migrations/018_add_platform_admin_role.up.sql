@@ -0,0 +1,2 @@
+-- Platform admins manage every tenant, so they sign in with a second factor like tenant admins
+INSERT INTO mfa_role_policies (role, required) VALUES ('platform_admin', TRUE) ON CONFLICT (role) DO NOTHING;
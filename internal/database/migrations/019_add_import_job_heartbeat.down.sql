@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_import_jobs_unfinished;
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS heartbeat_at;
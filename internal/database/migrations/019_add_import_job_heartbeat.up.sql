@@ -0,0 +1,4 @@
+-- Workers touch their job while it runs, so that jobs abandoned by a stopped instance can be told apart
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS idx_import_jobs_unfinished ON import_jobs (heartbeat_at) WHERE status IN ('pending', 'running');
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"xm-microservice/pkg/logger"
+)
+
+// retryBackoff is the base delay before a transaction is retried, multiplied by the attempt number
+const retryBackoff = 20 * time.Millisecond
+
+// Querier is implemented by both *sql.DB and *sql.Tx so repositories can run inside or outside a transaction
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+// TxManager runs service operations that span several repository calls in a single transaction
+type TxManager struct {
+	db         *DB
+	maxRetries int
+	log        *logger.Logger
+}
+
+// NewTxManager initializes a transaction manager that retries serialization failures up to maxRetries times
+func NewTxManager(db *DB, maxRetries int, log *logger.Logger) *TxManager {
+	return &TxManager{db: db, maxRetries: maxRetries, log: log}
+}
+
+// WithinTx runs fn in a read-write transaction on the primary
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return m.WithinTxOptions(ctx, nil, fn)
+}
+
+// WithinTxOptions runs fn in a transaction with the given options, committing when fn succeeds
+// and rolling back otherwise. Repository calls made with the context passed to fn join the
+// transaction, and a nested call joins the outer transaction instead of starting a new one.
+// fn is run again from the start when the transaction fails with a serialization failure or a
+// deadlock, so it must not have side effects outside the database.
+func (m *TxManager) WithinTxOptions(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := m.run(ctx, m.db.Primary(), opts, fn)
+		if err == nil || !IsRetryable(err) || attempt >= m.maxRetries {
+			return err
+		}
+
+		m.log.Info("Retrying transaction after conflict (attempt %d of %d): %v", attempt+1, m.maxRetries, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt+1) * retryBackoff):
+		}
+	}
+}
+
+// WithinReadTx runs fn in a read-only transaction with the given isolation level on the read replica,
+// or on the primary when no replica is configured or ctx asks for it. Unlike WithinTx it is never
+// retried, so fn may stream what it reads to a client.
+func (m *TxManager) WithinReadTx(ctx context.Context, isolation sql.IsolationLevel, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+	return m.run(ctx, m.db.readHandle(ctx), &sql.TxOptions{Isolation: isolation, ReadOnly: true}, fn)
+}
+
+// run executes a single attempt of a transaction on the given handle
+func (m *TxManager) run(ctx context.Context, handle *sql.DB, opts *sql.TxOptions, fn func(ctx context.Context) error) error {
+	tx, err := handle.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// txFromContext returns the transaction started by a TxManager, if any
+func txFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok
+}
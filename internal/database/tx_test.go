@@ -0,0 +1,263 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"xm-microservice/pkg/logger"
+
+	"github.com/lib/pq"
+)
+
+// fakeDriver hands out connections that only support transactions and records how they are used
+type fakeDriver struct {
+	mu        sync.Mutex
+	begins    []driver.TxOptions
+	commits   int
+	rollbacks int
+}
+
+var fakeDrivers sync.Map
+
+func init() {
+	sql.Register("fake", fakeConnector{})
+}
+
+// fakeConnector opens connections on the fakeDriver named by the data source name
+type fakeConnector struct{}
+
+func (fakeConnector) Open(name string) (driver.Conn, error) {
+	d, ok := fakeDrivers.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown fake database %q", name)
+	}
+	return &fakeConn{driver: d.(*fakeDriver)}, nil
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(_ context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.driver.begins = append(c.driver.begins, opts)
+	return &fakeTx{driver: c.driver}, nil
+}
+
+type fakeTx struct {
+	driver *fakeDriver
+}
+
+func (t *fakeTx) Commit() error {
+	t.driver.mu.Lock()
+	defer t.driver.mu.Unlock()
+	t.driver.commits++
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.driver.mu.Lock()
+	defer t.driver.mu.Unlock()
+	t.driver.rollbacks++
+	return nil
+}
+
+// openFake opens a database handle on a new fakeDriver registered under the test and role names
+func openFake(t *testing.T, role string) (*sql.DB, *fakeDriver) {
+	t.Helper()
+	name := t.Name() + "/" + role
+	d := &fakeDriver{}
+	fakeDrivers.Store(name, d)
+	db, err := sql.Open("fake", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		fakeDrivers.Delete(name)
+	})
+	return db, d
+}
+
+func newTestTxManager(t *testing.T, maxRetries int) (*TxManager, *fakeDriver) {
+	t.Helper()
+	primary, d := openFake(t, "primary")
+	log := logger.NewLogger()
+	log.SetLevel(logger.LevelError)
+	return NewTxManager(&DB{primary: primary}, maxRetries, log), d
+}
+
+func TestWithinTxRetriesConflicts(t *testing.T) {
+	for _, code := range []pq.ErrorCode{codeSerializationFailure, codeDeadlockDetected} {
+		t.Run(string(code), func(t *testing.T) {
+			m, d := newTestTxManager(t, 3)
+
+			attempts := 0
+			start := time.Now()
+			err := m.WithinTx(context.Background(), func(ctx context.Context) error {
+				attempts++
+				if attempts < 3 {
+					return &pq.Error{Code: code}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("WithinTx() = %v, want nil after a retried conflict", err)
+			}
+			if attempts != 3 {
+				t.Errorf("fn ran %d times, want 3", attempts)
+			}
+			// The attempts wait one and then two backoff periods
+			if elapsed := time.Since(start); elapsed < 3*retryBackoff {
+				t.Errorf("retries took %v, want at least %v of backoff", elapsed, 3*retryBackoff)
+			}
+			if len(d.begins) != 3 || d.commits != 1 || d.rollbacks != 2 {
+				t.Errorf("begins, commits, rollbacks = %d, %d, %d, want 3, 1, 2", len(d.begins), d.commits, d.rollbacks)
+			}
+		})
+	}
+}
+
+func TestWithinTxGivesUpAfterMaxRetries(t *testing.T) {
+	m, d := newTestTxManager(t, 2)
+
+	attempts := 0
+	conflict := &pq.Error{Code: codeSerializationFailure}
+	err := m.WithinTx(context.Background(), func(ctx context.Context) error {
+		attempts++
+		return conflict
+	})
+	if !errors.Is(err, conflict) {
+		t.Fatalf("WithinTx() = %v, want the last conflict", err)
+	}
+	if attempts != 3 {
+		t.Errorf("fn ran %d times, want the first attempt and 2 retries", attempts)
+	}
+	if d.commits != 0 || d.rollbacks != 3 {
+		t.Errorf("commits, rollbacks = %d, %d, want 0, 3", d.commits, d.rollbacks)
+	}
+}
+
+func TestWithinTxDoesNotRetryOtherErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "unique violation", err: &pq.Error{Code: "23505"}},
+		{name: "application error", err: errors.New("boom")},
+		{name: "no rows", err: sql.ErrNoRows},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, d := newTestTxManager(t, 3)
+
+			attempts := 0
+			err := m.WithinTx(context.Background(), func(ctx context.Context) error {
+				attempts++
+				return tt.err
+			})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("WithinTx() = %v, want %v", err, tt.err)
+			}
+			if attempts != 1 || d.commits != 0 || d.rollbacks != 1 {
+				t.Errorf("attempts, commits, rollbacks = %d, %d, %d, want 1, 0, 1", attempts, d.commits, d.rollbacks)
+			}
+		})
+	}
+}
+
+func TestWithinTxStopsRetryingWhenContextEnds(t *testing.T) {
+	m, _ := newTestTxManager(t, 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var attempts atomic.Int32
+	err := m.WithinTx(ctx, func(context.Context) error {
+		attempts.Add(1)
+		cancel()
+		return &pq.Error{Code: codeSerializationFailure}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("WithinTx() = %v, want context.Canceled", err)
+	}
+	if attempts.Load() != 1 {
+		t.Errorf("fn ran %d times, want 1", attempts.Load())
+	}
+}
+
+func TestWithinTxNestedCallJoinsTransaction(t *testing.T) {
+	m, d := newTestTxManager(t, 3)
+
+	err := m.WithinTx(context.Background(), func(outer context.Context) error {
+		outerTx, _ := txFromContext(outer)
+		return m.WithinTxOptions(outer, &sql.TxOptions{Isolation: sql.LevelSerializable}, func(inner context.Context) error {
+			innerTx, ok := txFromContext(inner)
+			if !ok || innerTx != outerTx {
+				t.Error("nested call did not reuse the outer transaction")
+			}
+			if m.db.Writer(inner) != innerTx {
+				t.Error("Writer inside the nested call is not the outer transaction")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("WithinTx() = %v", err)
+	}
+	if len(d.begins) != 1 || d.commits != 1 {
+		t.Errorf("begins, commits = %d, %d, want one transaction", len(d.begins), d.commits)
+	}
+}
+
+func TestWithinReadTxUsesReplicaAndIsNotRetried(t *testing.T) {
+	primary, primaryDriver := openFake(t, "primary")
+	replica, replicaDriver := openFake(t, "replica")
+
+	log := logger.NewLogger()
+	log.SetLevel(logger.LevelError)
+	m := NewTxManager(&DB{primary: primary, replica: replica}, 3, log)
+
+	attempts := 0
+	conflict := &pq.Error{Code: codeSerializationFailure}
+	err := m.WithinReadTx(context.Background(), sql.LevelRepeatableRead, func(ctx context.Context) error {
+		attempts++
+		return conflict
+	})
+	if !errors.Is(err, conflict) || attempts != 1 {
+		t.Fatalf("WithinReadTx() = %v after %d attempts, want the conflict after 1", err, attempts)
+	}
+	if len(primaryDriver.begins) != 0 {
+		t.Errorf("WithinReadTx began %d transactions on the primary", len(primaryDriver.begins))
+	}
+	if len(replicaDriver.begins) != 1 {
+		t.Fatalf("WithinReadTx began %d transactions on the replica, want 1", len(replicaDriver.begins))
+	}
+	opts := replicaDriver.begins[0]
+	if !opts.ReadOnly || sql.IsolationLevel(opts.Isolation) != sql.LevelRepeatableRead {
+		t.Errorf("replica transaction options = %+v, want read-only repeatable read", opts)
+	}
+
+	if err := m.WithinReadTx(WithPrimary(context.Background()), sql.LevelRepeatableRead, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("WithinReadTx() on the primary = %v", err)
+	}
+	if len(primaryDriver.begins) != 1 {
+		t.Errorf("WithinReadTx with WithPrimary began %d transactions on the primary, want 1", len(primaryDriver.begins))
+	}
+}
@@ -2,36 +2,47 @@ package event
 
 import (
 	"context"
+	"time"
 
 	"xm-microservice/pkg/logger"
 
 	"github.com/segmentio/kafka-go"
 )
 
+// TenantHeader is the message header identifying the tenant an event belongs to
+const TenantHeader = "tenant_id"
+
 // Producer represents a Kafka message producer
 type Producer struct {
-	writer *kafka.Writer
-	log    *logger.Logger
+	writer  *kafka.Writer
+	timeout time.Duration
+	log     *logger.Logger
 }
 
-// NewProducer initializes a new Kafka producer
-func NewProducer(brokerAddress, topic string, log *logger.Logger) *Producer {
+// NewProducer initializes a new Kafka producer whose publishes are bounded by the given timeout
+func NewProducer(brokerAddress, topic string, timeout time.Duration, log *logger.Logger) *Producer {
 	writer := &kafka.Writer{
 		Addr:     kafka.TCP(brokerAddress),
 		Topic:    topic,
 		Balancer: &kafka.LeastBytes{},
 	}
-	return &Producer{writer: writer, log: log}
+	return &Producer{writer: writer, timeout: timeout, log: log}
 }
 
-// PublishMessage sends a message to the Kafka topic
-func (p *Producer) PublishMessage(key, value string) error {
+// PublishMessage sends a message with optional headers to the Kafka topic
+func (p *Producer) PublishMessage(ctx context.Context, key, value string, headers map[string]string) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
 	message := kafka.Message{
 		Key:   []byte(key),
 		Value: []byte(value),
 	}
+	for name, headerValue := range headers {
+		message.Headers = append(message.Headers, kafka.Header{Key: name, Value: []byte(headerValue)})
+	}
 
-	if err := p.writer.WriteMessages(context.Background(), message); err != nil {
+	if err := p.writer.WriteMessages(ctx, message); err != nil {
 		p.log.Error(err, "Failed to publish message to Kafka")
 		return err
 	}
@@ -0,0 +1,51 @@
+package feature
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"xm-microservice/pkg/utils"
+)
+
+// Features that can be switched off while the server runs
+const (
+	Signup = "signup"
+	Import = "import"
+	Export = "export"
+)
+
+// Toggles holds which features are switched on and can be changed while requests are served
+type Toggles struct {
+	enabled atomic.Pointer[map[string]bool]
+}
+
+// NewToggles initializes Toggles with every feature switched on
+func NewToggles() *Toggles {
+	t := &Toggles{}
+	t.Set(nil)
+	return t
+}
+
+// Set replaces all toggles atomically, a feature without a toggle is switched on
+func (t *Toggles) Set(enabled map[string]bool) {
+	t.enabled.Store(&enabled)
+}
+
+// Enabled reports whether the named feature is switched on
+func (t *Toggles) Enabled(name string) bool {
+	enabled, ok := (*t.enabled.Load())[name]
+	return !ok || enabled
+}
+
+// Middleware rejects requests to the named feature with 503 while it is switched off
+func (t *Toggles) Middleware(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !t.Enabled(name) {
+				utils.ErrorResponse(w, r, http.StatusServiceUnavailable, "This feature is currently switched off")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
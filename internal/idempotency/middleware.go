@@ -0,0 +1,279 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"xm-microservice/internal/auth"
+	"xm-microservice/internal/database"
+	"xm-microservice/pkg/apperror"
+	"xm-microservice/pkg/logger"
+	"xm-microservice/pkg/requestid"
+	"xm-microservice/pkg/utils"
+)
+
+const (
+	// Header is the request header carrying the client-chosen idempotency key
+	Header = "Idempotency-Key"
+	// ReplayedHeader marks a response that was replayed from an earlier request
+	ReplayedHeader = "Idempotent-Replayed"
+
+	// DefaultMaxBodyBytes caps the body of a keyed request, which is read into memory to fingerprint it
+	DefaultMaxBodyBytes = 1 << 20
+
+	maxKeyLength = 255
+)
+
+var ErrUnavailable = apperror.New(apperror.ErrUnavailable, "idempotency key storage is unavailable")
+
+// Middleware makes POST requests with an Idempotency-Key safe to retry by replaying the first response
+type Middleware struct {
+	repo Repository
+	ttl  time.Duration
+	log  *logger.Logger
+}
+
+// NewMiddleware initializes the idempotency middleware, keeping responses for ttl
+func NewMiddleware(repo Repository, ttl time.Duration, log *logger.Logger) *Middleware {
+	return &Middleware{repo: repo, ttl: ttl, log: log}
+}
+
+// Handler stores the first response to a keyed POST request of an authenticated user and replays it for
+// identical retries. Requests without a key, or from anonymous callers, pass through unchanged.
+// Keyed requests with a body over DefaultMaxBodyBytes are rejected.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return m.HandlerWithLimit(DefaultMaxBodyBytes)(next)
+}
+
+// HandlerWithLimit works like Handler for routes whose keyed requests may carry up to maxBytes of body
+func (m *Middleware) HandlerWithLimit(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return m.handle(next, maxBytes)
+	}
+}
+
+func (m *Middleware) handle(next http.Handler, maxBytes int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		identity, authenticated := auth.IdentityFromContext(r.Context())
+		if r.Method != http.MethodPost || key == "" || !authenticated {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxKeyLength {
+			violations := &apperror.ValidationError{}
+			violations.Add(Header, apperror.CodeTooLong, "must be at most 255 characters")
+			utils.WriteError(w, r, violations.Err())
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.WriteProblem(w, r, utils.Problem{
+				Type:   "/problems/request-too-large",
+				Title:  "Request too large",
+				Status: http.StatusRequestEntityTooLarge,
+				Detail: fmt.Sprintf("Requests with an Idempotency-Key are limited to %d bytes", tooLarge.Limit),
+			})
+			return
+		}
+		if err != nil {
+			m.log.Error(err, "Failed to read request body")
+			utils.ErrorResponse(w, r, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		record := &Record{
+			UserID:      identity.UserID,
+			Key:         key,
+			RequestHash: hashRequest(r, body),
+			ExpiresAt:   time.Now().Add(m.ttl),
+		}
+
+		reserved, err := m.repo.Reserve(r.Context(), record)
+		if err != nil {
+			m.writeStorageError(w, r, err)
+			return
+		}
+		if !reserved {
+			m.replay(w, r, record)
+			return
+		}
+
+		m.log.Debug("Idempotency key %s reserved for user %s", key, identity.UserID)
+		m.execute(w, r, next, record)
+	})
+}
+
+// execute runs the request and stores its response, releasing the key when the request failed on the server
+func (m *Middleware) execute(w http.ResponseWriter, r *http.Request, next http.Handler, record *Record) {
+	capture := &responseCapture{ResponseWriter: w, status: http.StatusOK}
+	// The outcome is stored even if the client has gone away, since the work itself is done
+	ctx := context.WithoutCancel(r.Context())
+
+	completed := false
+	defer func() {
+		if !completed {
+			if err := m.repo.Release(ctx, record.UserID, record.Key); err != nil {
+				m.log.Error(err, "Failed to release idempotency key")
+			}
+		}
+	}()
+
+	next.ServeHTTP(capture, r)
+
+	// Server errors are not stored so that a retry gets another chance
+	if capture.status >= http.StatusInternalServerError {
+		return
+	}
+
+	if capture.headers == nil {
+		capture.headers = storedHeaders(w.Header())
+	}
+	if err := m.repo.Complete(ctx, record.UserID, record.Key, capture.status, capture.headers, capture.body.Bytes()); err != nil {
+		m.log.Error(err, "Failed to store idempotent response")
+		return
+	}
+	completed = true
+}
+
+// replay writes the stored response of an earlier request with the same key
+func (m *Middleware) replay(w http.ResponseWriter, r *http.Request, attempt *Record) {
+	record, err := m.repo.Get(r.Context(), attempt.UserID, attempt.Key)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The first request failed and released the key in the meantime
+		m.writeInProgress(w, r)
+		return
+	}
+	if err != nil {
+		m.writeStorageError(w, r, err)
+		return
+	}
+
+	if record.RequestHash != attempt.RequestHash {
+		m.log.Info("Idempotency key %s reused with a different request", attempt.Key)
+		utils.WriteProblem(w, r, utils.Problem{
+			Type:   "/problems/idempotency-key-reused",
+			Title:  "Idempotency key reused",
+			Status: http.StatusUnprocessableEntity,
+			Detail: "The Idempotency-Key was already used for a different request",
+		})
+		return
+	}
+	if !record.Completed() {
+		m.writeInProgress(w, r)
+		return
+	}
+
+	m.log.Info("Replaying response for idempotency key %s", attempt.Key)
+	for name, values := range record.Headers {
+		w.Header()[name] = values
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(*record.StatusCode)
+	if _, err := w.Write(record.Body); err != nil {
+		m.log.Error(err, "Failed to write replayed response")
+	}
+}
+
+// writeInProgress reports that another request with the same key has not finished yet
+func (m *Middleware) writeInProgress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "1")
+	utils.WriteProblem(w, r, utils.Problem{
+		Type:   "/problems/idempotency-key-in-progress",
+		Title:  "Request in progress",
+		Status: http.StatusConflict,
+		Detail: "A request with this Idempotency-Key is still being processed",
+	})
+}
+
+// writeStorageError maps a failure of the key storage to a problem response
+func (m *Middleware) writeStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	m.log.Error(err, "Failed to access idempotency keys")
+	if database.IsUnavailable(err) {
+		utils.WriteError(w, r, ErrUnavailable.WithCause(err))
+		return
+	}
+	utils.WriteError(w, r, err)
+}
+
+// PurgeExpired deletes expired keys at the given interval until ctx is done
+func (m *Middleware) PurgeExpired(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := m.repo.DeleteExpired(ctx)
+			if err != nil {
+				m.log.Error(err, "Failed to purge expired idempotency keys")
+				continue
+			}
+			if removed > 0 {
+				m.log.Info("Purged %d expired idempotency key(s)", removed)
+			}
+		}
+	}
+}
+
+// hashRequest fingerprints the method, path, query and body so that a key cannot be reused for another request
+func hashRequest(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// storedHeaders copies the response headers set by the handler, leaving out those that describe the current request
+func storedHeaders(header http.Header) http.Header {
+	stored := http.Header{}
+	for name, values := range header {
+		if name == http.CanonicalHeaderKey(requestid.Header) || name == "Retry-After" || strings.HasPrefix(name, "Ratelimit-") {
+			continue
+		}
+		stored[name] = append([]string(nil), values...)
+	}
+	return stored
+}
+
+// responseCapture passes the response through to the client while keeping a copy of it
+type responseCapture struct {
+	http.ResponseWriter
+	status  int
+	headers http.Header
+	body    bytes.Buffer
+	written bool
+}
+
+func (c *responseCapture) WriteHeader(status int) {
+	if c.written {
+		return
+	}
+	c.written = true
+	c.status = status
+	c.headers = storedHeaders(c.ResponseWriter.Header())
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *responseCapture) Write(b []byte) (int, error) {
+	if !c.written {
+		c.WriteHeader(http.StatusOK)
+	}
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
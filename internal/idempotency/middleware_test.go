@@ -0,0 +1,235 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"xm-microservice/internal/auth"
+	"xm-microservice/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// fakeRepository keeps records in memory, keyed by user and key
+type fakeRepository struct {
+	mu       sync.Mutex
+	records  map[string]*Record
+	released int
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{records: map[string]*Record{}}
+}
+
+func recordKey(userID uuid.UUID, key string) string {
+	return userID.String() + "/" + key
+}
+
+func (r *fakeRepository) Reserve(_ context.Context, record *Record) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.records[recordKey(record.UserID, record.Key)]; ok {
+		return false, nil
+	}
+	copied := *record
+	r.records[recordKey(record.UserID, record.Key)] = &copied
+	return true, nil
+}
+
+func (r *fakeRepository) Get(_ context.Context, userID uuid.UUID, key string) (*Record, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.records[recordKey(userID, key)]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *record
+	return &copied, nil
+}
+
+func (r *fakeRepository) Complete(_ context.Context, userID uuid.UUID, key string, statusCode int, headers http.Header, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record := r.records[recordKey(userID, key)]
+	record.StatusCode, record.Headers, record.Body = &statusCode, headers, body
+	return nil
+}
+
+func (r *fakeRepository) Release(_ context.Context, userID uuid.UUID, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.records, recordKey(userID, key))
+	r.released++
+	return nil
+}
+
+func (r *fakeRepository) DeleteExpired(context.Context) (int64, error) {
+	return 0, nil
+}
+
+func newTestMiddleware(repo Repository) *Middleware {
+	log := logger.NewLogger()
+	log.SetLevel(logger.LevelError)
+	return NewMiddleware(repo, time.Hour, log)
+}
+
+// keyedRequest builds a POST request of the user with an Idempotency-Key
+func keyedRequest(userID uuid.UUID, key, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/companies", strings.NewReader(body))
+	req.Header.Set(Header, key)
+	return req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{UserID: userID}))
+}
+
+// countingHandler creates a resource per call and echoes the request body
+type countingHandler struct {
+	mu     sync.Mutex
+	calls  int
+	status int
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	h.mu.Lock()
+	h.calls++
+	h.mu.Unlock()
+	w.Header().Set("Location", "/api/companies/1")
+	w.WriteHeader(h.status)
+	w.Write(body)
+}
+
+func TestHandlerReservesAndReplays(t *testing.T) {
+	repo := newFakeRepository()
+	next := &countingHandler{status: http.StatusCreated}
+	handler := newTestMiddleware(repo).Handler(next)
+	userID := uuid.New()
+
+	first := httptest.NewRecorder()
+	handler.ServeHTTP(first, keyedRequest(userID, "key-1", `{"name":"Acme"}`))
+	if first.Code != http.StatusCreated || first.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf("first request = %d, replayed %q, want 201 and not replayed", first.Code, first.Header().Get(ReplayedHeader))
+	}
+	record, err := repo.Get(context.Background(), userID, "key-1")
+	if err != nil || !record.Completed() {
+		t.Fatalf("key-1 after the first request = %+v, %v, want a completed record", record, err)
+	}
+
+	retry := httptest.NewRecorder()
+	handler.ServeHTTP(retry, keyedRequest(userID, "key-1", `{"name":"Acme"}`))
+	if retry.Code != http.StatusCreated || retry.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("retry = %d, replayed %q, want 201 replayed", retry.Code, retry.Header().Get(ReplayedHeader))
+	}
+	if retry.Body.String() != `{"name":"Acme"}` || retry.Header().Get("Location") != "/api/companies/1" {
+		t.Errorf("retry body, location = %q, %q, want those of the first response", retry.Body.String(), retry.Header().Get("Location"))
+	}
+	if next.calls != 1 {
+		t.Errorf("handler ran %d times, want 1", next.calls)
+	}
+
+	// Keys are scoped to the user
+	other := httptest.NewRecorder()
+	handler.ServeHTTP(other, keyedRequest(uuid.New(), "key-1", `{"name":"Acme"}`))
+	if other.Header().Get(ReplayedHeader) != "" || next.calls != 2 {
+		t.Errorf("another user's request was replayed, handler ran %d times", next.calls)
+	}
+}
+
+func TestHandlerRejectsKeyReusedForAnotherRequest(t *testing.T) {
+	next := &countingHandler{status: http.StatusCreated}
+	handler := newTestMiddleware(newFakeRepository()).Handler(next)
+	userID := uuid.New()
+
+	handler.ServeHTTP(httptest.NewRecorder(), keyedRequest(userID, "key-1", `{"name":"Acme"}`))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, keyedRequest(userID, "key-1", `{"name":"Globex"}`))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	if next.calls != 1 {
+		t.Errorf("handler ran %d times, want 1", next.calls)
+	}
+}
+
+func TestHandlerReleasesKeyWhenRequestFails(t *testing.T) {
+	repo := newFakeRepository()
+	next := &countingHandler{status: http.StatusServiceUnavailable}
+	handler := newTestMiddleware(repo).Handler(next)
+	userID := uuid.New()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, keyedRequest(userID, "key-1", `{"name":"Acme"}`))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if repo.released != 1 {
+		t.Errorf("key released %d times, want 1", repo.released)
+	}
+
+	next.status = http.StatusCreated
+	retry := httptest.NewRecorder()
+	handler.ServeHTTP(retry, keyedRequest(userID, "key-1", `{"name":"Acme"}`))
+	if retry.Code != http.StatusCreated || retry.Header().Get(ReplayedHeader) != "" || next.calls != 2 {
+		t.Errorf("retry = %d, replayed %q after %d calls, want the request to run again", retry.Code, retry.Header().Get(ReplayedHeader), next.calls)
+	}
+}
+
+func TestHandlerRejectsRetryWhileInFlight(t *testing.T) {
+	started, finish := make(chan struct{}), make(chan struct{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+		w.WriteHeader(http.StatusCreated)
+	})
+	handler := newTestMiddleware(newFakeRepository()).Handler(next)
+	userID := uuid.New()
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(first, keyedRequest(userID, "key-1", `{"name":"Acme"}`))
+	}()
+	<-started
+
+	retry := httptest.NewRecorder()
+	handler.ServeHTTP(retry, keyedRequest(userID, "key-1", `{"name":"Acme"}`))
+	close(finish)
+	<-done
+
+	if retry.Code != http.StatusConflict || retry.Header().Get("Retry-After") != "1" {
+		t.Errorf("retry while in flight = %d with Retry-After %q, want 409 with 1", retry.Code, retry.Header().Get("Retry-After"))
+	}
+	if first.Code != http.StatusCreated {
+		t.Errorf("first request = %d, want %d", first.Code, http.StatusCreated)
+	}
+}
+
+func TestHandlerLimitsBody(t *testing.T) {
+	repo := newFakeRepository()
+	next := &countingHandler{status: http.StatusCreated}
+	m := newTestMiddleware(repo)
+	userID := uuid.New()
+
+	rec := httptest.NewRecorder()
+	m.HandlerWithLimit(8)(next).ServeHTTP(rec, keyedRequest(userID, "key-1", `{"name":"Acme"}`))
+	if rec.Code != http.StatusRequestEntityTooLarge || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("oversized request = %d %q, want a 413 problem", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if next.calls != 0 || len(repo.records) != 0 {
+		t.Errorf("oversized request ran the handler %d times and stored %d keys", next.calls, len(repo.records))
+	}
+
+	// Requests without a key are left to the handler
+	unkeyed := keyedRequest(userID, "", `{"name":"Acme"}`)
+	m.HandlerWithLimit(8)(next).ServeHTTP(httptest.NewRecorder(), unkeyed)
+	if next.calls != 1 {
+		t.Errorf("unkeyed request ran the handler %d times, want 1", next.calls)
+	}
+}
@@ -0,0 +1,24 @@
+package idempotency
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Record is the stored outcome of the first request made with an idempotency key
+type Record struct {
+	UserID      uuid.UUID
+	Key         string
+	RequestHash string
+	StatusCode  *int
+	Headers     http.Header
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+// Completed reports whether the first request has finished and its response can be replayed
+func (r *Record) Completed() bool {
+	return r.StatusCode != nil
+}
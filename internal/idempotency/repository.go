@@ -0,0 +1,120 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"xm-microservice/internal/database"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	Reserve(ctx context.Context, record *Record) (bool, error)
+	Get(ctx context.Context, userID uuid.UUID, key string) (*Record, error)
+	Complete(ctx context.Context, userID uuid.UUID, key string, statusCode int, headers http.Header, body []byte) error
+	Release(ctx context.Context, userID uuid.UUID, key string) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type repository struct {
+	db      *database.DB
+	timeout time.Duration
+}
+
+// NewRepository initializes a new idempotency key repository with the database connection and query timeout
+func NewRepository(db *database.DB, timeout time.Duration) Repository {
+	return &repository{db: db, timeout: timeout}
+}
+
+// Reserve claims a key for a new request and reports false if an unexpired record already holds it
+func (r *repository) Reserve(ctx context.Context, record *Record) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// An expired record is replaced as if the key had never been used
+	query := `INSERT INTO idempotency_keys (user_id, key, request_hash, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, status_code = NULL, headers = NULL, body = NULL,
+			created_at = NOW(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, record.UserID, record.Key, record.RequestHash, record.ExpiresAt)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+// Get retrieves the record held by a key
+func (r *repository) Get(ctx context.Context, userID uuid.UUID, key string) (*Record, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT user_id, key, request_hash, status_code, headers, body, expires_at FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+	record := &Record{}
+	var statusCode sql.NullInt64
+	var headers []byte
+	err := r.db.Writer(ctx).QueryRowContext(ctx, query, userID, key).Scan(
+		&record.UserID,
+		&record.Key,
+		&record.RequestHash,
+		&statusCode,
+		&headers,
+		&record.Body,
+		&record.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode.Valid {
+		code := int(statusCode.Int64)
+		record.StatusCode = &code
+	}
+	if headers != nil {
+		if err := json.Unmarshal(headers, &record.Headers); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// Complete stores the response of the first request so that retries can replay it
+func (r *repository) Complete(ctx context.Context, userID uuid.UUID, key string, statusCode int, headers http.Header, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE idempotency_keys SET status_code = $1, headers = $2, body = $3 WHERE user_id = $4 AND key = $5`
+	_, err = r.db.Writer(ctx).ExecContext(ctx, query, statusCode, encoded, body, userID, key)
+	return err
+}
+
+// Release frees a key whose request failed, so that the client can retry it
+func (r *repository) Release(ctx context.Context, userID uuid.UUID, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.Writer(ctx).ExecContext(ctx, `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2`, userID, key)
+	return err
+}
+
+// DeleteExpired removes all records past their expiry and returns how many were removed
+func (r *repository) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.Writer(ctx).ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
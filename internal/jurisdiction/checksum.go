@@ -0,0 +1,57 @@
+package jurisdiction
+
+// mod97 returns the remainder of dividing an alphanumeric string by 97, reading letters as 10 to 35 as in ISO 7064
+func mod97(value string) int {
+	remainder := 0
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			remainder = (remainder*10 + int(r-'0')) % 97
+		case r >= 'A' && r <= 'Z':
+			remainder = (remainder*100 + int(r-'A') + 10) % 97
+		}
+	}
+	return remainder
+}
+
+// mod1110 returns the ISO 7064 MOD 11,10 check digit of a string of digits
+func mod1110(digits string) int {
+	product := 10
+	for _, r := range digits {
+		sum := (int(r-'0') + product) % 10
+		if sum == 0 {
+			sum = 10
+		}
+		product = (2 * sum) % 11
+	}
+	check := 11 - product
+	if check == 10 {
+		return 0
+	}
+	return check
+}
+
+// luhn reports whether a string of digits passes the Luhn check
+func luhn(digits string) bool {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// atoi converts a string of digits to an int
+func atoi(digits string) int {
+	n := 0
+	for _, r := range digits {
+		n = n*10 + int(r-'0')
+	}
+	return n
+}
@@ -0,0 +1,60 @@
+package jurisdiction
+
+import "testing"
+
+func TestMod97(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		// The IBAN GB82 WEST 1234 5698 7654 32 with its country code and check digits moved to the end
+		{value: "WEST12345698765432GB82", want: 1},
+		{value: "WEST12345698765432GB83", want: 2},
+		{value: "97", want: 0},
+		{value: "A", want: 10},
+		{value: "Z", want: 35},
+		{value: "", want: 0},
+	}
+	for _, tt := range tests {
+		if got := mod97(tt.value); got != tt.want {
+			t.Errorf("mod97(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMod1110(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   int
+	}{
+		// The first eight digits of the German VAT numbers DE136695976 and DE811907980
+		{digits: "13669597", want: 6},
+		{digits: "81190798", want: 0},
+		{digits: "00000000", want: 3},
+	}
+	for _, tt := range tests {
+		if got := mod1110(tt.digits); got != tt.want {
+			t.Errorf("mod1110(%q) = %d, want %d", tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestLuhn(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   bool
+	}{
+		{digits: "79927398713", want: true},
+		{digits: "79927398710", want: false},
+		{digits: "4111111111111111", want: true},
+		{digits: "4111111111111112", want: false},
+		{digits: "0", want: true},
+		{digits: "18", want: true},
+		{digits: "81", want: false},
+	}
+	for _, tt := range tests {
+		if got := luhn(tt.digits); got != tt.want {
+			t.Errorf("luhn(%q) = %v, want %v", tt.digits, got, tt.want)
+		}
+	}
+}
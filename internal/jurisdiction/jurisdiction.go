@@ -0,0 +1,66 @@
+package jurisdiction
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Validator checks an identifier and returns it in canonical form
+type Validator func(value string) (string, error)
+
+// Rules holds the identifier validators of a jurisdiction. A nil validator falls back to a generic format check.
+type Rules struct {
+	RegistrationNumber Validator
+	TaxID              Validator
+}
+
+var (
+	mu       sync.RWMutex
+	registry = builtinRules()
+)
+
+// Register sets the identifier rules of a country, replacing any registered before
+func Register(country string, rules Rules) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[country] = rules
+}
+
+// rulesFor returns the identifier rules registered for a country
+func rulesFor(country string) Rules {
+	mu.RLock()
+	defer mu.RUnlock()
+	return registry[country]
+}
+
+// genericIdentifier is the format accepted from countries without a specific validator
+var genericIdentifier = regexp.MustCompile(`^[A-Z0-9][A-Z0-9./-]{0,49}$`)
+
+// ValidateRegistrationNumber checks the official registration number of a company in a country
+func ValidateRegistrationNumber(country, value string) (string, error) {
+	return validate(rulesFor(country).RegistrationNumber, value)
+}
+
+// ValidateTaxID checks the tax identifier of a company in a country
+func ValidateTaxID(country, value string) (string, error) {
+	return validate(rulesFor(country).TaxID, value)
+}
+
+// validate applies a validator, or the generic format check when there is none
+func validate(validator Validator, value string) (string, error) {
+	if validator != nil {
+		return validator(value)
+	}
+	value = strings.ToUpper(strings.Join(strings.Fields(value), ""))
+	if !genericIdentifier.MatchString(value) {
+		return "", errors.New("must be up to 50 letters, digits, dots, slashes and hyphens")
+	}
+	return value, nil
+}
+
+// compact upper-cases an identifier and removes the spaces, dots and hyphens that people write in it
+func compact(value string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", ".", "", "-", "", "\t", "").Replace(value))
+}
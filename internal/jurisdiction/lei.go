@@ -0,0 +1,21 @@
+package jurisdiction
+
+import (
+	"errors"
+	"regexp"
+)
+
+var leiFormat = regexp.MustCompile(`^[A-Z0-9]{18}[0-9]{2}$`)
+
+// ValidateLEI checks a Legal Entity Identifier against ISO 17442: 18 letters or digits followed by
+// two check digits that make the whole identifier leave remainder 1 when divided by 97
+func ValidateLEI(value string) (string, error) {
+	value = compact(value)
+	if !leiFormat.MatchString(value) {
+		return "", errors.New("must be 18 letters or digits followed by 2 check digits")
+	}
+	if mod97(value) != 1 {
+		return "", errors.New("has invalid check digits")
+	}
+	return value, nil
+}
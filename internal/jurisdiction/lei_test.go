@@ -0,0 +1,32 @@
+package jurisdiction
+
+import "testing"
+
+func TestValidateLEI(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "5493001KJTIIGC8Y1R12", want: "5493001KJTIIGC8Y1R12"},
+		{value: "7LTWFZYICNSX8D621K86", want: "7LTWFZYICNSX8D621K86"},
+		{value: "HWUPKR0MPOU8FGXBT394", want: "HWUPKR0MPOU8FGXBT394"},
+		{value: "5299 00t8 bm49 aurs do55", want: "529900T8BM49AURSDO55"},
+		{value: "5493001KJTIIGC8Y1R17", wantErr: true},
+		{value: "7LTWFZYICNSX8D621K68", wantErr: true},
+		{value: "5493001KJTIIGC8Y1R1", wantErr: true},
+		{value: "5493001KJTIIGC8Y1RAB", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ValidateLEI(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateLEI(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ValidateLEI(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package jurisdiction
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// euVATFormats holds the VAT number formats of the EU member states after their VIES prefix, which is the
+// country code except for Greece
+var euVATFormats = map[string]struct {
+	prefix string
+	format string
+	check  func(number string) bool
+}{
+	"AT": {"AT", `U[0-9]{8}`, nil},
+	"BE": {"BE", `[01][0-9]{9}`, checkBelgianVAT},
+	"BG": {"BG", `[0-9]{9,10}`, nil},
+	"CY": {"CY", `[0-9]{8}[A-Z]`, nil},
+	"CZ": {"CZ", `[0-9]{8,10}`, nil},
+	"DE": {"DE", `[0-9]{9}`, checkGermanVAT},
+	"DK": {"DK", `[0-9]{8}`, nil},
+	"EE": {"EE", `[0-9]{9}`, nil},
+	"ES": {"ES", `[A-Z0-9][0-9]{7}[A-Z0-9]`, nil},
+	"FI": {"FI", `[0-9]{8}`, nil},
+	"FR": {"FR", `[A-HJ-NP-Z0-9]{2}[0-9]{9}`, checkFrenchVAT},
+	"GR": {"EL", `[0-9]{9}`, nil},
+	"HR": {"HR", `[0-9]{11}`, nil},
+	"HU": {"HU", `[0-9]{8}`, nil},
+	"IE": {"IE", `[0-9][0-9A-Z+*][0-9]{5}[A-W][A-I]?`, nil},
+	"IT": {"IT", `[0-9]{11}`, luhn},
+	"LT": {"LT", `[0-9]{9}|[0-9]{12}`, nil},
+	"LU": {"LU", `[0-9]{8}`, nil},
+	"LV": {"LV", `[0-9]{11}`, nil},
+	"MT": {"MT", `[0-9]{8}`, nil},
+	"NL": {"NL", `[0-9]{9}B[0-9]{2}`, nil},
+	"PL": {"PL", `[0-9]{10}`, nil},
+	"PT": {"PT", `[0-9]{9}`, nil},
+	"RO": {"RO", `[0-9]{2,10}`, nil},
+	"SE": {"SE", `[0-9]{10}01`, nil},
+	"SI": {"SI", `[0-9]{8}`, nil},
+	"SK": {"SK", `[0-9]{10}`, nil},
+}
+
+// builtinRules returns the identifier rules that ship with the service
+func builtinRules() map[string]Rules {
+	rules := map[string]Rules{
+		"GB": {RegistrationNumber: validateUKCompanyNumber, TaxID: validateUKVAT},
+		"US": {TaxID: validateEIN},
+	}
+	for country, vat := range euVATFormats {
+		rules[country] = Rules{TaxID: euVATValidator(vat.prefix, vat.format, vat.check)}
+	}
+	return rules
+}
+
+// euVATValidator checks VAT numbers with or without the prefix and returns them with it
+func euVATValidator(prefix, format string, check func(number string) bool) Validator {
+	pattern := regexp.MustCompile(`^(?:` + format + `)$`)
+	return func(value string) (string, error) {
+		number := strings.TrimPrefix(compact(value), prefix)
+		if !pattern.MatchString(number) {
+			return "", fmt.Errorf("is not a valid %s VAT number", prefix)
+		}
+		if check != nil && !check(number) {
+			return "", errors.New("has an invalid check digit")
+		}
+		return prefix + number, nil
+	}
+}
+
+// checkBelgianVAT verifies that the last two digits are 97 minus the first eight modulo 97
+func checkBelgianVAT(number string) bool {
+	return 97-atoi(number[:8])%97 == atoi(number[8:])
+}
+
+// checkGermanVAT verifies the ISO 7064 MOD 11,10 check digit
+func checkGermanVAT(number string) bool {
+	return mod1110(number[:8]) == int(number[8]-'0')
+}
+
+// checkFrenchVAT verifies a numeric key against the SIREN that follows it; letter keys have no published check
+func checkFrenchVAT(number string) bool {
+	key, siren := number[:2], number[2:]
+	if strings.IndexFunc(key, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return true
+	}
+	return (12+3*(atoi(siren)%97))%97 == atoi(key)
+}
+
+var (
+	ukCompanyNumber = regexp.MustCompile(`^(?:[0-9]{8}|[A-Z]{2}[0-9]{6})$`)
+	ukVAT           = regexp.MustCompile(`^(?:[0-9]{9}|[0-9]{12}|GD[0-4][0-9]{2}|HA[5-9][0-9]{2})$`)
+	ein             = regexp.MustCompile(`^[0-9]{9}$`)
+)
+
+// validateUKCompanyNumber checks a Companies House number, restoring the leading zeros of purely numeric ones
+func validateUKCompanyNumber(value string) (string, error) {
+	value = compact(value)
+	if len(value) < 8 && value != "" && strings.Trim(value, "0123456789") == "" {
+		value = strings.Repeat("0", 8-len(value)) + value
+	}
+	if !ukCompanyNumber.MatchString(value) {
+		return "", errors.New("must be 8 digits, or 2 letters followed by 6 digits")
+	}
+	return value, nil
+}
+
+// validateUKVAT checks a UK VAT number, including the modulus 97 check of standard and branch numbers
+func validateUKVAT(value string) (string, error) {
+	number := strings.TrimPrefix(compact(value), "GB")
+	if !ukVAT.MatchString(number) {
+		return "", errors.New("is not a valid GB VAT number")
+	}
+	if number[0] >= '0' && number[0] <= '9' {
+		sum := atoi(number[7:9])
+		for i := 0; i < 7; i++ {
+			sum += int(number[i]-'0') * (8 - i)
+		}
+		if sum%97 != 0 && (sum+55)%97 != 0 {
+			return "", errors.New("has invalid check digits")
+		}
+	}
+	return "GB" + number, nil
+}
+
+// validateEIN checks a US Employer Identification Number and returns it as NN-NNNNNNN
+func validateEIN(value string) (string, error) {
+	value = compact(value)
+	if !ein.MatchString(value) {
+		return "", errors.New("must be 9 digits")
+	}
+	return value[:2] + "-" + value[2:], nil
+}
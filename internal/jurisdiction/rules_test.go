@@ -0,0 +1,90 @@
+package jurisdiction
+
+import "testing"
+
+func TestValidateTaxID(t *testing.T) {
+	tests := []struct {
+		country string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{country: "BE", value: "BE0417497106", want: "BE0417497106"},
+		{country: "BE", value: "0403.170.701", want: "BE0403170701"},
+		{country: "BE", value: "BE0202239951", want: "BE0202239951"},
+		{country: "BE", value: "BE0417497107", wantErr: true},
+		{country: "BE", value: "BE2417497106", wantErr: true},
+		{country: "BE", value: "BE041749710", wantErr: true},
+
+		{country: "DE", value: "DE136695976", want: "DE136695976"},
+		{country: "DE", value: "811 907 980", want: "DE811907980"},
+		{country: "DE", value: "DE136695977", wantErr: true},
+		{country: "DE", value: "DE13669597", wantErr: true},
+
+		{country: "FR", value: "FR40303265045", want: "FR40303265045"},
+		{country: "FR", value: "fr 44 732829320", want: "FR44732829320"},
+		{country: "FR", value: "FR41303265045", wantErr: true},
+		{country: "FR", value: "FRAB303265045", want: "FRAB303265045"},
+		{country: "FR", value: "FRIO303265045", wantErr: true},
+
+		{country: "GB", value: "GB980780684", want: "GB980780684"},
+		{country: "GB", value: "434 0314 94", want: "GB434031494"},
+		{country: "GB", value: "GB980780684001", want: "GB980780684001"},
+		{country: "GB", value: "GB980780685", wantErr: true},
+		{country: "GB", value: "GBGD123", want: "GBGD123"},
+		{country: "GB", value: "GBGD523", wantErr: true},
+		{country: "GB", value: "GBHA599", want: "GBHA599"},
+
+		{country: "IT", value: "IT00743110157", want: "IT00743110157"},
+		{country: "IT", value: "IT00743110158", wantErr: true},
+
+		{country: "GR", value: "EL094014201", want: "EL094014201"},
+		{country: "AT", value: "ATU13585627", want: "ATU13585627"},
+		{country: "AT", value: "AT13585627", wantErr: true},
+
+		{country: "US", value: "12-3456789", want: "12-3456789"},
+		{country: "US", value: "1234567", wantErr: true},
+
+		{country: "CH", value: "che-123.456.789", want: "CHE-123.456.789"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.country+"/"+tt.value, func(t *testing.T) {
+			got, err := ValidateTaxID(tt.country, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateTaxID(%q, %q) error = %v, wantErr %v", tt.country, tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ValidateTaxID(%q, %q) = %q, want %q", tt.country, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRegistrationNumber(t *testing.T) {
+	tests := []struct {
+		country string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{country: "GB", value: "00445790", want: "00445790"},
+		{country: "GB", value: "445790", want: "00445790"},
+		{country: "GB", value: "sc123456", want: "SC123456"},
+		{country: "GB", value: "SC12345", wantErr: true},
+		{country: "GB", value: "123456789", wantErr: true},
+		{country: "DE", value: "HRB 86891", want: "HRB86891"},
+		{country: "DE", value: "HRB 86891!", wantErr: true},
+		{country: "DE", value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.country+"/"+tt.value, func(t *testing.T) {
+			got, err := ValidateRegistrationNumber(tt.country, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateRegistrationNumber(%q, %q) error = %v, wantErr %v", tt.country, tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ValidateRegistrationNumber(%q, %q) = %q, want %q", tt.country, tt.value, got, tt.want)
+			}
+		})
+	}
+}
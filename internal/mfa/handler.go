@@ -0,0 +1,66 @@
+package mfa
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"xm-microservice/pkg/logger"
+	"xm-microservice/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// Handler manages the admin endpoints for MFA role policies
+type Handler struct {
+	service Service
+	logger  *logger.Logger
+}
+
+// NewHandler initializes a new MFA policy handler
+func NewHandler(service Service, logger *logger.Logger) *Handler {
+	return &Handler{service: service, logger: logger}
+}
+
+// ListRolePolicies returns the MFA policy of every configured role
+func (h *Handler) ListRolePolicies(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("ListRolePolicies handler invoked")
+
+	policies, err := h.service.ListRolePolicies(r.Context())
+	if err != nil {
+		h.logger.Error(err, "Failed to list MFA role policies")
+		utils.ErrorResponse(w, r, http.StatusInternalServerError, "Failed to list MFA role policies")
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, policies)
+}
+
+// SetRolePolicy enforces or relaxes MFA for the role in the path
+func (h *Handler) SetRolePolicy(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("SetRolePolicy handler invoked")
+
+	var req struct {
+		Required *bool `json:"required"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Required == nil {
+		h.logger.Error(err, "Invalid input while decoding MFA policy")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	role := mux.Vars(r)["role"]
+	policy, err := h.service.SetRolePolicy(r.Context(), role, *req.Required)
+	if err != nil {
+		h.logger.Error(err, "Failed to update MFA role policy")
+		if errors.Is(err, ErrInvalidRole) {
+			utils.ErrorResponse(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.ErrorResponse(w, r, http.StatusInternalServerError, "Failed to update MFA role policy")
+		return
+	}
+
+	h.logger.Info("MFA policy for role %s set to required=%t", role, policy.Required)
+	utils.JSONResponse(w, http.StatusOK, policy)
+}
@@ -0,0 +1,28 @@
+package mfa
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Enrollment holds the TOTP secret of a user and whether it has been confirmed
+type Enrollment struct {
+	UserID       uuid.UUID
+	Secret       string
+	Enabled      bool
+	LastUsedStep int64
+}
+
+// EnrollmentResponse is returned when a user starts TOTP enrollment
+type EnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// RolePolicy defines whether users with a given role must use a second factor
+type RolePolicy struct {
+	Role      string    `json:"role"`
+	Required  bool      `json:"required"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,167 @@
+package mfa
+
+import (
+	"context"
+	"time"
+
+	"xm-microservice/internal/database"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	SaveEnrollment(ctx context.Context, userID uuid.UUID, secret string) error
+	GetEnrollment(ctx context.Context, userID uuid.UUID) (*Enrollment, error)
+	EnableEnrollment(ctx context.Context, userID uuid.UUID, step int64) error
+	MarkStepUsed(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+	GetRolePolicy(ctx context.Context, role string) (*RolePolicy, error)
+	ListRolePolicies(ctx context.Context) ([]RolePolicy, error)
+	SaveRolePolicy(ctx context.Context, policy *RolePolicy) error
+}
+
+type repository struct {
+	db      *database.DB
+	timeout time.Duration
+}
+
+// NewRepository initializes a new MFA repository with the database connection and query timeout
+// MFA state guards logins, so every query goes to the primary to avoid acting on stale replica data
+func NewRepository(db *database.DB, timeout time.Duration) Repository {
+	return &repository{db: db, timeout: timeout}
+}
+
+// SaveEnrollment stores a pending TOTP secret, replacing any earlier unconfirmed one
+func (r *repository) SaveEnrollment(ctx context.Context, userID uuid.UUID, secret string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `INSERT INTO user_mfa (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		WHERE user_mfa.enabled = FALSE`
+	_, err := r.db.Writer(ctx).ExecContext(ctx, query, userID, secret)
+	return err
+}
+
+// GetEnrollment retrieves the TOTP enrollment of a user
+func (r *repository) GetEnrollment(ctx context.Context, userID uuid.UUID) (*Enrollment, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT user_id, secret, enabled, last_used_step FROM user_mfa WHERE user_id = $1`
+	enrollment := &Enrollment{}
+	err := r.db.Writer(ctx).QueryRowContext(ctx, query, userID).Scan(
+		&enrollment.UserID,
+		&enrollment.Secret,
+		&enrollment.Enabled,
+		&enrollment.LastUsedStep,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+// EnableEnrollment marks the enrollment as confirmed and records the step used to confirm it
+func (r *repository) EnableEnrollment(ctx context.Context, userID uuid.UUID, step int64) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE user_mfa SET enabled = TRUE, enabled_at = NOW(), last_used_step = $1 WHERE user_id = $2`
+	_, err := r.db.Writer(ctx).ExecContext(ctx, query, step, userID)
+	return err
+}
+
+// MarkStepUsed records a TOTP time step as consumed and reports false if it was already used
+func (r *repository) MarkStepUsed(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE user_mfa SET last_used_step = $1 WHERE user_id = $2 AND last_used_step < $1`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, step, userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+// ReplaceRecoveryCodes discards all recovery codes of a user and stores the new hashes
+// Callers run it inside a transaction so a failure never leaves the user without codes
+func (r *repository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	db := r.db.Writer(ctx)
+	if _, err := db.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		if _, err := db.ExecContext(ctx, `INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode consumes an unused recovery code and reports whether it was valid
+func (r *repository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE mfa_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+// GetRolePolicy retrieves the MFA policy for a role
+func (r *repository) GetRolePolicy(ctx context.Context, role string) (*RolePolicy, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT role, required, updated_at FROM mfa_role_policies WHERE role = $1`
+	policy := &RolePolicy{}
+	if err := r.db.Writer(ctx).QueryRowContext(ctx, query, role).Scan(&policy.Role, &policy.Required, &policy.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// ListRolePolicies retrieves the MFA policies of all roles
+func (r *repository) ListRolePolicies(ctx context.Context) ([]RolePolicy, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.Writer(ctx).QueryContext(ctx, `SELECT role, required, updated_at FROM mfa_role_policies ORDER BY role`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []RolePolicy{}
+	for rows.Next() {
+		var policy RolePolicy
+		if err := rows.Scan(&policy.Role, &policy.Required, &policy.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
+// SaveRolePolicy creates or updates the MFA policy for a role
+func (r *repository) SaveRolePolicy(ctx context.Context, policy *RolePolicy) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `INSERT INTO mfa_role_policies (role, required, updated_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (role) DO UPDATE SET required = EXCLUDED.required, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at`
+	return r.db.Writer(ctx).QueryRowContext(ctx, query, policy.Role, policy.Required).Scan(&policy.UpdatedAt)
+}
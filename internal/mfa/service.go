@@ -0,0 +1,233 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"xm-microservice/internal/database"
+	"xm-microservice/internal/user"
+
+	"github.com/google/uuid"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrInvalidCode    = errors.New("invalid verification code")
+	ErrNotEnrolled    = errors.New("MFA is not enabled for this user")
+	ErrAlreadyEnabled = errors.New("MFA is already enabled for this user")
+	ErrInvalidRole    = errors.New("invalid role")
+)
+
+// Service defines the business logic interface for multi-factor authentication
+type Service interface {
+	Status(ctx context.Context, userID uuid.UUID, role string) (enabled bool, required bool, err error)
+	Enroll(ctx context.Context, userID uuid.UUID, account string) (*EnrollmentResponse, error)
+	Confirm(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	Verify(ctx context.Context, userID uuid.UUID, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	ListRolePolicies(ctx context.Context) ([]RolePolicy, error)
+	SetRolePolicy(ctx context.Context, role string, required bool) (*RolePolicy, error)
+}
+
+type service struct {
+	repo   Repository
+	tx     *database.TxManager
+	issuer string
+}
+
+// NewService initializes a new MFA service with the repository, transaction manager and the issuer shown in authenticator apps
+func NewService(repo Repository, tx *database.TxManager, issuer string) Service {
+	return &service{repo: repo, tx: tx, issuer: issuer}
+}
+
+// Status reports whether a user has MFA enabled and whether their role requires it
+func (s *service) Status(ctx context.Context, userID uuid.UUID, role string) (bool, bool, error) {
+	enabled := false
+	enrollment, err := s.repo.GetEnrollment(ctx, userID)
+	switch {
+	case err == nil:
+		enabled = enrollment.Enabled
+	case !errors.Is(err, sql.ErrNoRows):
+		return false, false, err
+	}
+
+	required := false
+	policy, err := s.repo.GetRolePolicy(ctx, role)
+	switch {
+	case err == nil:
+		required = policy.Required
+	case !errors.Is(err, sql.ErrNoRows):
+		return false, false, err
+	}
+
+	return enabled, required, nil
+}
+
+// Enroll generates a new TOTP secret for a user who has not yet enabled MFA
+func (s *service) Enroll(ctx context.Context, userID uuid.UUID, account string) (*EnrollmentResponse, error) {
+	enrollment, err := s.repo.GetEnrollment(ctx, userID)
+	if err == nil && enrollment.Enabled {
+		return nil, ErrAlreadyEnabled
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	secret, err := GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SaveEnrollment(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &EnrollmentResponse{
+		Secret:          secret,
+		ProvisioningURI: ProvisioningURI(s.issuer, account, secret),
+	}, nil
+}
+
+// Confirm enables MFA once the user proves possession of the secret and returns fresh recovery codes
+func (s *service) Confirm(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	enrollment, err := s.getEnrollment(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.Enabled {
+		return nil, ErrAlreadyEnabled
+	}
+
+	step, ok := ValidateCode(enrollment.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	// Enabling MFA and storing the first recovery codes succeed or fail together
+	var codes []string
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.EnableEnrollment(ctx, userID, step); err != nil {
+			return err
+		}
+		codes, err = s.issueRecoveryCodes(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify checks a TOTP code or an unused recovery code for a user with MFA enabled
+func (s *service) Verify(ctx context.Context, userID uuid.UUID, code string) error {
+	enrollment, err := s.getEnrollment(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !enrollment.Enabled {
+		return ErrNotEnrolled
+	}
+
+	code = strings.TrimSpace(code)
+	if step, ok := ValidateCode(enrollment.Secret, code, time.Now()); ok {
+		// Each time step can only be used once to prevent replaying an observed code
+		fresh, err := s.repo.MarkStepUsed(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrInvalidCode
+		}
+		return nil
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after verifying a current code
+func (s *service) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	return s.issueRecoveryCodes(ctx, userID)
+}
+
+// ListRolePolicies returns the MFA policy of every configured role
+func (s *service) ListRolePolicies(ctx context.Context) ([]RolePolicy, error) {
+	return s.repo.ListRolePolicies(ctx)
+}
+
+// SetRolePolicy enforces or relaxes MFA for a role
+func (s *service) SetRolePolicy(ctx context.Context, role string, required bool) (*RolePolicy, error) {
+	if !user.Role(role).Valid() {
+		return nil, ErrInvalidRole
+	}
+
+	policy := &RolePolicy{Role: role, Required: required}
+	if err := s.repo.SaveRolePolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// getEnrollment retrieves the enrollment of a user and reports ErrNotEnrolled when none exists
+func (s *service) getEnrollment(ctx context.Context, userID uuid.UUID) (*Enrollment, error) {
+	enrollment, err := s.repo.GetEnrollment(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotEnrolled
+	}
+	return enrollment, err
+}
+
+// issueRecoveryCodes generates new recovery codes and stores only their hashes
+func (s *service) issueRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashRecoveryCode(code)
+	}
+
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return s.repo.ReplaceRecoveryCodes(ctx, userID, hashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a random code formatted as two groups of five characters
+func generateRecoveryCode() (string, error) {
+	buf := make([]byte, 7)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	encoded := strings.ToLower(base32NoPadding.EncodeToString(buf))[:10]
+	return encoded[:5] + "-" + encoded[5:], nil
+}
+
+// hashRecoveryCode normalizes and hashes a recovery code for storage and lookup
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,123 @@
+package mfa
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeRepository keeps the enrollment and recovery codes of a single user in memory
+type fakeRepository struct {
+	Repository
+	enrollment    *Enrollment
+	recoveryCodes map[string]bool
+}
+
+func (r *fakeRepository) GetEnrollment(_ context.Context, userID uuid.UUID) (*Enrollment, error) {
+	if r.enrollment == nil || r.enrollment.UserID != userID {
+		return nil, sql.ErrNoRows
+	}
+	copied := *r.enrollment
+	return &copied, nil
+}
+
+func (r *fakeRepository) MarkStepUsed(_ context.Context, _ uuid.UUID, step int64) (bool, error) {
+	if step <= r.enrollment.LastUsedStep {
+		return false, nil
+	}
+	r.enrollment.LastUsedStep = step
+	return true, nil
+}
+
+func (r *fakeRepository) UseRecoveryCode(_ context.Context, _ uuid.UUID, codeHash string) (bool, error) {
+	if used, ok := r.recoveryCodes[codeHash]; !ok || used {
+		return false, nil
+	}
+	r.recoveryCodes[codeHash] = true
+	return true, nil
+}
+
+// currentCode returns the TOTP code of the secret for the given offset from the current time step
+func currentCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	key, err := base32NoPadding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("cannot decode secret: %v", err)
+	}
+	return generateCode(key, time.Now().Unix()/totpPeriod+offset)
+}
+
+func TestVerify(t *testing.T) {
+	userID := uuid.New()
+	const recoveryCode = "abcde-fghij"
+
+	tests := []struct {
+		name    string
+		enabled bool
+		codes   func(secret string) []string
+		wantErr []error
+	}{
+		{
+			name:    "current code is accepted once",
+			enabled: true,
+			codes:   func(secret string) []string { c := currentCode(t, secret, 0); return []string{c, c} },
+			wantErr: []error{nil, ErrInvalidCode},
+		},
+		{
+			name:    "earlier step is rejected after a later one was used",
+			enabled: true,
+			codes: func(secret string) []string {
+				return []string{currentCode(t, secret, 0), currentCode(t, secret, -1)}
+			},
+			wantErr: []error{nil, ErrInvalidCode},
+		},
+		{
+			name:    "recovery code is accepted once, in any format",
+			enabled: true,
+			codes:   func(string) []string { return []string{recoveryCode, " ABCDEFGHIJ "} },
+			wantErr: []error{nil, ErrInvalidCode},
+		},
+		{
+			name:    "code from two steps ago is rejected",
+			enabled: true,
+			codes:   func(secret string) []string { return []string{currentCode(t, secret, -2)} },
+			wantErr: []error{ErrInvalidCode},
+		},
+		{
+			name:    "pending enrollment is not enabled",
+			enabled: false,
+			codes:   func(secret string) []string { return []string{currentCode(t, secret, 0)} },
+			wantErr: []error{ErrNotEnrolled},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := GenerateSecret()
+			if err != nil {
+				t.Fatalf("GenerateSecret() error = %v", err)
+			}
+			repo := &fakeRepository{
+				enrollment:    &Enrollment{UserID: userID, Secret: secret, Enabled: tt.enabled},
+				recoveryCodes: map[string]bool{hashRecoveryCode(recoveryCode): false},
+			}
+			s := &service{repo: repo}
+
+			for i, code := range tt.codes(secret) {
+				if err := s.Verify(context.Background(), userID, code); !errors.Is(err, tt.wantErr[i]) {
+					t.Errorf("attempt %d: Verify(%q) error = %v, want %v", i+1, code, err, tt.wantErr[i])
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyNotEnrolled(t *testing.T) {
+	s := &service{repo: &fakeRepository{}}
+	if err := s.Verify(context.Background(), uuid.New(), "123456"); !errors.Is(err, ErrNotEnrolled) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrNotEnrolled)
+	}
+}
@@ -0,0 +1,104 @@
+package mfa
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of RFC 6238 appendix B, "12345678901234567890", in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateCodeRFC6238Vectors(t *testing.T) {
+	// The RFC lists eight digits; authenticator apps use the last six
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+		{unix: 20000000000, code: "353130"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			step, ok := ValidateCode(rfcSecret, tt.code, time.Unix(tt.unix, 0))
+			if !ok {
+				t.Fatalf("ValidateCode(%q) at %d rejected a valid code", tt.code, tt.unix)
+			}
+			if want := tt.unix / totpPeriod; step != want {
+				t.Errorf("step = %d, want %d", step, want)
+			}
+		})
+	}
+}
+
+func TestValidateCodeSkew(t *testing.T) {
+	// 287082 is the code of step 1 (seconds 30 to 59)
+	const code = "287082"
+	tests := []struct {
+		name   string
+		unix   int64
+		wantOK bool
+	}{
+		{name: "current step", unix: 45, wantOK: true},
+		{name: "one step early", unix: 15, wantOK: true},
+		{name: "one step late", unix: 75, wantOK: true},
+		{name: "two steps late", unix: 95, wantOK: false},
+		{name: "far in the future", unix: 3600, wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateCode(rfcSecret, code, time.Unix(tt.unix, 0))
+			if ok != tt.wantOK {
+				t.Fatalf("ValidateCode() at %d = %v, want %v", tt.unix, ok, tt.wantOK)
+			}
+			if ok && step != 1 {
+				t.Errorf("step = %d, want the step the code was issued for", step)
+			}
+		})
+	}
+}
+
+func TestValidateCodeRejectsMalformedInput(t *testing.T) {
+	now := time.Unix(59, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		wantOK bool
+	}{
+		{name: "lower-case secret", secret: strings.ToLower(rfcSecret), code: "287082", wantOK: true},
+		{name: "wrong code", secret: rfcSecret, code: "287083"},
+		{name: "eight digits", secret: rfcSecret, code: "94287082"},
+		{name: "five digits", secret: rfcSecret, code: "87082"},
+		{name: "empty code", secret: rfcSecret, code: ""},
+		{name: "secret is not base32", secret: "not-base32!", code: "287082"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ValidateCode(tt.secret, tt.code, now); ok != tt.wantOK {
+				t.Errorf("ValidateCode(%q, %q) = %v, want %v", tt.secret, tt.code, ok, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecretRoundTrip(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+	key, err := base32NoPadding.DecodeString(secret)
+	if err != nil || len(key) != secretSize {
+		t.Fatalf("secret %q decodes to %d bytes (%v), want %d", secret, len(key), err, secretSize)
+	}
+
+	now := time.Now()
+	code := generateCode(key, now.Unix()/totpPeriod)
+	if _, ok := ValidateCode(secret, code, now); !ok {
+		t.Errorf("ValidateCode() rejected the current code %q of a generated secret", code)
+	}
+}
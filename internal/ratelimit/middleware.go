@@ -0,0 +1,183 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"xm-microservice/internal/auth"
+	"xm-microservice/pkg/logger"
+	"xm-microservice/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+// Route groups with their own rate limit policy
+const (
+	GroupLogin  = "login"
+	GroupSignup = "signup"
+	GroupAPI    = "api"
+)
+
+// KeyFunc identifies the client a request is counted against
+type KeyFunc func(r *http.Request) string
+
+// ClientKeys identifies clients by their IP address, read from X-Forwarded-For when the request came through a trusted proxy
+type ClientKeys struct {
+	trustedProxies []netip.Prefix
+}
+
+// NewClientKeys initializes ClientKeys that trust X-Forwarded-For from the given proxy networks only
+func NewClientKeys(trustedProxies []netip.Prefix) *ClientKeys {
+	return &ClientKeys{trustedProxies: trustedProxies}
+}
+
+// ParseTrustedProxies parses IP addresses and CIDR networks of trusted proxies
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q is neither an IP address nor a CIDR network", value)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
+
+// ByIP counts requests per client IP address
+func (k *ClientKeys) ByIP(r *http.Request) string {
+	return "ip:" + k.clientIP(r)
+}
+
+// ByUser counts requests per authenticated user, and per IP address for anonymous requests
+func (k *ClientKeys) ByUser(r *http.Request) string {
+	if identity, ok := auth.IdentityFromContext(r.Context()); ok {
+		return "user:" + identity.UserID.String()
+	}
+	return k.ByIP(r)
+}
+
+// ByAPIKey counts requests per API key when the request authenticated with one, so that each key
+// gets its own budget, and falls back to ByUser otherwise
+func (k *ClientKeys) ByAPIKey(r *http.Request) string {
+	if identity, ok := auth.IdentityFromContext(r.Context()); ok && identity.APIKeyID != uuid.Nil {
+		return "apikey:" + identity.APIKeyID.String()
+	}
+	return k.ByUser(r)
+}
+
+// clientIP returns the peer address, or, when the peer is a trusted proxy, the rightmost address in
+// X-Forwarded-For that is not a trusted proxy. Addresses left of it were written by the client and are ignored.
+func (k *ClientKeys) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !k.trusted(addr) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		addr = hop
+		if !k.trusted(hop) {
+			break
+		}
+	}
+	return addr.Unmap().String()
+}
+
+// trusted reports whether addr belongs to a trusted proxy
+func (k *ClientKeys) trusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range k.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Limiter applies named rate limit policies to route groups
+type Limiter struct {
+	store    Store
+	policies atomic.Pointer[map[string]Policy]
+	log      *logger.Logger
+}
+
+// NewLimiter initializes a Limiter backed by the given store with no policies
+func NewLimiter(store Store, log *logger.Logger) *Limiter {
+	l := &Limiter{store: store, log: log}
+	l.SetPolicies(nil)
+	return l
+}
+
+// SetPolicies replaces all policies atomically, a route group without a policy is not limited
+func (l *Limiter) SetPolicies(policies map[string]Policy) {
+	l.policies.Store(&policies)
+}
+
+// Middleware limits requests with the named policy, counting them per client as identified by key
+func (l *Limiter) Middleware(name string, key KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			policy, ok := (*l.policies.Load())[name]
+			if !ok || !policy.Enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := l.store.Take(r.Context(), name+":"+key(r), policy)
+			if err != nil {
+				// Fail open so that an unavailable store does not take the API down with it
+				l.log.Error(err, "Rate limit store failed, allowing request")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Policy", policy.String())
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(policy.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+
+			if !result.Allowed {
+				retryAfter := seconds(result.RetryAfter)
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				l.log.Info("Rate limit %s exceeded for %s", name, r.URL.Path)
+				utils.WriteProblem(w, r, utils.Problem{
+					Type:   "/problems/rate-limited",
+					Title:  "Too many requests",
+					Status: http.StatusTooManyRequests,
+					Detail: fmt.Sprintf("Rate limit exceeded, retry in %d seconds", retryAfter),
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds rounds a duration up to whole seconds, at least one for a non-zero duration
+func seconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"xm-microservice/internal/auth"
+
+	"github.com/google/uuid"
+)
+
+func TestClientKeysByIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.10"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+	keys := NewClientKeys(trusted)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.7:5000", want: "ip:203.0.113.7"},
+		{name: "untrusted peer cannot forge the header", remoteAddr: "203.0.113.7:5000", forwarded: []string{"198.51.100.1"}, want: "ip:203.0.113.7"},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:5000", forwarded: []string{"198.51.100.1"}, want: "ip:198.51.100.1"},
+		{name: "chain of trusted proxies", remoteAddr: "10.1.2.3:5000", forwarded: []string{"198.51.100.1, 192.0.2.10, 10.9.9.9"}, want: "ip:198.51.100.1"},
+		{name: "spoofed entries left of the client are ignored", remoteAddr: "10.1.2.3:5000", forwarded: []string{"1.1.1.1, 198.51.100.1"}, want: "ip:198.51.100.1"},
+		{name: "several header lines", remoteAddr: "10.1.2.3:5000", forwarded: []string{"198.51.100.1", "10.9.9.9"}, want: "ip:198.51.100.1"},
+		{name: "trusted proxy without the header", remoteAddr: "10.1.2.3:5000", want: "ip:10.1.2.3"},
+		{name: "malformed entry stops the walk", remoteAddr: "10.1.2.3:5000", forwarded: []string{"198.51.100.1, garbage, 10.9.9.9"}, want: "ip:10.9.9.9"},
+		{name: "only trusted proxies", remoteAddr: "10.1.2.3:5000", forwarded: []string{"10.4.4.4"}, want: "ip:10.4.4.4"},
+		{name: "IPv6 client", remoteAddr: "10.1.2.3:5000", forwarded: []string{"2001:db8::1"}, want: "ip:2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/companies", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if got := keys.ByIP(req); got != tt.want {
+				t.Errorf("ByIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientKeysByAPIKey(t *testing.T) {
+	keys := NewClientKeys(nil)
+	userID, keyID := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name     string
+		identity *auth.Identity
+		want     string
+	}{
+		{name: "API key", identity: &auth.Identity{UserID: userID, APIKeyID: keyID}, want: "apikey:" + keyID.String()},
+		{name: "token", identity: &auth.Identity{UserID: userID}, want: "user:" + userID.String()},
+		{name: "anonymous", want: "ip:203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/companies", nil)
+			req.RemoteAddr = "203.0.113.7:5000"
+			// An unverified header does not pick the bucket
+			req.Header.Set("X-API-Key", "xmk_forged")
+			if tt.identity != nil {
+				req = req.WithContext(auth.WithIdentity(req.Context(), *tt.identity))
+			}
+			if got := keys.ByAPIKey(req); got != tt.want {
+				t.Errorf("ByAPIKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		values  []string
+		wantErr bool
+	}{
+		{values: []string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32", "::1"}},
+		{values: []string{}},
+		{values: []string{"10.0.0.0/33"}, wantErr: true},
+		{values: []string{"proxy.internal"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if _, err := ParseTrustedProxies(tt.values); (err != nil) != tt.wantErr {
+			t.Errorf("ParseTrustedProxies(%q) error = %v, wantErr %v", tt.values, err, tt.wantErr)
+		}
+	}
+}
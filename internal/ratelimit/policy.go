@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy allows Limit requests per Window, refilled continuously as a token bucket
+type Policy struct {
+	Limit  int
+	Window time.Duration
+}
+
+// ParsePolicy parses a policy written as requests/window, such as "10/1m" or "100/s"
+// An empty string or a limit of 0 disables the policy
+func ParsePolicy(value string) (Policy, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Policy{}, nil
+	}
+
+	limitStr, windowStr, ok := strings.Cut(value, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("rate limit %q must be written as requests/window, e.g. 10/1m", value)
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(limitStr))
+	if err != nil || limit < 0 {
+		return Policy{}, fmt.Errorf("rate limit %q has an invalid number of requests", value)
+	}
+
+	windowStr = strings.TrimSpace(windowStr)
+	if windowStr != "" && (windowStr[0] < '0' || windowStr[0] > '9') {
+		windowStr = "1" + windowStr
+	}
+	window, err := time.ParseDuration(windowStr)
+	if err != nil || window <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %q has an invalid window", value)
+	}
+
+	return Policy{Limit: limit, Window: window}, nil
+}
+
+// Enabled reports whether the policy limits requests at all
+func (p Policy) Enabled() bool {
+	return p.Limit > 0 && p.Window > 0
+}
+
+// String formats the policy as a RateLimit-Policy header value
+func (p Policy) String() string {
+	return fmt.Sprintf("%d;w=%d", p.Limit, int(p.Window.Seconds()))
+}
+
+// interval is the time it takes to refill a single token
+func (p Policy) interval() time.Duration {
+	return p.Window / time.Duration(p.Limit)
+}
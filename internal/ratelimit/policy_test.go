@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Policy
+		wantErr bool
+	}{
+		{value: "10/1m", want: Policy{Limit: 10, Window: time.Minute}},
+		{value: "100/s", want: Policy{Limit: 100, Window: time.Second}},
+		{value: " 5 / 30s ", want: Policy{Limit: 5, Window: 30 * time.Second}},
+		{value: "0/1m", want: Policy{Limit: 0, Window: time.Minute}},
+		{value: "", want: Policy{}},
+		{value: "10", wantErr: true},
+		{value: "-1/1m", wantErr: true},
+		{value: "ten/1m", wantErr: true},
+		{value: "10/", wantErr: true},
+		{value: "10/0s", wantErr: true},
+		{value: "10/fortnight", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParsePolicy(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePolicy(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePolicy(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyEnabledAndInterval(t *testing.T) {
+	tests := []struct {
+		policy       Policy
+		wantEnabled  bool
+		wantInterval time.Duration
+		wantHeader   string
+	}{
+		{policy: Policy{Limit: 10, Window: time.Minute}, wantEnabled: true, wantInterval: 6 * time.Second, wantHeader: "10;w=60"},
+		{policy: Policy{Limit: 4, Window: time.Second}, wantEnabled: true, wantInterval: 250 * time.Millisecond, wantHeader: "4;w=1"},
+		{policy: Policy{Limit: 0, Window: time.Minute}, wantEnabled: false},
+		{policy: Policy{}, wantEnabled: false},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.Enabled(); got != tt.wantEnabled {
+			t.Errorf("%+v.Enabled() = %v, want %v", tt.policy, got, tt.wantEnabled)
+		}
+		if !tt.wantEnabled {
+			continue
+		}
+		if got := tt.policy.interval(); got != tt.wantInterval {
+			t.Errorf("%+v.interval() = %v, want %v", tt.policy, got, tt.wantInterval)
+		}
+		if got := tt.policy.String(); got != tt.wantHeader {
+			t.Errorf("%+v.String() = %q, want %q", tt.policy, got, tt.wantHeader)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store drops buckets that have refilled completely
+const sweepInterval = time.Minute
+
+// Result describes the outcome of taking a token for a request
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// Store keeps the token buckets, so that a shared implementation can limit across several instances
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore initializes a Store that keeps buckets in process memory
+func NewMemoryStore() Store {
+	return &memoryStore{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// Take refills the bucket for key and consumes one token if available
+func (s *memoryStore) Take(_ context.Context, key string, policy Policy) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	capacity := float64(policy.Limit)
+	interval := policy.interval()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		s.buckets[key] = b
+	}
+
+	// Refill for the time elapsed, never above the capacity of the current policy
+	b.tokens += float64(now.Sub(b.updated)) / float64(interval)
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+	b.updated = now
+
+	result := Result{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) * float64(interval))
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = time.Duration((capacity - b.tokens) * float64(interval))
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+// sweep drops buckets that are full again, since a new bucket starts full as well
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
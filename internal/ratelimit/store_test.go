@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable time source for the memory store
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestStore() (*memoryStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore().(*memoryStore)
+	store.now = clock.Now
+	return store, clock
+}
+
+func TestMemoryStoreTokenBucket(t *testing.T) {
+	// Three requests per 30s refill one token every 10s
+	policy := Policy{Limit: 3, Window: 30 * time.Second}
+
+	tests := []struct {
+		name          string
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+		wantReset     time.Duration
+	}{
+		{name: "full bucket", wantAllowed: true, wantRemaining: 2, wantReset: 10 * time.Second},
+		{name: "second burst request", wantAllowed: true, wantRemaining: 1, wantReset: 20 * time.Second},
+		{name: "last token", wantAllowed: true, wantRemaining: 0, wantReset: 30 * time.Second},
+		{name: "empty bucket", wantAllowed: false, wantRemaining: 0, wantRetry: 10 * time.Second, wantReset: 30 * time.Second},
+		{name: "half a token refilled", advance: 5 * time.Second, wantAllowed: false, wantRemaining: 0, wantRetry: 5 * time.Second, wantReset: 25 * time.Second},
+		{name: "one token refilled", advance: 5 * time.Second, wantAllowed: true, wantRemaining: 0, wantReset: 30 * time.Second},
+		{name: "refill is capped at the limit", advance: time.Hour, wantAllowed: true, wantRemaining: 2, wantReset: 10 * time.Second},
+	}
+
+	store, clock := newTestStore()
+	for _, tt := range tests {
+		clock.now = clock.now.Add(tt.advance)
+		result, err := store.Take(context.Background(), "ip:192.0.2.1", policy)
+		if err != nil {
+			t.Fatalf("%s: Take() error = %v", tt.name, err)
+		}
+		want := Result{Allowed: tt.wantAllowed, Remaining: tt.wantRemaining, RetryAfter: tt.wantRetry, Reset: tt.wantReset}
+		if result != want {
+			t.Errorf("%s: Take() = %+v, want %+v", tt.name, result, want)
+		}
+	}
+}
+
+func TestMemoryStoreKeysAreIndependent(t *testing.T) {
+	policy := Policy{Limit: 1, Window: time.Minute}
+	store, _ := newTestStore()
+
+	for _, key := range []string{"ip:192.0.2.1", "ip:192.0.2.2"} {
+		if result, _ := store.Take(context.Background(), key, policy); !result.Allowed {
+			t.Errorf("first request of %s was limited", key)
+		}
+	}
+	if result, _ := store.Take(context.Background(), "ip:192.0.2.1", policy); result.Allowed {
+		t.Error("second request of 192.0.2.1 was allowed")
+	}
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	policy := Policy{Limit: 2, Window: time.Minute}
+	store, clock := newTestStore()
+
+	store.Take(context.Background(), "ip:192.0.2.1", policy)
+	store.Take(context.Background(), "ip:192.0.2.2", policy)
+	store.Take(context.Background(), "ip:192.0.2.2", policy)
+
+	// After 40s the first bucket is full again, the second one still misses a token
+	clock.now = clock.now.Add(sweepInterval - 20*time.Second)
+	store.lastSweep = clock.now.Add(-sweepInterval)
+	store.Take(context.Background(), "ip:192.0.2.3", policy)
+
+	if _, ok := store.buckets["ip:192.0.2.1"]; ok {
+		t.Error("full bucket of 192.0.2.1 was not swept")
+	}
+	if _, ok := store.buckets["ip:192.0.2.2"]; !ok {
+		t.Error("bucket of 192.0.2.2 was swept before it refilled")
+	}
+}
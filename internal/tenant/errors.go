@@ -0,0 +1,31 @@
+package tenant
+
+import (
+	"database/sql"
+	"errors"
+
+	"xm-microservice/internal/database"
+	"xm-microservice/pkg/apperror"
+)
+
+var (
+	ErrNotFound     = apperror.New(apperror.ErrNotFound, "tenant not found")
+	ErrNameConflict = apperror.New(apperror.ErrConflict, "tenant name already exists")
+	ErrUnavailable  = apperror.New(apperror.ErrUnavailable, "tenant storage is unavailable")
+)
+
+// mapError translates database errors into tenant domain errors
+func mapError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case database.IsUniqueViolation(err):
+		return ErrNameConflict.WithCause(err)
+	case database.IsUnavailable(err):
+		return ErrUnavailable.WithCause(err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,58 @@
+package tenant
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"xm-microservice/pkg/logger"
+	"xm-microservice/pkg/utils"
+)
+
+// Handler manages the admin endpoints for tenants
+type Handler struct {
+	service Service
+	logger  *logger.Logger
+}
+
+// NewHandler initializes a new tenant handler
+func NewHandler(service Service, logger *logger.Logger) *Handler {
+	return &Handler{service: service, logger: logger}
+}
+
+// CreateTenant handles the creation of a new tenant
+func (h *Handler) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("CreateTenant handler invoked")
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error(err, "Invalid input while decoding tenant data")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	tenant, err := h.service.CreateTenant(r.Context(), req.Name)
+	if err != nil {
+		h.logger.Error(err, "Failed to create tenant")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.logger.Info("Tenant created successfully with ID: %s", tenant.ID)
+	utils.JSONResponse(w, http.StatusCreated, tenant)
+}
+
+// ListTenants returns all tenants
+func (h *Handler) ListTenants(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("ListTenants handler invoked")
+
+	tenants, err := h.service.ListTenants(r.Context())
+	if err != nil {
+		h.logger.Error(err, "Failed to list tenants")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, tenants)
+}
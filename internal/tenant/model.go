@@ -0,0 +1,16 @@
+package tenant
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultID is the tenant that pre-existing data and self-registered users belong to
+var DefaultID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+type Tenant struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,70 @@
+package tenant
+
+import (
+	"context"
+	"time"
+
+	"xm-microservice/internal/database"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	Create(ctx context.Context, tenant *Tenant) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Tenant, error)
+	List(ctx context.Context) ([]Tenant, error)
+}
+
+type repository struct {
+	db      *database.DB
+	timeout time.Duration
+}
+
+// NewRepository initializes a new tenant repository with the database connection and query timeout
+func NewRepository(db *database.DB, timeout time.Duration) Repository {
+	return &repository{db: db, timeout: timeout}
+}
+
+// Create inserts a new tenant into the database
+func (r *repository) Create(ctx context.Context, tenant *Tenant) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `INSERT INTO tenants (id, name) VALUES ($1, $2) RETURNING created_at`
+	return mapError(r.db.Writer(ctx).QueryRowContext(ctx, query, tenant.ID, tenant.Name).Scan(&tenant.CreatedAt))
+}
+
+// GetByID retrieves a tenant by its unique identifier
+func (r *repository) GetByID(ctx context.Context, id uuid.UUID) (*Tenant, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT id, name, created_at FROM tenants WHERE id = $1`
+	tenant := &Tenant{}
+	if err := r.db.Reader(ctx).QueryRowContext(ctx, query, id).Scan(&tenant.ID, &tenant.Name, &tenant.CreatedAt); err != nil {
+		return nil, mapError(err)
+	}
+	return tenant, nil
+}
+
+// List retrieves all tenants ordered by name
+func (r *repository) List(ctx context.Context) ([]Tenant, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, `SELECT id, name, created_at FROM tenants ORDER BY name`)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	tenants := []Tenant{}
+	for rows.Next() {
+		var tenant Tenant
+		if err := rows.Scan(&tenant.ID, &tenant.Name, &tenant.CreatedAt); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, rows.Err()
+}
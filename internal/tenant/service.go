@@ -0,0 +1,56 @@
+package tenant
+
+import (
+	"context"
+	"strings"
+
+	"xm-microservice/pkg/apperror"
+
+	"github.com/google/uuid"
+)
+
+// Service defines the business logic interface for tenants
+type Service interface {
+	CreateTenant(ctx context.Context, name string) (*Tenant, error)
+	GetTenantByID(ctx context.Context, id uuid.UUID) (*Tenant, error)
+	ListTenants(ctx context.Context) ([]Tenant, error)
+}
+
+type service struct {
+	repo Repository
+}
+
+// NewService initializes a new tenant service with the repository
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+// CreateTenant validates and creates a new tenant
+func (s *service) CreateTenant(ctx context.Context, name string) (*Tenant, error) {
+	name = strings.TrimSpace(name)
+	violations := &apperror.ValidationError{}
+	if name == "" {
+		violations.Add("name", apperror.CodeRequired, "tenant name is required")
+	} else if len(name) > 100 {
+		violations.Add("name", apperror.CodeTooLong, "tenant name must be up to 100 characters")
+	}
+	if err := violations.Err(); err != nil {
+		return nil, err
+	}
+
+	tenant := &Tenant{ID: uuid.New(), Name: name}
+	if err := s.repo.Create(ctx, tenant); err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+// GetTenantByID retrieves a tenant by its ID
+func (s *service) GetTenantByID(ctx context.Context, id uuid.UUID) (*Tenant, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// ListTenants retrieves all tenants
+func (s *service) ListTenants(ctx context.Context) ([]Tenant, error) {
+	return s.repo.List(ctx)
+}
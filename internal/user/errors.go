@@ -0,0 +1,36 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+
+	"xm-microservice/internal/database"
+	"xm-microservice/pkg/apperror"
+)
+
+var (
+	ErrNotFound         = apperror.New(apperror.ErrNotFound, "user not found")
+	ErrUsernameConflict = apperror.New(apperror.ErrConflict, "user already exists")
+	ErrIdentityConflict = apperror.New(apperror.ErrConflict, "identity is already linked to another user")
+	ErrTenantNotFound   = apperror.New(apperror.ErrValidation, "tenant does not exist")
+	ErrTenantMismatch   = apperror.New(apperror.ErrForbidden, "the account belongs to another tenant")
+	ErrUnavailable      = apperror.New(apperror.ErrUnavailable, "user storage is unavailable")
+)
+
+// mapError translates database errors into user domain errors
+func mapError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case database.IsUniqueViolation(err):
+		return ErrUsernameConflict.WithCause(err)
+	case database.IsForeignKeyViolation(err):
+		return ErrTenantNotFound.WithCause(err)
+	case database.IsUnavailable(err):
+		return ErrUnavailable.WithCause(err)
+	default:
+		return err
+	}
+}
@@ -3,7 +3,6 @@ package user
 import (
 	"encoding/json"
 	"net/http"
-	"strings"
 
 	"xm-microservice/pkg/logger"
 	"xm-microservice/pkg/utils"
@@ -16,6 +15,8 @@ import (
 type UserResponse struct {
 	ID       uuid.UUID `json:"id"`
 	Username string    `json:"username"`
+	Role     Role      `json:"role"`
+	TenantID uuid.UUID `json:"tenant_id"`
 }
 
 // Handler manages HTTP requests related to user operations
@@ -34,6 +35,8 @@ func sanitizeUser(user *User) UserResponse {
 	return UserResponse{
 		ID:       user.ID,
 		Username: user.Username,
+		Role:     user.Role,
+		TenantID: user.TenantID,
 	}
 }
 
@@ -48,26 +51,14 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.logger.Error(err, "Invalid input while decoding user data")
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid input")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
 		return
 	}
 
-	if req.Username == "" || req.Password == "" {
-		h.logger.Info("Validation failed: Username or password is empty")
-		utils.ErrorResponse(w, http.StatusBadRequest, "Username and password cannot be empty")
-		return
-	}
-
-	user, err := h.service.CreateUser(req.Username, req.Password)
+	user, err := h.service.CreateUser(r.Context(), req.Username, req.Password)
 	if err != nil {
 		h.logger.Error(err, "Failed to create user")
-
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			utils.ErrorResponse(w, http.StatusBadRequest, "User already exists")
-			return
-		}
-
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to create user")
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -82,14 +73,14 @@ func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
 		h.logger.Error(err, "Invalid ID")
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid ID")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	user, err := h.service.GetUserByID(id)
+	user, err := h.service.GetUserByID(r.Context(), id)
 	if err != nil {
-		h.logger.Error(err, "User not found")
-		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
+		h.logger.Error(err, "Failed to retrieve user")
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -104,7 +95,7 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
 		h.logger.Error(err, "Invalid ID")
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid ID")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
@@ -115,19 +106,13 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.logger.Error(err, "Invalid input while decoding user data")
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid input")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
 		return
 	}
 
-	if req.Username == "" || req.Password == "" {
-		h.logger.Info("Validation failed: Username or password is empty")
-		utils.ErrorResponse(w, http.StatusBadRequest, "Username and password cannot be empty")
-		return
-	}
-
-	if err := h.service.UpdateUser(id, req.Username, req.Password); err != nil {
+	if err := h.service.UpdateUser(r.Context(), id, req.Username, req.Password); err != nil {
 		h.logger.Error(err, "Failed to update user")
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update user")
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -142,16 +127,46 @@ func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
 		h.logger.Error(err, "Invalid ID")
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid ID")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	if err := h.service.DeleteUser(id); err != nil {
+	if err := h.service.DeleteUser(r.Context(), id); err != nil {
 		h.logger.Error(err, "Failed to delete user")
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to delete user")
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	h.logger.Info("User deleted successfully with ID: %s", id)
 	utils.JSONResponse(w, http.StatusNoContent, nil)
 }
+
+// AssignTenant handles moving a user to another tenant
+func (h *Handler) AssignTenant(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("AssignTenant handler invoked")
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.logger.Error(err, "Invalid ID")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	var req struct {
+		TenantID uuid.UUID `json:"tenant_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TenantID == uuid.Nil {
+		h.logger.Error(err, "Invalid input while decoding tenant assignment")
+		utils.ErrorResponse(w, r, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	if err := h.service.AssignTenant(r.Context(), id, req.TenantID); err != nil {
+		h.logger.Error(err, "Failed to assign tenant")
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	h.logger.Info("User %s assigned to tenant %s", id, req.TenantID)
+	utils.JSONResponse(w, http.StatusOK, map[string]string{"message": "Tenant assigned successfully"})
+}
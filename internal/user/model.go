@@ -2,8 +2,48 @@ package user
 
 import "github.com/google/uuid"
 
+// maxUsernameLength is the length of the username column
+const maxUsernameLength = 255
+
+// Role defines the authorization level granted to a user
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+	// RolePlatformAdmin operates the service across tenants: it manages tenants, tenant membership and platform-wide settings
+	RolePlatformAdmin Role = "platform_admin"
+)
+
+// roleRanks orders the roles from the least to the most privileged
+var roleRanks = map[Role]int{
+	RoleUser:          1,
+	RoleAdmin:         2,
+	RolePlatformAdmin: 3,
+}
+
+// Valid reports whether the role is one of the known roles
+func (r Role) Valid() bool {
+	return r == RoleUser || r == RoleAdmin || r == RolePlatformAdmin
+}
+
+// AtLeast reports whether the role is a known role granting at least the privileges of min
+func (r Role) AtLeast(min Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[min]
+}
+
 type User struct {
 	ID           uuid.UUID `json:"id"`
 	Username     string    `json:"username"`
 	PasswordHash string    `json:"password_hash"`
+	Role         Role      `json:"role"`
+	TenantID     uuid.UUID `json:"tenant_id"`
+}
+
+// Identity links a user to an account at an external OIDC identity provider
+type Identity struct {
+	Issuer  string    `json:"issuer"`
+	Subject string    `json:"subject"`
+	UserID  uuid.UUID `json:"user_id"`
+	Email   string    `json:"email,omitempty"`
 }
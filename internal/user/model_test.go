@@ -0,0 +1,25 @@
+package user
+
+import "testing"
+
+func TestRoleAtLeast(t *testing.T) {
+	tests := []struct {
+		role Role
+		min  Role
+		want bool
+	}{
+		{role: RoleUser, min: RoleUser, want: true},
+		{role: RoleUser, min: RoleAdmin, want: false},
+		{role: RoleAdmin, min: RoleAdmin, want: true},
+		{role: RolePlatformAdmin, min: RoleAdmin, want: true},
+		{role: RoleAdmin, min: RolePlatformAdmin, want: false},
+		{role: RolePlatformAdmin, min: RolePlatformAdmin, want: true},
+		{role: "", min: RoleUser, want: false},
+		{role: "owner", min: RoleUser, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.AtLeast(tt.min); got != tt.want {
+			t.Errorf("Role(%q).AtLeast(%q) = %v, want %v", tt.role, tt.min, got, tt.want)
+		}
+	}
+}
@@ -1,70 +1,147 @@
 package user
 
 import (
-	"database/sql"
-	"errors"
+	"context"
+	"time"
+
+	"xm-microservice/internal/database"
 
 	"github.com/google/uuid"
 )
 
 // Repository handles database operations related to users
 type Repository struct {
-	db *sql.DB
+	db      *database.DB
+	timeout time.Duration
 }
 
 // NewRepository initializes a new Repository with a database connection
-func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+// Lookups used by login and provisioning stay on the primary because they must read their own writes
+func NewRepository(db *database.DB, timeout time.Duration) *Repository {
+	return &Repository{db: db, timeout: timeout}
 }
 
 // CreateUser inserts a new user into the database
-func (r *Repository) CreateUser(user *User) error {
-	query := `INSERT INTO users (id, username, password_hash) VALUES ($1, $2, $3)`
-	_, err := r.db.Exec(query, user.ID, user.Username, user.PasswordHash)
-	return err
+func (r *Repository) CreateUser(ctx context.Context, user *User) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `INSERT INTO users (id, username, password_hash, role, tenant_id) VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.db.Writer(ctx).ExecContext(ctx, query, user.ID, user.Username, user.PasswordHash, user.Role, user.TenantID)
+	return mapError(err)
 }
 
 // GetUserByID retrieves a user by their unique ID
-func (r *Repository) GetUserByID(id uuid.UUID) (*User, error) {
-	query := `SELECT id, username, password_hash FROM users WHERE id = $1`
-	row := r.db.QueryRow(query, id)
+func (r *Repository) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT id, username, password_hash, role, tenant_id FROM users WHERE id = $1`
+	row := r.db.Reader(ctx).QueryRowContext(ctx, query, id)
 
 	var user User
-	if err := row.Scan(&user.ID, &user.Username, &user.PasswordHash); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
-		}
-		return nil, err
+	if err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.TenantID); err != nil {
+		return nil, mapError(err)
 	}
 	return &user, nil
 }
 
 // UpdateUser updates an existing user's information
-func (r *Repository) UpdateUser(id uuid.UUID, user *User) error {
+func (r *Repository) UpdateUser(ctx context.Context, id uuid.UUID, user *User) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
 	query := `UPDATE users SET username = $1, password_hash = $2 WHERE id = $3`
-	_, err := r.db.Exec(query, user.Username, user.PasswordHash, id)
-	return err
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, user.Username, user.PasswordHash, id)
+	if err != nil {
+		return mapError(err)
+	}
+	return mapError(database.ExpectRows(result))
+}
+
+// UpdateRole changes the role assigned to a user
+func (r *Repository) UpdateRole(ctx context.Context, id uuid.UUID, role Role) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE users SET role = $1 WHERE id = $2`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, role, id)
+	if err != nil {
+		return mapError(err)
+	}
+	return mapError(database.ExpectRows(result))
+}
+
+// UpdateTenant moves a user to another tenant
+func (r *Repository) UpdateTenant(ctx context.Context, id, tenantID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE users SET tenant_id = $1 WHERE id = $2`
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, tenantID, id)
+	if err != nil {
+		return mapError(err)
+	}
+	return mapError(database.ExpectRows(result))
 }
 
 // DeleteUser removes a user from the database by their ID
-func (r *Repository) DeleteUser(id uuid.UUID) error {
+func (r *Repository) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
 	query := `DELETE FROM users WHERE id = $1`
-	_, err := r.db.Exec(query, id)
-	return err
+	result, err := r.db.Writer(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		return mapError(err)
+	}
+	return mapError(database.ExpectRows(result))
 }
 
 // GetUserByUsername retrieves a user by their username
-func (r *Repository) GetUserByUsername(username string) (*User, error) {
-	query := `SELECT id, username, password_hash FROM users WHERE username = $1`
-	row := r.db.QueryRow(query, username)
+func (r *Repository) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT id, username, password_hash, role, tenant_id FROM users WHERE username = $1`
+	row := r.db.Writer(ctx).QueryRowContext(ctx, query, username)
+
+	var user User
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.TenantID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &user, nil
+}
+
+// GetUserByIdentity retrieves the user linked to an external identity
+func (r *Repository) GetUserByIdentity(ctx context.Context, issuer, subject string) (*User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT u.id, u.username, u.password_hash, u.role, u.tenant_id FROM users u
+		JOIN user_identities i ON i.user_id = u.id
+		WHERE i.issuer = $1 AND i.subject = $2`
+	row := r.db.Writer(ctx).QueryRowContext(ctx, query, issuer, subject)
 
 	var user User
-	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash)
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.TenantID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
-		}
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &user, nil
 }
+
+// LinkIdentity associates an external identity with an existing user
+func (r *Repository) LinkIdentity(ctx context.Context, identity *Identity) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `INSERT INTO user_identities (issuer, subject, user_id, email) VALUES ($1, $2, $3, $4)`
+	_, err := r.db.Writer(ctx).ExecContext(ctx, query, identity.Issuer, identity.Subject, identity.UserID, identity.Email)
+	// The identity primary key is the only unique constraint that can fail here
+	if database.IsUniqueViolation(err) {
+		return ErrIdentityConflict.WithCause(err)
+	}
+	return mapError(err)
+}
@@ -1,22 +1,53 @@
 package user
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
+	"xm-microservice/internal/database"
+	"xm-microservice/internal/tenant"
+	"xm-microservice/pkg/apperror"
+
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// store is the part of the user repository that the service relies on
+type store interface {
+	CreateUser(ctx context.Context, user *User) error
+	GetUserByID(ctx context.Context, id uuid.UUID) (*User, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	GetUserByIdentity(ctx context.Context, issuer, subject string) (*User, error)
+	LinkIdentity(ctx context.Context, identity *Identity) error
+	UpdateUser(ctx context.Context, id uuid.UUID, user *User) error
+	UpdateRole(ctx context.Context, id uuid.UUID, role Role) error
+	UpdateTenant(ctx context.Context, id, tenantID uuid.UUID) error
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+}
+
+// transactor runs a function in a transaction, as database.TxManager does
+type transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
 // Service handles business logic related to users
 type Service struct {
-	repo *Repository
+	repo store
+	tx   transactor
 }
 
-// NewService initializes a new Service with a user repository
-func NewService(repo *Repository) *Service {
-	return &Service{repo: repo}
+// NewService initializes a new Service with a user repository and transaction manager
+func NewService(repo *Repository, tx *database.TxManager) *Service {
+	return &Service{repo: repo, tx: tx}
 }
 
 // CreateUser handles the creation of a new user, including password hashing
-func (s *Service) CreateUser(username, password string) (*User, error) {
+func (s *Service) CreateUser(ctx context.Context, username, password string) (*User, error) {
+	if err := validateCredentials(username, password); err != nil {
+		return nil, err
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
@@ -26,21 +57,147 @@ func (s *Service) CreateUser(username, password string) (*User, error) {
 		ID:           uuid.New(),
 		Username:     username,
 		PasswordHash: string(hashedPassword),
+		Role:         RoleUser,
+		TenantID:     tenant.DefaultID,
+	}
+
+	if err := s.repo.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// FederatedProfile holds the claims asserted about a user by an external identity provider
+type FederatedProfile struct {
+	Issuer        string
+	Subject       string
+	Username      string
+	Email         string
+	EmailVerified bool
+	Role          Role
+	// TenantID is the tenant that the identity provider or its configuration assigns the user to
+	TenantID uuid.UUID
+}
+
+// ProvisionFederatedUser returns the local user for an external identity, linking an
+// existing account by verified email or creating a new one in the profile's tenant on first login.
+// The role is kept in sync with the identity provider on every call, and a user of another tenant is refused.
+// Creating the user and linking the identity happen in one transaction.
+func (s *Service) ProvisionFederatedUser(ctx context.Context, profile FederatedProfile) (*User, error) {
+	var user *User
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.provisionFederatedUser(ctx, profile)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// provisionFederatedUser resolves or creates the local user for an external identity
+func (s *Service) provisionFederatedUser(ctx context.Context, profile FederatedProfile) (*User, error) {
+	user, err := s.repo.GetUserByIdentity(ctx, profile.Issuer, profile.Subject)
+	if err == nil {
+		if user.TenantID != profile.TenantID {
+			return nil, ErrTenantMismatch
+		}
+		if err := s.syncRole(ctx, user, profile.Role); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	user = nil
+	if profile.EmailVerified && profile.Email != "" {
+		existing, err := s.repo.GetUserByUsername(ctx, profile.Email)
+		switch {
+		case err == nil:
+			user = existing
+		case !errors.Is(err, ErrNotFound):
+			return nil, err
+		}
 	}
 
-	if err := s.repo.CreateUser(user); err != nil {
+	if user == nil {
+		username, err := s.freeUsername(ctx, profile)
+		if err != nil {
+			return nil, err
+		}
+		// Federated users have no local password, so password login is never possible for them
+		user = &User{
+			ID:       uuid.New(),
+			Username: username,
+			Role:     profile.Role,
+			TenantID: profile.TenantID,
+		}
+		if err := s.repo.CreateUser(ctx, user); err != nil {
+			return nil, err
+		}
+	} else if user.TenantID != profile.TenantID {
+		return nil, ErrTenantMismatch
+	} else if err := s.syncRole(ctx, user, profile.Role); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Issuer:  profile.Issuer,
+		Subject: profile.Subject,
+		UserID:  user.ID,
+		Email:   profile.Email,
+	}
+	if err := s.repo.LinkIdentity(ctx, identity); err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
+// freeUsername picks the username for a new federated user. The preferred username is used when it is free;
+// otherwise the user is named after the identity as issuer:subject, which only this identity can claim.
+// Names are checked up front because a failed insert would abort the surrounding transaction.
+func (s *Service) freeUsername(ctx context.Context, profile FederatedProfile) (string, error) {
+	for _, candidate := range []string{profile.Username, fmt.Sprintf("%s:%s", profile.Issuer, profile.Subject)} {
+		if candidate == "" || len(candidate) > maxUsernameLength {
+			continue
+		}
+		_, err := s.repo.GetUserByUsername(ctx, candidate)
+		if errors.Is(err, ErrNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", ErrUsernameConflict
+}
+
+// syncRole updates the stored role of a user when it differs from the given role
+func (s *Service) syncRole(ctx context.Context, user *User, role Role) error {
+	if user.Role == role {
+		return nil
+	}
+	if err := s.repo.UpdateRole(ctx, user.ID, role); err != nil {
+		return err
+	}
+	user.Role = role
+	return nil
+}
+
 // GetUserByID retrieves a user by their unique ID
-func (s *Service) GetUserByID(id uuid.UUID) (*User, error) {
-	return s.repo.GetUserByID(id)
+func (s *Service) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
+	return s.repo.GetUserByID(ctx, id)
 }
 
 // UpdateUser updates an existing user's username and password
-func (s *Service) UpdateUser(id uuid.UUID, username, password string) error {
+func (s *Service) UpdateUser(ctx context.Context, id uuid.UUID, username, password string) error {
+	if err := validateCredentials(username, password); err != nil {
+		return err
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
@@ -51,10 +208,27 @@ func (s *Service) UpdateUser(id uuid.UUID, username, password string) error {
 		PasswordHash: string(hashedPassword),
 	}
 
-	return s.repo.UpdateUser(id, user)
+	return s.repo.UpdateUser(ctx, id, user)
+}
+
+// AssignTenant moves a user to another tenant
+func (s *Service) AssignTenant(ctx context.Context, id, tenantID uuid.UUID) error {
+	return s.repo.UpdateTenant(ctx, id, tenantID)
 }
 
 // DeleteUser removes a user from the system by their ID
-func (s *Service) DeleteUser(id uuid.UUID) error {
-	return s.repo.DeleteUser(id)
+func (s *Service) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	return s.repo.DeleteUser(ctx, id)
+}
+
+// validateCredentials checks that both the username and the password are present
+func validateCredentials(username, password string) error {
+	violations := &apperror.ValidationError{}
+	if username == "" {
+		violations.Add("username", apperror.CodeRequired, "username cannot be empty")
+	}
+	if password == "" {
+		violations.Add("password", apperror.CodeRequired, "password cannot be empty")
+	}
+	return violations.Err()
 }
@@ -0,0 +1,223 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// fakeStore keeps users and identities in memory
+type fakeStore struct {
+	users      map[uuid.UUID]*User
+	identities map[[2]string]uuid.UUID
+	created    int
+	linked     int
+}
+
+func newFakeStore(users ...*User) *fakeStore {
+	s := &fakeStore{users: map[uuid.UUID]*User{}, identities: map[[2]string]uuid.UUID{}}
+	for _, u := range users {
+		s.users[u.ID] = u
+	}
+	return s
+}
+
+func (s *fakeStore) CreateUser(_ context.Context, user *User) error {
+	for _, u := range s.users {
+		if u.Username == user.Username {
+			return ErrUsernameConflict
+		}
+	}
+	copied := *user
+	s.users[user.ID] = &copied
+	s.created++
+	return nil
+}
+
+func (s *fakeStore) GetUserByID(_ context.Context, id uuid.UUID) (*User, error) {
+	if u, ok := s.users[id]; ok {
+		copied := *u
+		return &copied, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *fakeStore) GetUserByUsername(_ context.Context, username string) (*User, error) {
+	for _, u := range s.users {
+		if u.Username == username {
+			copied := *u
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *fakeStore) GetUserByIdentity(ctx context.Context, issuer, subject string) (*User, error) {
+	id, ok := s.identities[[2]string{issuer, subject}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.GetUserByID(ctx, id)
+}
+
+func (s *fakeStore) LinkIdentity(_ context.Context, identity *Identity) error {
+	key := [2]string{identity.Issuer, identity.Subject}
+	if _, ok := s.identities[key]; ok {
+		return ErrIdentityConflict
+	}
+	s.identities[key] = identity.UserID
+	s.linked++
+	return nil
+}
+
+func (s *fakeStore) UpdateUser(_ context.Context, id uuid.UUID, user *User) error {
+	return errors.New("not implemented")
+}
+
+func (s *fakeStore) UpdateRole(_ context.Context, id uuid.UUID, role Role) error {
+	u, ok := s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	u.Role = role
+	return nil
+}
+
+func (s *fakeStore) UpdateTenant(_ context.Context, id, tenantID uuid.UUID) error {
+	return errors.New("not implemented")
+}
+
+func (s *fakeStore) DeleteUser(_ context.Context, id uuid.UUID) error {
+	return errors.New("not implemented")
+}
+
+// inlineTx runs transactions without a database
+type inlineTx struct{}
+
+func (inlineTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+const testIssuer = "https://idp.example.com"
+
+var (
+	tenantA = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	tenantB = uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+)
+
+func profile(subject, username string) FederatedProfile {
+	return FederatedProfile{Issuer: testIssuer, Subject: subject, Username: username, Role: RoleUser, TenantID: tenantA}
+}
+
+func TestProvisionFederatedUserFirstLogin(t *testing.T) {
+	store := newFakeStore()
+	service := &Service{repo: store, tx: inlineTx{}}
+
+	u, err := service.ProvisionFederatedUser(context.Background(), profile("sub-1", "jane"))
+	if err != nil {
+		t.Fatalf("ProvisionFederatedUser() error = %v", err)
+	}
+	if u.Username != "jane" || u.TenantID != tenantA || u.Role != RoleUser || u.PasswordHash != "" {
+		t.Errorf("created user = %+v, want jane in tenant A with role user and no password", u)
+	}
+	if store.created != 1 || store.identities[[2]string{testIssuer, "sub-1"}] != u.ID {
+		t.Errorf("created %d users and identities %v, want one user linked to sub-1", store.created, store.identities)
+	}
+}
+
+func TestProvisionFederatedUserReturningLogin(t *testing.T) {
+	store := newFakeStore()
+	service := &Service{repo: store, tx: inlineTx{}}
+	first, err := service.ProvisionFederatedUser(context.Background(), profile("sub-1", "jane"))
+	if err != nil {
+		t.Fatalf("first login error = %v", err)
+	}
+
+	returning := profile("sub-1", "jane.renamed")
+	returning.Role = RoleAdmin
+	again, err := service.ProvisionFederatedUser(context.Background(), returning)
+	if err != nil {
+		t.Fatalf("returning login error = %v", err)
+	}
+	if again.ID != first.ID || again.Username != "jane" {
+		t.Errorf("returning login resolved %+v, want the user created on first login", again)
+	}
+	if again.Role != RoleAdmin || store.users[first.ID].Role != RoleAdmin {
+		t.Errorf("role = %s, stored %s, want the role synced to admin", again.Role, store.users[first.ID].Role)
+	}
+	if store.created != 1 || store.linked != 1 {
+		t.Errorf("created %d users and linked %d identities, want 1 and 1", store.created, store.linked)
+	}
+}
+
+func TestProvisionFederatedUserLinksByVerifiedEmail(t *testing.T) {
+	local := &User{ID: uuid.New(), Username: "jane@example.com", PasswordHash: "hash", Role: RoleUser, TenantID: tenantA}
+
+	tests := []struct {
+		name       string
+		verified   bool
+		tenantID   uuid.UUID
+		wantLinked bool
+		wantErr    error
+	}{
+		{name: "verified email links the local user", verified: true, tenantID: tenantA, wantLinked: true},
+		{name: "unverified email creates a new user", verified: false, tenantID: tenantA},
+		{name: "local user of another tenant is refused", verified: true, tenantID: tenantB, wantErr: ErrTenantMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			copied := *local
+			store := newFakeStore(&copied)
+			service := &Service{repo: store, tx: inlineTx{}}
+
+			p := profile("sub-2", "jane@example.com")
+			p.Email, p.EmailVerified, p.TenantID = "jane@example.com", tt.verified, tt.tenantID
+			u, err := service.ProvisionFederatedUser(context.Background(), p)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ProvisionFederatedUser() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if linked := u.ID == local.ID; linked != tt.wantLinked {
+				t.Errorf("linked to the local user = %v, want %v", linked, tt.wantLinked)
+			}
+			if store.identities[[2]string{testIssuer, "sub-2"}] != u.ID {
+				t.Errorf("identity not linked to the resolved user %s", u.ID)
+			}
+		})
+	}
+}
+
+func TestProvisionFederatedUserUsernameCollision(t *testing.T) {
+	local := &User{ID: uuid.New(), Username: "jane", PasswordHash: "hash", Role: RoleUser, TenantID: tenantA}
+	store := newFakeStore(local)
+	service := &Service{repo: store, tx: inlineTx{}}
+
+	u, err := service.ProvisionFederatedUser(context.Background(), profile("sub-3", "jane"))
+	if err != nil {
+		t.Fatalf("ProvisionFederatedUser() error = %v", err)
+	}
+	if want := testIssuer + ":sub-3"; u.Username != want {
+		t.Errorf("username = %q, want %q", u.Username, want)
+	}
+	if u.ID == local.ID {
+		t.Error("federated user was merged into the local account with the same name")
+	}
+}
+
+func TestProvisionFederatedUserTenantMismatchOnReturningLogin(t *testing.T) {
+	store := newFakeStore()
+	service := &Service{repo: store, tx: inlineTx{}}
+	if _, err := service.ProvisionFederatedUser(context.Background(), profile("sub-4", "joe")); err != nil {
+		t.Fatalf("first login error = %v", err)
+	}
+
+	moved := profile("sub-4", "joe")
+	moved.TenantID = tenantB
+	if _, err := service.ProvisionFederatedUser(context.Background(), moved); !errors.Is(err, ErrTenantMismatch) {
+		t.Errorf("login with another tenant error = %v, want %v", err, ErrTenantMismatch)
+	}
+}
@@ -0,0 +1,97 @@
+package apperror
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Error kinds shared by all domain packages. Domain errors wrap one of these
+// so that transport code can translate them without knowing the domain.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrForbidden   = errors.New("forbidden")
+	ErrValidation  = errors.New("validation failed")
+	ErrUnavailable = errors.New("service unavailable")
+)
+
+// Error is a domain error with a client-facing message, a kind and an optional underlying cause
+type Error struct {
+	kind    error
+	message string
+	cause   error
+}
+
+// New creates a domain error of the given kind
+func New(kind error, message string) *Error {
+	return &Error{kind: kind, message: message}
+}
+
+// Wrap creates a domain error of the given kind that keeps the underlying cause for logging
+func Wrap(kind error, message string, cause error) *Error {
+	return &Error{kind: kind, message: message, cause: cause}
+}
+
+// Error returns the client-facing message
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.message + ": " + e.cause.Error()
+	}
+	return e.message
+}
+
+// Message returns the client-facing message without the underlying cause
+func (e *Error) Message() string {
+	return e.message
+}
+
+// Unwrap exposes both the kind and the cause to errors.Is and errors.As
+func (e *Error) Unwrap() []error {
+	if e.cause != nil {
+		return []error{e.kind, e.cause}
+	}
+	return []error{e.kind}
+}
+
+// Is reports a match when the target is the same domain error, even if it wraps another cause
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.cause == nil && t.kind == e.kind && t.message == e.message
+}
+
+// WithCause returns a copy of the error that keeps the underlying cause for logging
+func (e *Error) WithCause(cause error) *Error {
+	return &Error{kind: e.kind, message: e.message, cause: cause}
+}
+
+// HTTPStatus translates an error to the HTTP status code matching its kind
+func HTTPStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Message returns the client-facing message of an error, hiding details of unexpected errors
+func Message(err error) string {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Message()
+	}
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Error()
+	}
+	return http.StatusText(http.StatusInternalServerError)
+}
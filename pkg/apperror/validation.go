@@ -0,0 +1,51 @@
+package apperror
+
+import "strings"
+
+// Machine-readable codes describing why a field is invalid
+const (
+	CodeRequired     = "required"
+	CodeTooLong      = "too_long"
+	CodeOutOfRange   = "out_of_range"
+	CodeInvalidValue = "invalid_value"
+	CodeConflict     = "conflict"
+)
+
+// FieldError describes a single violated rule on an input field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects every violated field rule of an input
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Add records a violated rule for a field
+func (e *ValidationError) Add(field, code, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Err returns the validation error when any rule was violated and nil otherwise
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error joins the messages of all violated rules
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap marks the error as a validation failure
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
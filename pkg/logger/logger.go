@@ -4,21 +4,76 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// Level is the minimum severity a Logger writes
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelError
+)
+
+// ParseLevel converts a level name such as "debug", "info" or "error" to a Level
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level %q", name)
+	}
+}
+
+// String returns the name of the level
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelError:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
 // Logger handles logging for informational and error messages
 type Logger struct {
 	infoLogger  *log.Logger
 	errorLogger *log.Logger
+	level       atomic.Int32
 }
 
-// NewLogger initializes a new Logger with output to stdout and stderr
+// NewLogger initializes a new Logger with output to stdout and stderr at info level
 func NewLogger() *Logger {
-	return &Logger{
+	l := &Logger{
 		infoLogger:  log.New(os.Stdout, "", 0),
 		errorLogger: log.New(os.Stderr, "", 0),
 	}
+	l.level.Store(int32(LevelInfo))
+	return l
+}
+
+// SetLevel changes the minimum level written, safe to call while other goroutines log
+func (l *Logger) SetLevel(level Level) {
+	l.level.Store(int32(level))
+}
+
+// Level returns the minimum level written
+func (l *Logger) Level() Level {
+	return Level(l.level.Load())
+}
+
+// enabled reports whether messages of the given level are written
+func (l *Logger) enabled(level Level) bool {
+	return level >= l.Level()
 }
 
 // formatLog formats the log message with timestamp and level
@@ -27,8 +82,23 @@ func formatLog(level, message string) string {
 	return fmt.Sprintf("[%s] %s %s", timestamp, level, message)
 }
 
+// Debug logs diagnostic messages that are only written at debug level
+func (l *Logger) Debug(message string, args ...interface{}) {
+	if !l.enabled(LevelDebug) {
+		return
+	}
+	if len(args) > 0 {
+		l.infoLogger.Println(formatLog("DEBUG", fmt.Sprintf(message, args...)))
+	} else {
+		l.infoLogger.Println(formatLog("DEBUG", message))
+	}
+}
+
 // Info logs informational messages with formatting support
 func (l *Logger) Info(message string, args ...interface{}) {
+	if !l.enabled(LevelInfo) {
+		return
+	}
 	if len(args) > 0 {
 		l.infoLogger.Println(formatLog("INFO", fmt.Sprintf(message, args...)))
 	} else {
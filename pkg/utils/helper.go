@@ -14,8 +14,3 @@ func JSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
-
-// ErrorResponse writes an error message as JSON
-func ErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	JSONResponse(w, statusCode, map[string]string{"error": message})
-}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"xm-microservice/pkg/apperror"
+	"xm-microservice/pkg/requestid"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object
+type Problem struct {
+	Type       string                 `json:"type"`
+	Title      string                 `json:"title"`
+	Status     int                    `json:"status"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	Errors     []apperror.FieldError  `json:"errors,omitempty"`
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// problemExtender is implemented by errors that add extension members to their problem details
+type problemExtender interface {
+	ProblemExtensions() map[string]interface{}
+}
+
+// MarshalJSON writes the extension members next to the standard members, which take precedence
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type standard Problem
+	encoded, err := json.Marshal(standard(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return encoded, err
+	}
+
+	members := map[string]json.RawMessage{}
+	if err := json.Unmarshal(encoded, &members); err != nil {
+		return nil, err
+	}
+	for name, value := range p.Extensions {
+		if _, ok := members[name]; ok {
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		members[name] = raw
+	}
+	return json.Marshal(members)
+}
+
+// problemTypes maps each error kind to its problem type URI and title
+var problemTypes = []struct {
+	kind  error
+	uri   string
+	title string
+}{
+	{apperror.ErrValidation, "/problems/validation-error", "Validation failed"},
+	{apperror.ErrNotFound, "/problems/not-found", "Resource not found"},
+	{apperror.ErrConflict, "/problems/conflict", "Resource conflict"},
+	{apperror.ErrForbidden, "/problems/forbidden", "Forbidden"},
+	{apperror.ErrUnavailable, "/problems/service-unavailable", "Service unavailable"},
+}
+
+// ErrorResponse writes a generic problem for the given status code and detail message
+func ErrorResponse(w http.ResponseWriter, r *http.Request, statusCode int, detail string) {
+	WriteProblem(w, r, Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: detail,
+	})
+}
+
+// WriteError translates a domain error to its problem type and HTTP status and writes it
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusInternalServerError),
+		Status: apperror.HTTPStatus(err),
+		Detail: apperror.Message(err),
+	}
+
+	for _, problemType := range problemTypes {
+		if errors.Is(err, problemType.kind) {
+			problem.Type = problemType.uri
+			problem.Title = problemType.title
+			break
+		}
+	}
+
+	var validationErr *apperror.ValidationError
+	if errors.As(err, &validationErr) {
+		problem.Detail = "The request contains invalid fields"
+		problem.Errors = validationErr.Fields
+	}
+
+	var extender problemExtender
+	if errors.As(err, &extender) {
+		problem.Extensions = extender.ProblemExtensions()
+	}
+
+	WriteProblem(w, r, problem)
+}
+
+// WriteProblem fills in the request-specific members of a problem and writes it
+func WriteProblem(w http.ResponseWriter, r *http.Request, problem Problem) {
+	problem.Instance = r.URL.Path
+	problem.RequestID = requestid.FromContext(r.Context())
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}